@@ -0,0 +1,133 @@
+// Package cli runs fwtui without the terminal UI when it is started with a subcommand.
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	exitOK       = 0
+	exitError    = 1
+	exitChanges  = 2 // plan -detailed-exitcode found changes
+	exitFindings = 2 // lint found problems
+)
+
+type command struct {
+	name        string
+	synopsis    string
+	help        string
+	run         func(args []string, stdout, stderr io.Writer) int
+	subcommands []command
+}
+
+var commands = []command{
+	{"plan", "plan -f FILE [-detailed-exitcode]", "show what apply would change", onHost(runPlan), nil},
+	{"apply", "apply -f FILE [-y]", "bring the firewall to the config in FILE", onHost(runApply), nil},
+	{"export", "export [-f FILE] [-o FILE] [flags]", "write the firewall, or the config in -f FILE, as yaml, json, ufw, nft, iptables or ip6tables (-format)", runExport, nil},
+	{name: "rules", subcommands: []command{
+		{"list", "rules list [-json]", "list the rules in the order ufw numbers them", onHost(runRulesList), nil},
+		{"add", "rules add [flags]", "add a rule, see rules add -h", onHost(runRulesAdd), nil},
+		{"delete", "rules delete -match TEXT|-number N", "delete rules, -all when several match, -twins for IPv6 twins", onHost(runRulesDelete), nil},
+	}},
+	{name: "defaults", subcommands: []command{
+		{"set", "defaults set [flags]", "set the incoming, outgoing and routed policies, see defaults set -h", onHost(runDefaultsSet), nil},
+	}},
+	{name: "profiles", subcommands: []command{
+		{"install", "profiles install [-allow] NAME", "install one of the built-in application profiles", onHost(runProfilesInstall), nil},
+	}},
+	{name: "backup", subcommands: []command{
+		{"create", "backup create", "back up the rules", onHost(runBackupCreate), nil},
+		{"list", "backup list", "list the backups, the newest first", onHost(runBackupList), nil},
+		{"restore", "backup restore FILE", "restore a backup, the current rules are backed up first", onHost(runBackupRestore), nil},
+	}},
+	{"lint", "lint [FILE]", "report shadowed, duplicate and conflicting rules of the firewall or a config, exit 2 on findings", runLint, nil},
+	{"serve", "serve [-listen ADDR] [-fake]", "serve the JSON API on unix:/run/fwtui.sock, changes need a token", runServe, nil},
+	{"exporter", "exporter [-listen ADDR]", "serve Prometheus metrics on 127.0.0.1:9879", onHost(runExporter), nil},
+}
+
+// Run executes the subcommand named by the first argument and returns the exit code.
+func Run(args []string) int {
+	return run(args, os.Stdout, os.Stderr)
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		for _, c := range commands {
+			if c.name != args[0] {
+				continue
+			}
+			if c.subcommands == nil {
+				return c.run(args[1:], stdout, stderr)
+			}
+			return runSubcommand(c, args[1:], stdout, stderr)
+		}
+		if args[0] != "help" && args[0] != "-h" && args[0] != "--help" {
+			fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+			usage(stderr)
+			return exitError
+		}
+	}
+	usage(stdout)
+	return exitOK
+}
+
+// CheckHost fails when this host's firewall can't be managed: fwtui needs root and a working ufw.
+func CheckHost() error {
+	if os.Geteuid() != 0 {
+		return errors.New("this action requires root, please run with sudo")
+	}
+	if err := exec.Command("sudo", "ufw", "status").Run(); err != nil {
+		return fmt.Errorf("ufw is not available or sudo failed: %w", err)
+	}
+	return nil
+}
+
+// onHost guards a command that reads or changes this host's firewall. Commands working on files or
+// the fake firewall alone call hostUnavailable themselves when they need the host.
+func onHost(run func(args []string, stdout, stderr io.Writer) int) func(args []string, stdout, stderr io.Writer) int {
+	return func(args []string, stdout, stderr io.Writer) int {
+		if hostUnavailable(stderr) {
+			return exitError
+		}
+		return run(args, stdout, stderr)
+	}
+}
+
+func hostUnavailable(stderr io.Writer) bool {
+	if err := CheckHost(); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return true
+	}
+	return false
+}
+
+func runSubcommand(c command, args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		for _, sub := range c.subcommands {
+			if sub.name == args[0] {
+				return sub.run(args[1:], stdout, stderr)
+			}
+		}
+		fmt.Fprintf(stderr, "unknown command %q\n\n", c.name+" "+args[0])
+	}
+	usage(stderr)
+	return exitError
+}
+
+func usage(w io.Writer) {
+	lines := []string{"Usage: fwtui [command]", "", "Without a command fwtui starts the terminal UI.", "", "Commands:"}
+	for _, c := range commands {
+		for _, sub := range append([]command{c}, c.subcommands...) {
+			if sub.run != nil {
+				lines = append(lines, fmt.Sprintf("  fwtui %-35s %s", sub.synopsis, sub.help))
+			}
+		}
+	}
+	lines = append(lines, "", "Exit codes: 0 on success, 1 on errors, 2 when plan -detailed-exitcode finds changes or lint finds problems.")
+	fmt.Fprintln(w, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"fwtui/domain/declarative"
+	"fwtui/modules/shared/state"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+func runPlan(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("plan", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	file := flags.String("f", "", "firewall config file (YAML)")
+	detailed := flags.Bool("detailed-exitcode", false, "exit with 2 when there are changes")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	plan, err := computePlan(*file)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+	fmt.Fprintln(stdout, plan)
+	if *detailed && !plan.Empty() {
+		return exitChanges
+	}
+	return exitOK
+}
+
+func runApply(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("apply", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	file := flags.String("f", "", "firewall config file (YAML)")
+	yes := flags.Bool("y", false, "apply without asking")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	plan, err := computePlan(*file)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+	fmt.Fprintln(stdout, plan)
+	if plan.Empty() {
+		return exitOK
+	}
+
+	if !*yes {
+		if !isTerminal(os.Stdin) {
+			fmt.Fprintln(stderr, "Error: not a terminal, pass -y to apply without asking")
+			return exitError
+		}
+		if !confirm(os.Stdin, stdout, "\nApply these changes?") {
+			fmt.Fprintln(stdout, "Apply cancelled.")
+			return exitOK
+		}
+	}
+
+	output, err := declarative.Apply(plan)
+	fmt.Fprintln(stdout, output)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func runExport(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	config := flags.String("f", "", "translate the firewall config in FILE instead of this host's firewall")
+	file := flags.String("o", "", "write to FILE instead of stdout")
+	format := flags.String("format", "", "yaml, json, ufw, nft, iptables or ip6tables, by default taken from the file extension")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if *format == "" {
+		*format = declarative.FormatOf(*file)
+	}
+	if *config == "" && hostUnavailable(stderr) {
+		return exitError
+	}
+
+	live, err := exportSource(*config)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+	data, err := declarative.ExportAs(live, *format)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+
+	if *file == "" {
+		stdout.Write(data)
+		return exitOK
+	}
+	if err := os.WriteFile(*file, data, lo.Ternary(*format == declarative.FormatScript, os.FileMode(0700), 0600)); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+	fmt.Fprintln(stdout, "Exported to", *file)
+	return exitOK
+}
+
+// exportSource is the firewall a config file describes, or this host's firewall without one.
+func exportSource(config string) (declarative.Live, error) {
+	if config == "" {
+		return state.Load().Live()
+	}
+	return desiredFirewall(config)
+}
+
+func desiredFirewall(path string) (declarative.Live, error) {
+	config, err := declarative.Load(path)
+	if err != nil {
+		return declarative.Live{}, err
+	}
+	return declarative.Desired(config)
+}
+
+func computePlan(file string) (declarative.Plan, error) {
+	if file == "" {
+		return declarative.Plan{}, errors.New("no config file, pass it with -f")
+	}
+	config, err := declarative.Load(file)
+	if err != nil {
+		return declarative.Plan{}, err
+	}
+	live, err := state.Load().Live()
+	if err != nil {
+		return declarative.Plan{}, err
+	}
+	return declarative.Compute(config, live)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func confirm(in io.Reader, out io.Writer, prompt string) bool {
+	fmt.Fprintf(out, "%s [y/N] ", prompt)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
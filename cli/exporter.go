@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"fwtui/exporter"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+func runExporter(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("exporter", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	listen := flags.String("listen", "127.0.0.1:9879", "address to serve /metrics on")
+	window := flags.Duration("log-window", 5*time.Minute, "window the blocked log rate is averaged over")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if *window < time.Minute {
+		fmt.Fprintln(stderr, "Error: -log-window is at least 1m")
+		return exitError
+	}
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", exporter.Handler(*window))
+	fmt.Fprintf(stdout, "Serving metrics on http://%s/metrics\n", listener.Addr())
+	return serveUntilSignal(&http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}, listener, stderr)
+}
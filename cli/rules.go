@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"fwtui/domain/hostnames"
+	"fwtui/domain/ufw"
+	"fwtui/modules/createrule"
+	"fwtui/utils/oscmd"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+func runRulesList(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("rules list", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	asJSON := flags.Bool("json", false, "write the rules as JSON")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	rules, err := ufw.ReadRules()
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+
+	if !*asJSON {
+		for _, rule := range rules {
+			fmt.Fprintln(stdout, rule.Line)
+		}
+		return exitOK
+	}
+	list := lo.Map(rules, func(r ufw.Rule, _ int) ufw.RuleJSON { return r.JSON() })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+	fmt.Fprintln(stdout, string(data))
+	return exitOK
+}
+
+func runRulesAdd(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("rules add", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	action := flags.String("action", "allow", "allow, deny, reject or limit")
+	direction := flags.String("direction", "in", "in, out or route")
+	proto := flags.String("proto", "tcp/udp", "tcp, udp or tcp/udp")
+	port := flags.String("port", "", "port, range like 6000:6010 or service name")
+	from := flags.String("from", "", "source address, host name or @group of an incoming or routed rule")
+	to := flags.String("to", "", "destination address, host name or @group of an outgoing or routed rule")
+	iface := flags.String("interface", "", "interface of an incoming or routed rule")
+	comment := flags.String("comment", "", "rule comment")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(stderr, "Error: unexpected arguments %s\n", strings.Join(flags.Args(), " "))
+		return exitError
+	}
+
+	commands, err := createrule.Commands(createrule.Prefill{
+		Port:          *port,
+		Protocol:      createrule.Protocol(*proto),
+		Action:        createrule.Action(*action),
+		Direction:     createrule.Direction(*direction),
+		SourceIP:      *from,
+		DestinationIP: *to,
+		Interface:     *iface,
+		Comment:       *comment,
+	}, hostnames.DefaultResolver)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+
+	if !backupFirst(stdout, stderr) {
+		return exitError
+	}
+	code := exitOK
+	for _, command := range commands {
+		fmt.Fprintln(stdout, command)
+		if report(oscmd.RunCommand(command), stdout, stderr) != exitOK {
+			code = exitError
+		}
+	}
+	return code
+}
+
+func runRulesDelete(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("rules delete", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	match := flags.String("match", "", "delete the rules whose description or comment contains TEXT")
+	number := flags.Int("number", 0, "delete the rule with this number of ufw status numbered")
+	all := flags.Bool("all", false, "delete all rules -match finds, not only a single one")
+	twins := flags.Bool("twins", false, "delete the IPv4/IPv6 twins of the rules as well")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if (*match == "") == (*number == 0) {
+		fmt.Fprintln(stderr, "Error: pass either -match or -number")
+		return exitError
+	}
+
+	rules, err := ufw.ReadRules()
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+	selected := lo.Filter(rules, func(r ufw.Rule, _ int) bool {
+		if *number != 0 {
+			return r.Number == *number
+		}
+		text := strings.ToLower(*match)
+		return strings.Contains(strings.ToLower(r.Summary()), text) || strings.Contains(strings.ToLower(r.Comment), text)
+	})
+
+	switch {
+	case len(selected) == 0:
+		fmt.Fprintln(stderr, "Error: no rule matches")
+		return exitError
+	case len(selected) > 1 && !*all:
+		fmt.Fprintf(stderr, "Error: %d rules match, pass -all to delete them all:\n", len(selected))
+		for _, rule := range selected {
+			fmt.Fprintln(stderr, "  "+rule.Line)
+		}
+		return exitError
+	}
+
+	numbers := lo.Map(selected, func(r ufw.Rule, _ int) int { return r.Number })
+	if *twins {
+		pairs := ufw.IPv6Twins(rules)
+		for _, n := range numbers {
+			if twin, ok := pairs[n]; ok && !lo.Contains(numbers, twin) {
+				numbers = append(numbers, twin)
+			}
+		}
+	}
+	sort.Ints(numbers)
+
+	if !backupFirst(stdout, stderr) {
+		return exitError
+	}
+	for _, rule := range rules {
+		if lo.Contains(numbers, rule.Number) {
+			fmt.Fprintln(stdout, "Deleting "+rule.Line)
+		}
+	}
+	return report(ufw.DeleteRulesByNumber(numbers), stdout, stderr)
+}
+
+// backupFirst backs up the rules before a change, like the terminal UI does when it starts. Without
+// a backup nothing is changed.
+func backupFirst(stdout, stderr io.Writer) bool {
+	path, err := ufw.Backup()
+	if err != nil {
+		fmt.Fprintln(stderr, "Error: backup failed, nothing changed:", err)
+		return false
+	}
+	fmt.Fprintln(stdout, "Backup: "+path)
+	return true
+}
+
+// report prints the output of ufw commands and turns failures into the exit code.
+func report(output string, stdout, stderr io.Writer) int {
+	output = strings.TrimSpace(output)
+	if strings.HasPrefix(output, "Error:") || strings.Contains(output, "\nError:") {
+		fmt.Fprintln(stderr, output)
+		return exitError
+	}
+	if output != "" {
+		fmt.Fprintln(stdout, output)
+	}
+	return exitOK
+}
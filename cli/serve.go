@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"fwtui/server"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const tokenEnv = "FWTUI_TOKEN"
+
+func runServe(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	listen := flags.String("listen", "unix:/run/fwtui.sock", "unix:PATH or a TCP address like 127.0.0.1:8080")
+	tokenFile := flags.String("token-file", "", "file holding the bearer token for changes, or set "+tokenEnv)
+	fake := flags.Bool("fake", false, "serve an in-memory firewall instead of this host's, for trying the API")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if !*fake && hostUnavailable(stderr) {
+		return exitError
+	}
+
+	token := os.Getenv(tokenEnv)
+	if *tokenFile != "" {
+		content, err := os.ReadFile(*tokenFile)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			return exitError
+		}
+		token = strings.TrimSpace(string(content))
+	}
+
+	var backend server.Backend = server.Host{}
+	if *fake {
+		backend = server.NewFake()
+	}
+	listener, err := server.Listen(*listen)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+
+	fmt.Fprintf(stdout, "Serving the API on %s", *listen)
+	if token == "" {
+		fmt.Fprint(stdout, ", read-only without a token")
+	}
+	fmt.Fprintln(stdout)
+	handler := server.New(backend, token).Handler()
+	return serveUntilSignal(&http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}, listener, stderr)
+}
+
+// serveUntilSignal serves until SIGINT or SIGTERM and then lets running requests finish.
+func serveUntilSignal(httpServer *http.Server, listener net.Listener, stderr io.Writer) int {
+	done := make(chan error, 1)
+	go func() { done <- httpServer.Serve(listener) }()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-done:
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	case <-signals:
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+	return exitOK
+}
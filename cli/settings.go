@@ -0,0 +1,226 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"fwtui/domain/entity"
+	"fwtui/domain/ufw"
+	"fwtui/modules/defaultpolicies"
+	"io"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+func runDefaultsSet(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("defaults set", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	incoming := flags.String("incoming", "", "allow, deny or reject")
+	outgoing := flags.String("outgoing", "", "allow, deny or reject")
+	routed := flags.String("routed", "", "allow, deny, reject or disabled to turn IP forwarding off")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	policies := []string{"allow", "deny", "reject"}
+	for _, policy := range []struct{ name, value string }{{"incoming", *incoming}, {"outgoing", *outgoing}} {
+		if policy.value != "" && !lo.Contains(policies, policy.value) {
+			fmt.Fprintf(stderr, "Error: invalid %s policy %q, use allow, deny or reject\n", policy.name, policy.value)
+			return exitError
+		}
+	}
+	if *routed != "" && *routed != "disabled" && !lo.Contains(policies, *routed) {
+		fmt.Fprintf(stderr, "Error: invalid routed policy %q, use allow, deny, reject or disabled\n", *routed)
+		return exitError
+	}
+	if *incoming == "" && *outgoing == "" && *routed == "" {
+		fmt.Fprintln(stderr, "Error: pass at least one of -incoming, -outgoing and -routed")
+		return exitError
+	}
+
+	res := defaultpolicies.ParseUfwDefaults(ufw.StatusVerbose())
+	if res.IsErr() {
+		fmt.Fprintln(stderr, "Error: reading the default policies (is ufw enabled?):", res.Err())
+		return exitError
+	}
+	current := res.Value()
+
+	// like the default policies view, only the changed policies are written and routing follows
+	// IP forwarding
+	var commands []func() string
+	for _, policy := range []struct{ direction, current, selected string }{
+		{"incoming", current.Incoming, *incoming},
+		{"outgoing", current.Outgoing, *outgoing},
+	} {
+		if policy.selected != "" && policy.selected != policy.current {
+			commands = append(commands, func() string {
+				return ufw.SetDefaultPolicy(policy.direction, policy.selected)
+			})
+		}
+	}
+	switch {
+	case *routed == "" || *routed == current.Routed:
+	case *routed == "disabled":
+		commands = append(commands, func() string {
+			return ufw.SetForwarding(ufw.Forwarding{})
+		})
+	default:
+		if current.Routed == "disabled" {
+			commands = append(commands, func() string {
+				return ufw.SetForwarding(ufw.Forwarding{V4: true, V6: true})
+			})
+		}
+		commands = append(commands, func() string {
+			return ufw.SetDefaultPolicy("routed", *routed)
+		})
+	}
+
+	if len(commands) == 0 {
+		fmt.Fprintln(stdout, "Nothing to change, the default policies are set already.")
+		return exitOK
+	}
+	if !backupFirst(stdout, stderr) {
+		return exitError
+	}
+	for _, command := range commands {
+		if code := report(command(), stdout, stderr); code != exitOK {
+			return code
+		}
+	}
+	return exitOK
+}
+
+func runProfilesInstall(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("profiles install", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	allow := flags.Bool("allow", false, "allow the profile once it is installed")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "Error: pass the name of one profile, e.g. profiles install OpenSSH")
+		return exitError
+	}
+	name := flags.Arg(0)
+
+	predefined := entity.PredefinedProfiles()
+	profile, found := lo.Find(predefined, func(p entity.UFWProfile) bool {
+		return strings.EqualFold(p.Name, name)
+	})
+	if !found {
+		names := lo.Map(predefined, func(p entity.UFWProfile, _ int) string { return p.Name })
+		fmt.Fprintf(stderr, "Error: unknown profile %q, known profiles: %s\n", name, strings.Join(names, ", "))
+		return exitError
+	}
+	installed, err := entity.LoadInstalledProfiles()
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+	profile.Installed = lo.ContainsBy(installed, func(p entity.UFWProfile) bool { return p.Name == profile.Name })
+
+	if !profile.Installed {
+		res := entity.CreateProfile(profile)
+		if res.IsErr() {
+			fmt.Fprintln(stderr, "Error:", res.Err())
+			return exitError
+		}
+		fmt.Fprintln(stdout, res.Value())
+	} else {
+		fmt.Fprintf(stdout, "%s is installed already\n", profile.Name)
+	}
+
+	if !*allow {
+		return exitOK
+	}
+	if !backupFirst(stdout, stderr) {
+		return exitError
+	}
+	return report(ufw.AllowProfile(profile.Name), stdout, stderr)
+}
+
+func runBackupCreate(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		fmt.Fprintf(stderr, "Error: unexpected arguments %s\n", strings.Join(args, " "))
+		return exitError
+	}
+	path, err := ufw.Backup()
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+	fmt.Fprintln(stdout, path)
+	return exitOK
+}
+
+func runBackupList(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		fmt.Fprintf(stderr, "Error: unexpected arguments %s\n", strings.Join(args, " "))
+		return exitError
+	}
+	backups, err := ufw.ListBackups()
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+	for _, backup := range backups {
+		fmt.Fprintf(stdout, "%s  %s\n", backup.Created.Format("2006-01-02 15:04:05"), backup.Path)
+	}
+	return exitOK
+}
+
+func runBackupRestore(args []string, stdout, stderr io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintln(stderr, "Error: pass the backup to restore, see backup list")
+		return exitError
+	}
+	return report(ufw.RestoreBackup(args[0]), stdout, stderr)
+}
+
+func runLint(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 1 {
+		fmt.Fprintf(stderr, "Error: unexpected arguments %s\n", strings.Join(args[1:], " "))
+		return exitError
+	}
+	if len(args) == 0 && hostUnavailable(stderr) {
+		return exitError
+	}
+	rules, ipv6, err := lintedRules(args)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+
+	findings := ufw.Analyze(rules, ipv6)
+	if len(findings) == 0 {
+		fmt.Fprintln(stdout, "No findings, the rules look consistent")
+		return exitOK
+	}
+	byNumber := lo.KeyBy(rules, func(r ufw.Rule) int { return r.Number })
+	for _, f := range findings {
+		fmt.Fprintf(stdout, "[%s] %s\n", f.Kind, f.Message)
+		for _, n := range f.Rules {
+			fmt.Fprintln(stdout, "  "+byNumber[n].Line)
+		}
+	}
+	return exitFindings
+}
+
+// lintedRules are the rules of the config file in args, or this host's rules without one, and
+// whether IPv6 is on for them.
+func lintedRules(args []string) ([]ufw.Rule, bool, error) {
+	if len(args) == 0 {
+		rules, err := ufw.ReadRules()
+		if err != nil {
+			return nil, false, err
+		}
+		// ufw enables IPv6 unless /etc/default/ufw says otherwise
+		ipv6, err := ufw.ReadIPv6Setting()
+		return rules, ipv6 || err != nil, nil
+	}
+	live, err := desiredFirewall(args[0])
+	if err != nil {
+		return nil, false, err
+	}
+	return live.Rules, live.IPv6, nil
+}
@@ -0,0 +1,156 @@
+package addrgroups
+
+import (
+	"errors"
+	"fmt"
+	"fwtui/domain/ufw"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// registryPath holds one group per line, e.g. "office = 203.0.113.0/24, 198.51.100.7".
+const registryPath = "/etc/fwtui/groups.conf"
+
+// commentPrefix marks the rules created for a group member.
+const commentPrefix = "group:"
+
+// Prefix introduces a group name in an address field, e.g. "@office".
+const Prefix = "@"
+
+type Group struct {
+	Name    string
+	Members []string // addresses and networks
+}
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+
+func (g Group) Validate() error {
+	if !namePattern.MatchString(g.Name) {
+		return fmt.Errorf("invalid group name: %q, use letters, digits, - and _", g.Name)
+	}
+	if len(g.Members) == 0 {
+		return fmt.Errorf("group %s has no members", g.Name)
+	}
+	for _, member := range g.Members {
+		if _, err := netip.ParseAddr(member); err == nil {
+			continue
+		}
+		if _, err := netip.ParsePrefix(member); err == nil {
+			continue
+		}
+		return fmt.Errorf("invalid member of group %s: %s", g.Name, member)
+	}
+	return nil
+}
+
+func Load() ([]Group, error) {
+	content, err := os.ReadFile(registryPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", registryPath, err)
+	}
+	return Parse(string(content))
+}
+
+func Save(groups []Group) error {
+	if err := os.MkdirAll(filepath.Dir(registryPath), 0755); err != nil {
+		return fmt.Errorf("creating %s: %w", filepath.Dir(registryPath), err)
+	}
+	if err := os.WriteFile(registryPath, []byte(Format(groups)), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", registryPath, err)
+	}
+	return nil
+}
+
+// Find returns the group named in an address field like "@office".
+func Find(groups []Group, field string) (Group, bool) {
+	name := strings.TrimPrefix(strings.TrimSpace(field), Prefix)
+	for _, g := range groups {
+		if g.Name == name {
+			return g, true
+		}
+	}
+	return Group{}, false
+}
+
+func Parse(content string) ([]Group, error) {
+	var groups []Group
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, members, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected \"name = address, ...\"", i+1)
+		}
+		group := Group{Name: strings.TrimSpace(name), Members: ParseMembers(members)}
+		if err := group.Validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// ParseMembers splits a comma separated member list.
+func ParseMembers(members string) []string {
+	var list []string
+	for _, member := range strings.Split(members, ",") {
+		if member = strings.TrimSpace(member); member != "" {
+			list = append(list, member)
+		}
+	}
+	return list
+}
+
+func Format(groups []Group) string {
+	sorted := append([]Group(nil), groups...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	var b strings.Builder
+	b.WriteString("# fwtui address groups, one per line: name = address, network, ...\n")
+	for _, g := range sorted {
+		fmt.Fprintf(&b, "%s = %s\n", g.Name, strings.Join(g.Members, ", "))
+	}
+	return b.String()
+}
+
+// Comment builds the comment of a rule created for a group member, keeping the comment the user
+// typed.
+func Comment(name, comment string) string {
+	if comment == "" {
+		return commentPrefix + name
+	}
+	return commentPrefix + name + " " + comment
+}
+
+// FromComment returns the group recorded in a rule comment.
+func FromComment(comment string) (string, bool) {
+	if !strings.HasPrefix(comment, commentPrefix) {
+		return "", false
+	}
+	name, _, _ := strings.Cut(strings.TrimPrefix(comment, commentPrefix), " ")
+	return name, name != ""
+}
+
+// Sync compares the rules created for the group with its members and returns the rules to add and
+// to delete.
+func Sync(rules []ufw.Rule, group Group) (add []ufw.Rule, remove []ufw.Rule) {
+	for _, set := range ufw.GroupRuleSets(rules, FromComment) {
+		if set.Tag != group.Name {
+			continue
+		}
+		setAdd, setRemove := set.Diff(group.Members)
+		add = append(add, setAdd...)
+		remove = append(remove, setRemove...)
+	}
+	return add, remove
+}
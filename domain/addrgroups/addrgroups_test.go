@@ -0,0 +1,68 @@
+package addrgroups
+
+import (
+	"fwtui/domain/fakeufw"
+	"fwtui/domain/ufw"
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+)
+
+func TestSyncReplacesRulesInPlace(t *testing.T) {
+	fw := fakeufw.New()
+	for _, command := range []string{
+		"sudo ufw allow from 203.0.113.0/24 to any port 22 proto tcp comment 'group:office'",
+		"sudo ufw allow from 198.51.100.7 to any port 22 proto tcp comment 'group:office'",
+		"sudo ufw allow from 2001:db8::7 to any port 22 proto tcp comment 'group:office'",
+		"sudo ufw deny 22/tcp",
+		"sudo ufw allow 80/tcp",
+	} {
+		if output := fw.Run(command); strings.HasPrefix(output, "Error:") {
+			t.Fatalf("%s: %s", command, output)
+		}
+	}
+
+	// the subnet is written with host bits and the IPv6 address in upper case, both stay as they are
+	group := Group{Name: "office", Members: []string{"203.0.113.9/24", "198.51.100.8", "2001:DB8::7", "2001:db8::8"}}
+	add, remove := Sync(fw.Rules(), group)
+	if got := lo.Map(remove, func(r ufw.Rule, _ int) string { return r.SetAddr() }); !lo.ElementsMatch(got, []string{"198.51.100.7"}) {
+		t.Errorf("remove = %v, want [198.51.100.7]", got)
+	}
+	if got := lo.Map(add, func(r ufw.Rule, _ int) string { return r.SetAddr() }); !lo.ElementsMatch(got, []string{"198.51.100.8", "2001:db8::8"}) {
+		t.Errorf("add = %v, want [198.51.100.8 2001:db8::8]", got)
+	}
+
+	for _, command := range ufw.RuleChangeCommands(fw.Rules(), remove, add) {
+		if output := fw.Run(command); strings.HasPrefix(output, "Error:") {
+			t.Fatalf("%s: %s", command, output)
+		}
+	}
+
+	// the replacements keep their place ahead of the deny rule
+	want := []string{
+		"allow in from 203.0.113.0/24 to any port 22 proto tcp",
+		"allow in from 198.51.100.8 to any port 22 proto tcp",
+		"deny in from any to any port 22 proto tcp",
+		"allow in from any to any port 80 proto tcp",
+		"allow in from 2001:db8::7 to any port 22 proto tcp (v6)",
+		"allow in from 2001:db8::8 to any port 22 proto tcp (v6)",
+		"deny in from any to any port 22 proto tcp (v6)",
+		"allow in from any to any port 80 proto tcp (v6)",
+	}
+	got := lo.Map(fw.Rules(), func(r ufw.Rule, _ int) string { return r.Summary() })
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("rules after the sync:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSyncUpToDate(t *testing.T) {
+	fw := fakeufw.New()
+	fw.Run("sudo ufw allow from 10.0.0.0/8 comment 'group:lan'")
+	fw.Run("sudo ufw allow from fd00::/8 comment 'group:lan'")
+
+	add, remove := Sync(fw.Rules(), Group{Name: "lan", Members: []string{"10.1.2.3/8", "FD00:0::1/8"}})
+	if len(add) != 0 || len(remove) != 0 {
+		t.Errorf("Sync() = %v, %v, want no changes", add, remove)
+	}
+}
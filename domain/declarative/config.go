@@ -0,0 +1,333 @@
+package declarative
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"fwtui/domain/services"
+	"fwtui/domain/ufw"
+	"io"
+	"net/netip"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaVersion is the version of the file format. Files without a version are read as the
+// current one.
+const SchemaVersion = 1
+
+// Config is the desired firewall as written in a firewall.yaml or an export. Sections left out of
+// the file are left alone, the rules are the complete ordered ruleset once the section is present.
+type Config struct {
+	Version  int        `yaml:"version,omitempty" json:"version,omitempty"`
+	Defaults *Defaults  `yaml:"defaults,omitempty" json:"defaults,omitempty"`
+	Logging  string     `yaml:"logging,omitempty" json:"logging,omitempty"`
+	Profiles []Profile  `yaml:"profiles,omitempty" json:"profiles,omitempty"`
+	Rules    []RuleSpec `yaml:"rules" json:"rules"`
+
+	hasRules bool
+}
+
+type Defaults struct {
+	Incoming string `yaml:"incoming,omitempty" json:"incoming,omitempty"`
+	Outgoing string `yaml:"outgoing,omitempty" json:"outgoing,omitempty"`
+	Routed   string `yaml:"routed,omitempty" json:"routed,omitempty"` // allow, deny, reject or disabled to turn IP forwarding off
+}
+
+// Profile is an application profile that has to be installed. The ports can be left out for the
+// predefined profiles of the profiles view.
+type Profile struct {
+	Name  string   `yaml:"name,omitempty" json:"name,omitempty"`
+	Title string   `yaml:"title,omitempty" json:"title,omitempty"`
+	Ports []string `yaml:"ports,omitempty" json:"ports,omitempty"`
+}
+
+// RuleSpec is one rule of the file. Without a family a rule that names no address becomes an IPv4
+// rule and its IPv6 twin, like `ufw allow` does.
+type RuleSpec struct {
+	Action       string `yaml:"action,omitempty" json:"action,omitempty"`       // allow, deny, reject or limit
+	Direction    string `yaml:"direction,omitempty" json:"direction,omitempty"` // in (default) or out
+	Route        bool   `yaml:"route,omitempty" json:"route,omitempty"`
+	Interface    string `yaml:"interface,omitempty" json:"interface,omitempty"`         // interface of the direction, the incoming one of a route rule
+	InterfaceOut string `yaml:"interface_out,omitempty" json:"interface_out,omitempty"` // outgoing interface of a route rule
+	Log          string `yaml:"log,omitempty" json:"log,omitempty"`                     // log or log-all
+	Proto        string `yaml:"proto,omitempty" json:"proto,omitempty"`                 // tcp, udp or any
+	From         string `yaml:"from,omitempty" json:"from,omitempty"`
+	FromPort     string `yaml:"from_port,omitempty" json:"from_port,omitempty"`
+	FromApp      string `yaml:"from_app,omitempty" json:"from_app,omitempty"`
+	To           string `yaml:"to,omitempty" json:"to,omitempty"`
+	Port         string `yaml:"port,omitempty" json:"port,omitempty"` // number, range, list or service name
+	App          string `yaml:"app,omitempty" json:"app,omitempty"`
+	Comment      string `yaml:"comment,omitempty" json:"comment,omitempty"`
+	Family       string `yaml:"family,omitempty" json:"family,omitempty"` // v4 or v6 to create the rule for one family only
+}
+
+var portPattern = regexp.MustCompile(`^[0-9]+([:,][0-9]+)*$`)
+
+// Load reads and validates a config file.
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes the YAML or JSON of a config, rejecting unknown keys so that typos don't go
+// unnoticed.
+func Parse(data []byte) (Config, error) {
+	var config Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("parsing config: %w", err)
+	}
+
+	var keys map[string]any
+	if err := yaml.Unmarshal(data, &keys); err == nil {
+		_, config.hasRules = keys["rules"]
+	}
+
+	if config.Version > SchemaVersion {
+		return Config{}, fmt.Errorf("schema version %d is newer than the supported %d, update fwtui", config.Version, SchemaVersion)
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+func (c Config) Validate() error {
+	var problems []string
+
+	if c.Defaults != nil {
+		for _, d := range []struct{ name, value string }{{"incoming", c.Defaults.Incoming}, {"outgoing", c.Defaults.Outgoing}} {
+			if d.value != "" && !oneOf(d.value, "allow", "deny", "reject") {
+				problems = append(problems, fmt.Sprintf("defaults.%s: %q is not allow, deny or reject", d.name, d.value))
+			}
+		}
+		if c.Defaults.Routed != "" && !oneOf(c.Defaults.Routed, "allow", "deny", "reject", "disabled") {
+			problems = append(problems, fmt.Sprintf("defaults.routed: %q is not allow, deny, reject or disabled", c.Defaults.Routed))
+		}
+	}
+
+	if c.Logging != "" && !oneOf(c.Logging, ufw.LoggingLevels...) {
+		problems = append(problems, fmt.Sprintf("logging: unknown level %q", c.Logging))
+	}
+
+	names := map[string]bool{}
+	for i, p := range c.Profiles {
+		switch {
+		case p.Name == "":
+			problems = append(problems, fmt.Sprintf("profiles[%d]: name is missing", i))
+		case names[p.Name]:
+			problems = append(problems, fmt.Sprintf("profiles[%d]: %s is listed twice", i, p.Name))
+		}
+		names[p.Name] = true
+	}
+
+	seen := map[string]int{}
+	for i, spec := range c.Rules {
+		rules, err := spec.Expand(true)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("rules[%d]: %s", i, err))
+			continue
+		}
+		for _, rule := range rules {
+			key := rule.ExactArgs()
+			if first, dup := seen[key]; dup {
+				problems = append(problems, fmt.Sprintf("rules[%d]: duplicates rules[%d]", i, first))
+				break
+			}
+			seen[key] = i
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// Expand turns the spec into the ufw rules it stands for, the IPv4 rule before its IPv6 twin. The
+// twin is left out when IPv6 is off.
+func (s RuleSpec) Expand(ipv6 bool) ([]ufw.Rule, error) {
+	rule := ufw.Rule{
+		Action:    s.Action,
+		Log:       s.Log,
+		Route:     s.Route,
+		Direction: s.Direction,
+		Protocol:  s.Proto,
+		Comment:   s.Comment,
+		DestApp:   s.App,
+		SrcApp:    s.FromApp,
+		DestPort:  "any",
+		SrcPort:   "any",
+	}
+
+	if !oneOf(rule.Action, "allow", "deny", "reject", "limit") {
+		return nil, fmt.Errorf("action %q is not allow, deny, reject or limit", s.Action)
+	}
+	if !oneOf(rule.Log, "", "log", "log-all") {
+		return nil, fmt.Errorf("log %q is not log or log-all", s.Log)
+	}
+	if rule.Direction == "" {
+		rule.Direction = "in"
+	}
+	if !oneOf(rule.Direction, "in", "out") {
+		return nil, fmt.Errorf("direction %q is not in or out", s.Direction)
+	}
+	if rule.Protocol == "" {
+		rule.Protocol = "any"
+	}
+	if !oneOf(rule.Protocol, "tcp", "udp", "any") {
+		return nil, fmt.Errorf("proto %q is not tcp, udp or any", s.Proto)
+	}
+
+	for _, iface := range []string{s.Interface, s.InterfaceOut} {
+		if iface != "" && !ufw.ValidInterfaceName(iface) {
+			return nil, fmt.Errorf("invalid interface name %q", iface)
+		}
+	}
+	switch {
+	case s.Route:
+		rule.InterfaceIn = s.Interface
+		rule.InterfaceOut = s.InterfaceOut
+		if rule.InterfaceIn == "" && rule.InterfaceOut != "" {
+			rule.Direction = "out"
+		}
+	case s.InterfaceOut != "":
+		return nil, fmt.Errorf("interface_out needs route: true, use direction: out with interface")
+	case rule.Direction == "out":
+		rule.InterfaceOut = s.Interface
+	default:
+		rule.InterfaceIn = s.Interface
+	}
+
+	var err error
+	if rule.DestPort, rule.Protocol, err = resolvePort(s.Port, rule.Protocol, s.App); err != nil {
+		return nil, fmt.Errorf("port: %w", err)
+	}
+	if rule.SrcPort, rule.Protocol, err = resolvePort(s.FromPort, rule.Protocol, s.FromApp); err != nil {
+		return nil, fmt.Errorf("from_port: %w", err)
+	}
+	if (s.App != "" || s.FromApp != "") && s.Proto != "" {
+		return nil, fmt.Errorf("proto can't be combined with an app, the profile defines it")
+	}
+
+	source, sourceFamily, err := normalizeAddr(s.From)
+	if err != nil {
+		return nil, fmt.Errorf("from: %w", err)
+	}
+	dest, destFamily, err := normalizeAddr(s.To)
+	if err != nil {
+		return nil, fmt.Errorf("to: %w", err)
+	}
+	rule.Source, rule.Dest = source, dest
+
+	family := s.Family
+	for _, addrFamily := range []string{sourceFamily, destFamily} {
+		if addrFamily == "" {
+			continue
+		}
+		if family != "" && family != addrFamily {
+			return nil, fmt.Errorf("addresses and family don't agree on IPv4 or IPv6")
+		}
+		family = addrFamily
+	}
+
+	switch family {
+	case "":
+		if !ipv6 {
+			return []ufw.Rule{rule}, nil
+		}
+		twin := rule
+		twin.V6 = true
+		return []ufw.Rule{rule, twin}, nil
+	case "v4":
+		return []ufw.Rule{rule}, nil
+	case "v6":
+		rule.V6 = true
+		return []ufw.Rule{rule}, nil
+	default:
+		return nil, fmt.Errorf("family %q is not v4 or v6", s.Family)
+	}
+}
+
+// resolvePort checks a port spec and swaps a service name for its port. A service defined for one
+// protocol only narrows the protocol down to it.
+func resolvePort(port, protocol, app string) (string, string, error) {
+	if port == "" {
+		return "any", protocol, nil
+	}
+	if app != "" {
+		return "", "", fmt.Errorf("a port can't be combined with an app")
+	}
+	if portPattern.MatchString(port) {
+		if strings.ContainsAny(port, ":,") && protocol == "any" {
+			return "", "", fmt.Errorf("port ranges and lists need proto tcp or udp")
+		}
+		return port, protocol, nil
+	}
+
+	entries := services.Default().Lookup(port)
+	if len(entries) == 0 {
+		return "", "", fmt.Errorf("unknown service %q", port)
+	}
+	for _, entry := range entries {
+		if protocol != "any" && entry.Protocol != protocol {
+			continue
+		}
+		if protocol == "any" && len(entries) == 1 {
+			protocol = entry.Protocol
+		}
+		return fmt.Sprint(entry.Port), protocol, nil
+	}
+	return "", "", fmt.Errorf("service %s is not defined for %s", port, protocol)
+}
+
+// normalizeAddr writes an address the way ufw stores it in its rule tuples: networks masked, single
+// hosts without a prefix length and "any" for the whole address space.
+func normalizeAddr(addr string) (normalized string, family string, err error) {
+	if addr == "" || addr == "any" {
+		return "any", "", nil
+	}
+
+	var prefix netip.Prefix
+	if strings.Contains(addr, "/") {
+		prefix, err = netip.ParsePrefix(addr)
+	} else {
+		var ip netip.Addr
+		ip, err = netip.ParseAddr(addr)
+		prefix = netip.PrefixFrom(ip, ip.BitLen())
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("invalid address %q", addr)
+	}
+
+	prefix = prefix.Masked()
+	family = "v4"
+	if prefix.Addr().Is6() {
+		family = "v6"
+	}
+	switch {
+	case prefix.Bits() == 0:
+		return "any", family, nil
+	case prefix.IsSingleIP():
+		return prefix.Addr().String(), family, nil
+	default:
+		return prefix.String(), family, nil
+	}
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+package declarative
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"fwtui/domain/entity"
+	"fwtui/domain/ufw"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// Export describes the live firewall as a config. Applying it on the same host plans nothing: IPv4
+// rules and their IPv6 twins become one rule, everything else keeps its family.
+func Export(live Live) Config {
+	config := Config{
+		Version:  SchemaVersion,
+		Defaults: &Defaults{Incoming: live.Incoming, Outgoing: live.Outgoing, Routed: live.Routed},
+		Logging:  live.Logging,
+		Profiles: profilesOf(live.Profiles),
+		Rules:    []RuleSpec{},
+		hasRules: true,
+	}
+
+	for _, fr := range mergeFamilies(live) {
+		config.Rules = append(config.Rules, specFor(fr.rule, fr.family))
+	}
+	return config
+}
+
+type familyRule struct {
+	rule   ufw.Rule
+	family string // empty for an IPv4 rule standing for its IPv6 twin as well
+}
+
+// mergeFamilies walks the IPv4 and IPv6 rules side by side so that each family keeps its order. A
+// twin pair only becomes one rule when both sit at the head of their family, otherwise the rules
+// are written separately.
+func mergeFamilies(live Live) []familyRule {
+	var v4, v6 []ufw.Rule
+	for _, rule := range live.Rules {
+		if rule.V6 {
+			v6 = append(v6, rule)
+		} else {
+			v4 = append(v4, rule)
+		}
+	}
+	twins := ufw.IPv6Twins(live.Rules)
+
+	var merged []familyRule
+	i, j := 0, 0
+	for i < len(v4) || j < len(v6) {
+		switch {
+		case j < len(v6) && !paired(twins, v6[j]):
+			merged = append(merged, familyRule{v6[j], "v6"})
+			j++
+		case i < len(v4) && !paired(twins, v4[i]):
+			merged = append(merged, familyRule{v4[i], familyOf(live.IPv6)})
+			i++
+		case i < len(v4) && j < len(v6) && twins[v4[i].Number] == v6[j].Number:
+			merged = append(merged, familyRule{v4[i], ""})
+			i++
+			j++
+		case i < len(v4):
+			delete(twins, twins[v4[i].Number])
+			delete(twins, v4[i].Number)
+			merged = append(merged, familyRule{v4[i], "v4"})
+			i++
+		default:
+			merged = append(merged, familyRule{v6[j], "v6"})
+			j++
+		}
+	}
+	return merged
+}
+
+func paired(twins map[int]int, rule ufw.Rule) bool {
+	_, ok := twins[rule.Number]
+	return ok
+}
+
+// familyOf names the family of an IPv4 rule without a twin. With IPv6 off no twin is expected, a
+// config written then gets the twin once IPv6 is turned on.
+func familyOf(ipv6 bool) string {
+	if !ipv6 {
+		return ""
+	}
+	return "v4"
+}
+
+func specFor(rule ufw.Rule, family string) RuleSpec {
+	spec := RuleSpec{
+		Action:  rule.Action,
+		Route:   rule.Route,
+		Log:     rule.Log,
+		FromApp: rule.SrcApp,
+		App:     rule.DestApp,
+		Comment: rule.Comment,
+		Family:  family,
+	}
+	if rule.Route {
+		spec.Interface = rule.InterfaceIn
+		spec.InterfaceOut = rule.InterfaceOut
+	} else {
+		spec.Interface = rule.Interface()
+		if rule.Direction != "in" {
+			spec.Direction = rule.Direction
+		}
+	}
+	if rule.DestApp == "" && rule.SrcApp == "" {
+		spec.Proto = exportValue(rule.Protocol)
+		spec.Port = exportValue(rule.DestPort)
+		spec.FromPort = exportValue(rule.SrcPort)
+	}
+	spec.From = exportValue(rule.Source)
+	spec.To = exportValue(rule.Dest)
+
+	if spec.From != "" || spec.To != "" {
+		spec.Family = "" // the address tells the family
+	}
+	return spec
+}
+
+func exportValue(value string) string {
+	if value == "any" {
+		return ""
+	}
+	return value
+}
+
+// Marshal writes the config as YAML or JSON.
+func Marshal(config Config, format string) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	case FormatYAML:
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(config); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, use yaml, json, ufw, nft, iptables or ip6tables", format)
+	}
+}
+
+// ExportAs writes the live firewall in one of the export formats. A ufw script is only handed out
+// once replaying it on the fake firewall gives back exactly the live rules.
+// The nft and iptables-save formats are translations for leaving ufw, they say in comments what
+// they leave out.
+func ExportAs(live Live, format string) ([]byte, error) {
+	switch format {
+	case FormatScript:
+		script := Script(live)
+		if err := VerifyScript(script, live); err != nil {
+			return nil, fmt.Errorf("the ufw script would not rebuild this firewall exactly: %w", err)
+		}
+		return []byte(script), nil
+	case FormatNft:
+		return []byte(Nft(live)), nil
+	case FormatIptables, FormatIp6tables:
+		return []byte(IptablesSave(live, format == FormatIp6tables)), nil
+	default:
+		return Marshal(Export(live), format)
+	}
+}
+
+// FormatOf picks the format by the extension of a file: .json for JSON, .sh for a ufw script, .nft
+// for nftables, .v4 and .v6 for iptables-save like iptables-persistent names them and YAML
+// otherwise.
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".sh":
+		return FormatScript
+	case ".nft":
+		return FormatNft
+	case ".v4":
+		return FormatIptables
+	case ".v6":
+		return FormatIp6tables
+	default:
+		return FormatYAML
+	}
+}
+
+// Interfaces lists the interface names the rules of the config use.
+func (c Config) Interfaces() []string {
+	seen := map[string]bool{}
+	for _, spec := range c.Rules {
+		for _, iface := range []string{spec.Interface, spec.InterfaceOut} {
+			if iface != "" {
+				seen[iface] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MapInterfaces renames the interfaces of the rules, for a config taken from a host whose
+// interfaces are named differently. Names missing from the mapping stay as they are.
+func (c Config) MapInterfaces(mapping map[string]string) Config {
+	mapped := c
+	mapped.Rules = make([]RuleSpec, len(c.Rules))
+	for i, spec := range c.Rules {
+		if to, ok := mapping[spec.Interface]; ok && spec.Interface != "" {
+			spec.Interface = to
+		}
+		if to, ok := mapping[spec.InterfaceOut]; ok && spec.InterfaceOut != "" {
+			spec.InterfaceOut = to
+		}
+		mapped.Rules[i] = spec
+	}
+	return mapped
+}
+
+// profilesOf is the profiles part of a config for the installed profiles.
+func profilesOf(installed []entity.UFWProfile) []Profile {
+	profiles := make([]Profile, 0, len(installed))
+	for _, p := range installed {
+		profiles = append(profiles, Profile{Name: p.Name, Title: p.Title, Ports: p.Ports})
+	}
+	return profiles
+}
@@ -0,0 +1,75 @@
+package declarative
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// exportedOn is the export date in the header, the only part of the output that changes by itself.
+var exportedOn = regexp.MustCompile(`exported by fwtui on [0-9: -]+\.`)
+
+// goldenLive is a firewall using what the translations have to handle.
+func goldenLive(t *testing.T) Live {
+	fw := setUp(t, map[string]string{"OpenSSH": "22/tcp", "Nginx Full": "80,443/tcp", "Samba": "137,138/udp|139,445/tcp"}, []string{
+		"sudo ufw default reject incoming",
+		"sudo ufw default allow routed",
+		"sudo ufw logging medium",
+		// app profiles
+		"sudo ufw allow 'Nginx Full'",
+		"sudo ufw allow from 10.0.0.0/8 to any app OpenSSH",
+		"sudo ufw allow from 192.168.1.0/24 to any app Samba",
+		// limit and log
+		"sudo ufw limit 22/tcp",
+		"sudo ufw allow log 25/tcp",
+		"sudo ufw deny log-all from 203.0.113.0/24",
+		// port ranges and port lists
+		"sudo ufw allow 60000:61000/udp",
+		"sudo ufw allow in on eth0 to any port 8000:8080,9000 proto tcp",
+		// route rules with interfaces
+		"sudo ufw route allow in on wg0 out on eth0 from 10.8.0.0/24",
+		"sudo ufw route deny in on eth1 out on eth0 to 198.51.100.0/24 port 3306 proto tcp",
+		// v6 only and outgoing
+		"sudo ufw allow from 2001:db8::/32 to any port 5432 proto tcp",
+		"sudo ufw reject out to 192.0.2.10 port 25 proto tcp",
+		// comments with quotes
+		`sudo ufw allow 8443/tcp comment 'it'\''s the "admin" port'`,
+	})
+	fw.Forwarding = true
+	fw.Enabled = true
+	return liveOf(fw)
+}
+
+func TestExportGolden(t *testing.T) {
+	live := goldenLive(t)
+	for _, tc := range []struct {
+		file string
+		got  string
+	}{
+		{"export.nft", Nft(live)},
+		{"export.rules.v4", IptablesSave(live, false)},
+		{"export.rules.v6", IptablesSave(live, true)},
+		{"export.sh", Script(live)},
+	} {
+		t.Run(tc.file, func(t *testing.T) {
+			got := exportedOn.ReplaceAllString(tc.got, "exported by fwtui on DATE.")
+			path := filepath.Join("testdata", tc.file)
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("%s differs from the golden file, rerun with -update after checking the diff:\n%s", tc.file, got)
+			}
+		})
+	}
+}
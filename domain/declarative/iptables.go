@@ -0,0 +1,215 @@
+package declarative
+
+import (
+	"fmt"
+	"fwtui/domain/ufw"
+	"strings"
+	"time"
+)
+
+// IptablesSave writes one family of the firewall as a standalone filter table for
+// `iptables-restore`, or `ip6tables-restore` when v6 is set. The rules go straight into the built-in
+// chains instead of ufw's own chains.
+func IptablesSave(live Live, v6 bool) string {
+	tool := "iptables"
+	if v6 {
+		tool = "ip6tables"
+	}
+	lines := []string{fmt.Sprintf("# %s-save rules exported by fwtui on %s.", tool, time.Now().Format("2006-01-02 15:04"))}
+	lines = append(lines, lossyNotes(live)...)
+	if v6 && !live.IPv6 {
+		lines = append(lines, "# IPv6 was off in ufw, only the baseline and the default policies are written.")
+	}
+
+	chains := []struct{ name, policy string }{
+		{"INPUT", live.Incoming},
+		{"FORWARD", routedPolicy(live)},
+		{"OUTPUT", live.Outgoing},
+	}
+	lines = append(lines, "*filter")
+	for _, chain := range chains {
+		lines = append(lines, fmt.Sprintf(":%s %s [0:0]", chain.name, iptablesPolicy(chain.policy)))
+	}
+
+	baseline := iptablesBaseline
+	if v6 {
+		baseline = ip6tablesBaseline
+	}
+	rules := translateRules(live)
+	for _, chain := range chains {
+		lines = append(lines, "", fmt.Sprintf("# %s", chain.name))
+		if chain.name == "FORWARD" && live.Routed == "disabled" {
+			lines = append(lines, "# IP forwarding was off, nothing is routed until net.ipv4.ip_forward is turned on")
+		}
+		for _, line := range baseline[chain.name] {
+			lines = append(lines, fmt.Sprintf("-A %s %s", chain.name, line))
+		}
+
+		for _, tr := range rules {
+			if strings.ToUpper(tr.chain) != chain.name || !inFamily(tr, v6, live.IPv6) {
+				continue
+			}
+			lines = append(lines, tr.header())
+			if tr.skip != "" {
+				lines = append(lines, "# left out: "+tr.skip)
+				continue
+			}
+			lines = append(lines, iptablesRule(tr, chain.name, v6)...)
+		}
+
+		if policyLogged(live, chain.policy) {
+			lines = append(lines, fmt.Sprintf(`-A %s -m limit --limit 3/min --limit-burst 10 -j LOG --log-prefix "[UFW BLOCK] "`, chain.name))
+		}
+		if chain.policy == "reject" {
+			lines = append(lines, fmt.Sprintf("-A %s -j REJECT", chain.name))
+		}
+	}
+	lines = append(lines, "COMMIT")
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// inFamily tells whether a rule belongs in the file of a family. A rule standing for its IPv6 twin
+// goes into both files.
+func inFamily(tr translatedRule, v6, ipv6 bool) bool {
+	if !v6 {
+		return !tr.rule.V6
+	}
+	return tr.rule.V6 || (tr.family == "" && ipv6)
+}
+
+// iptablesBaseline and ip6tablesBaseline stand in for ufw's before.rules and before6.rules.
+var iptablesBaseline = map[string][]string{
+	"INPUT": {
+		"-i lo -j ACCEPT",
+		"-m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT",
+		"-m conntrack --ctstate INVALID -j DROP",
+		"-p icmp --icmp-type destination-unreachable -j ACCEPT",
+		"-p icmp --icmp-type time-exceeded -j ACCEPT",
+		"-p icmp --icmp-type parameter-problem -j ACCEPT",
+		"-p icmp --icmp-type echo-request -j ACCEPT",
+		"-p udp --sport 67 --dport 68 -j ACCEPT",
+	},
+	"FORWARD": {
+		"-m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT",
+		"-m conntrack --ctstate INVALID -j DROP",
+	},
+	"OUTPUT": {
+		"-o lo -j ACCEPT",
+		"-m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT",
+	},
+}
+
+var ip6tablesBaseline = map[string][]string{
+	"INPUT": {
+		"-i lo -j ACCEPT",
+		"-m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT",
+		"-m conntrack --ctstate INVALID -j DROP",
+		"-p ipv6-icmp --icmpv6-type destination-unreachable -j ACCEPT",
+		"-p ipv6-icmp --icmpv6-type packet-too-big -j ACCEPT",
+		"-p ipv6-icmp --icmpv6-type time-exceeded -j ACCEPT",
+		"-p ipv6-icmp --icmpv6-type parameter-problem -j ACCEPT",
+		"-p ipv6-icmp --icmpv6-type echo-request -j ACCEPT",
+		"-p ipv6-icmp --icmpv6-type router-advertisement -m hl --hl-eq 255 -j ACCEPT",
+		"-p ipv6-icmp --icmpv6-type neighbour-solicitation -m hl --hl-eq 255 -j ACCEPT",
+		"-p ipv6-icmp --icmpv6-type neighbour-advertisement -m hl --hl-eq 255 -j ACCEPT",
+		"-s fe80::/10 -p udp --sport 547 --dport 546 -j ACCEPT",
+	},
+	"FORWARD": iptablesBaseline["FORWARD"],
+	"OUTPUT":  iptablesBaseline["OUTPUT"],
+}
+
+// iptablesRule writes the kernel rules of one ufw rule like ufw does: an optional log rule, the
+// recent matches of limit and the verdict, once per protocol and port combination.
+func iptablesRule(tr translatedRule, chain string, v6 bool) []string {
+	rule := tr.rule
+	var lines []string
+	for _, m := range tr.matches {
+		match := fmt.Sprintf("-A %s%s", chain, iptablesMatch(rule, m))
+		switch rule.Log {
+		case "log":
+			lines = append(lines, fmt.Sprintf(`%s -m conntrack --ctstate NEW -j LOG --log-prefix "%s"`, match, logPrefix(rule.Action)))
+		case "log-all":
+			lines = append(lines, fmt.Sprintf(`%s -j LOG --log-prefix "%s"`, match, logPrefix(rule.Action)))
+		}
+
+		if rule.Action == "limit" {
+			name := fmt.Sprintf("ufw-limit-%d", rule.Number)
+			lines = append(lines,
+				fmt.Sprintf("%s -m conntrack --ctstate NEW -m recent --set --name %s", match, name),
+				fmt.Sprintf("%s -m conntrack --ctstate NEW -m recent --update --seconds %d --hitcount %d --name %s -j REJECT",
+					match, limitSeconds, limitHits, name))
+		}
+
+		verdict := " -j " + iptablesVerdict(rule.Action, m.proto, v6)
+		if rule.Comment != "" {
+			verdict = " -m comment --comment " + quotedComment(rule.Comment) + verdict
+		}
+		lines = append(lines, match+verdict)
+	}
+	return lines
+}
+
+func iptablesMatch(rule ufw.Rule, m portMatch) string {
+	var parts []string
+	in, out := interfaces(rule)
+	if in != "" {
+		parts = append(parts, "-i", in)
+	}
+	if out != "" {
+		parts = append(parts, "-o", out)
+	}
+	if rule.Source != "any" {
+		parts = append(parts, "-s", rule.Source)
+	}
+	if rule.Dest != "any" {
+		parts = append(parts, "-d", rule.Dest)
+	}
+	if m.proto != "" {
+		parts = append(parts, "-p", m.proto)
+	}
+	parts = append(parts, iptablesPorts("sport", m.sport)...)
+	parts = append(parts, iptablesPorts("dport", m.dport)...)
+
+	if len(parts) == 0 {
+		return ""
+	}
+	return " " + strings.Join(parts, " ")
+}
+
+// iptablesPorts matches a single port or range directly and a list with the multiport module.
+func iptablesPorts(option, ports string) []string {
+	switch {
+	case ports == "":
+		return nil
+	case strings.Contains(ports, ","):
+		return []string{"-m", "multiport", "--" + option + "s", ports}
+	default:
+		return []string{"--" + option, ports}
+	}
+}
+
+func iptablesPolicy(policy string) string {
+	if policy == "allow" {
+		return "ACCEPT"
+	}
+	return "DROP"
+}
+
+// iptablesVerdict maps a ufw action to a target. Like ufw, tcp is rejected with a reset.
+func iptablesVerdict(action, proto string, v6 bool) string {
+	switch action {
+	case "allow", "limit":
+		return "ACCEPT"
+	case "reject":
+		switch {
+		case proto == "tcp":
+			return "REJECT --reject-with tcp-reset"
+		case v6:
+			return "REJECT --reject-with icmp6-port-unreachable"
+		default:
+			return "REJECT --reject-with icmp-port-unreachable"
+		}
+	default:
+		return "DROP"
+	}
+}
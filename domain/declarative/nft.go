@@ -0,0 +1,218 @@
+package declarative
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Nft writes the firewall as a standalone nftables ruleset for `nft -f`. One inet table holds both
+// families, so a rule standing for its IPv6 twin as well is written once.
+func Nft(live Live) string {
+	lines := []string{
+		"#!/usr/sbin/nft -f",
+		fmt.Sprintf("# nftables ruleset exported by fwtui on %s.", time.Now().Format("2006-01-02 15:04")),
+	}
+	lines = append(lines, lossyNotes(live)...)
+	lines = append(lines,
+		"# ufw's limit action is approximated with a meter, see the rules using it.",
+		"# Loading the file flushes the whole ruleset, including tables other tools added.",
+		"",
+		"flush ruleset",
+		"",
+		"table inet filter {",
+	)
+
+	rules := translateRules(live)
+	for _, chain := range []struct{ name, policy string }{
+		{"input", live.Incoming},
+		{"forward", routedPolicy(live)},
+		{"output", live.Outgoing},
+	} {
+		lines = append(lines, fmt.Sprintf("\tchain %s {", chain.name))
+		lines = append(lines, fmt.Sprintf("\t\ttype filter hook %s priority filter; policy %s;", chain.name, nftPolicy(chain.policy)))
+		if chain.name == "forward" && live.Routed == "disabled" {
+			lines = append(lines, "\t\t# IP forwarding was off, nothing is routed until net.ipv4.ip_forward is turned on")
+		}
+		for _, line := range nftBaseline[chain.name] {
+			lines = append(lines, "\t\t"+line)
+		}
+
+		for _, tr := range rules {
+			if tr.chain != chain.name {
+				continue
+			}
+			lines = append(lines, "", "\t\t"+tr.header())
+			if tr.skip != "" {
+				lines = append(lines, "\t\t# left out: "+tr.skip)
+				continue
+			}
+			for _, line := range nftRule(tr, live.IPv6) {
+				lines = append(lines, "\t\t"+line)
+			}
+		}
+
+		if policyLogged(live, chain.policy) {
+			lines = append(lines, "", `		limit rate 3/minute burst 10 packets log prefix "[UFW BLOCK] "`)
+		}
+		if chain.policy == "reject" {
+			lines = append(lines, "\t\treject")
+		}
+		lines = append(lines, "\t}")
+	}
+	lines = append(lines, "}")
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// nftBaseline stands in for ufw's before.rules.
+var nftBaseline = map[string][]string{
+	"input": {
+		`iifname "lo" accept`,
+		"ct state established,related accept",
+		"ct state invalid drop",
+		"icmp type { destination-unreachable, time-exceeded, parameter-problem, echo-request } accept",
+		"icmpv6 type { destination-unreachable, packet-too-big, time-exceeded, parameter-problem, echo-request, nd-router-advert, nd-neighbor-solicit, nd-neighbor-advert } accept",
+		"udp sport 67 udp dport 68 accept",
+		"ip6 saddr fe80::/10 udp sport 547 udp dport 546 accept",
+	},
+	"forward": {
+		"ct state established,related accept",
+		"ct state invalid drop",
+	},
+	"output": {
+		`oifname "lo" accept`,
+		"ct state established,related accept",
+	},
+}
+
+// nftRule writes the kernel rules of one ufw rule: an optional log rule, the limit meters and the
+// verdict, once per protocol and port combination.
+func nftRule(tr translatedRule, ipv6 bool) []string {
+	rule := tr.rule
+	var lines []string
+	if rule.Action == "limit" {
+		lines = append(lines,
+			fmt.Sprintf("# ufw rejects the %dth new connection of an address within %d seconds, the meter", limitHits, limitSeconds),
+			fmt.Sprintf("# allows %d per minute with a burst of %d instead", limitHits*60/limitSeconds, limitHits))
+	}
+	for _, m := range tr.matches {
+		match := nftMatch(tr, m, ipv6)
+		if match != "" {
+			match += " "
+		}
+		switch rule.Log {
+		case "log":
+			lines = append(lines, fmt.Sprintf("%sct state new log prefix %q", match, logPrefix(rule.Action)))
+		case "log-all":
+			lines = append(lines, fmt.Sprintf("%slog prefix %q", match, logPrefix(rule.Action)))
+		}
+
+		if rule.Action == "limit" {
+			for _, family := range nftLimitFamilies(tr, ipv6) {
+				lines = append(lines, fmt.Sprintf(
+					"%sct state new meter ufw_limit_%d_%s { %s saddr limit rate over %d/minute burst %d packets } reject",
+					match, rule.Number, family, family, limitHits*60/limitSeconds, limitHits))
+			}
+		}
+
+		verdict := nftVerdict(rule.Action, m.proto)
+		if rule.Comment != "" {
+			verdict += " comment " + quotedComment(rule.Comment)
+		}
+		lines = append(lines, match+verdict)
+	}
+	return lines
+}
+
+// nftMatch writes the match part of a rule. Without an address the family comes from the rule.
+func nftMatch(tr translatedRule, m portMatch, ipv6 bool) string {
+	rule := tr.rule
+	var parts []string
+	in, out := interfaces(rule)
+	if in != "" {
+		parts = append(parts, fmt.Sprintf("iifname %q", in))
+	}
+	if out != "" {
+		parts = append(parts, fmt.Sprintf("oifname %q", out))
+	}
+
+	if rule.Source == "any" && rule.Dest == "any" {
+		switch {
+		case tr.family == "v6":
+			parts = append(parts, "meta nfproto ipv6")
+		case tr.family == "v4" || !ipv6:
+			parts = append(parts, "meta nfproto ipv4")
+		}
+	}
+	if rule.Source != "any" {
+		parts = append(parts, fmt.Sprintf("%s saddr %s", nftAddrFamily(rule.Source), rule.Source))
+	}
+	if rule.Dest != "any" {
+		parts = append(parts, fmt.Sprintf("%s daddr %s", nftAddrFamily(rule.Dest), rule.Dest))
+	}
+
+	switch {
+	case m.sport != "" || m.dport != "":
+		if m.sport != "" {
+			parts = append(parts, fmt.Sprintf("%s sport %s", m.proto, nftPorts(m.sport)))
+		}
+		if m.dport != "" {
+			parts = append(parts, fmt.Sprintf("%s dport %s", m.proto, nftPorts(m.dport)))
+		}
+	case m.proto != "":
+		parts = append(parts, "meta l4proto "+m.proto)
+	}
+	return strings.Join(parts, " ")
+}
+
+func nftAddrFamily(addr string) string {
+	if strings.Contains(addr, ":") {
+		return "ip6"
+	}
+	return "ip"
+}
+
+// nftLimitFamilies are the address families the limit meters key on. A meter only sees the
+// packets of its family, so a rule for both families needs two.
+func nftLimitFamilies(tr translatedRule, ipv6 bool) []string {
+	switch {
+	case tr.rule.V6:
+		return []string{"ip6"}
+	case tr.family == "" && ipv6:
+		return []string{"ip", "ip6"}
+	default:
+		return []string{"ip"}
+	}
+}
+
+// nftPorts turns ufw's port syntax into nft's, "80,443,8000:8080" becomes "{ 80, 443, 8000-8080 }".
+func nftPorts(ports string) string {
+	parts := strings.Split(strings.ReplaceAll(ports, ":", "-"), ",")
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return "{ " + strings.Join(parts, ", ") + " }"
+}
+
+// nftPolicy maps a default policy to a chain policy, a reject policy gets a final reject rule.
+func nftPolicy(policy string) string {
+	if policy == "allow" {
+		return "accept"
+	}
+	return "drop"
+}
+
+// nftVerdict maps a ufw action to a verdict. Like ufw, tcp is rejected with a reset.
+func nftVerdict(action, proto string) string {
+	switch action {
+	case "allow", "limit":
+		return "accept"
+	case "reject":
+		if proto == "tcp" {
+			return "reject with tcp reset"
+		}
+		return "reject"
+	default:
+		return "drop"
+	}
+}
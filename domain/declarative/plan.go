@@ -0,0 +1,447 @@
+package declarative
+
+import (
+	"fmt"
+	"fwtui/domain/entity"
+	"fwtui/domain/ufw"
+	"fwtui/utils/oscmd"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// Live is the firewall as it is, the plan brings it to the config.
+type Live struct {
+	Enabled       bool
+	Rules         []ufw.Rule
+	Incoming      string
+	Outgoing      string
+	Routed        string // as `ufw status` reports it, disabled while IP forwarding is off
+	ForwardPolicy string // the routed policy ufw keeps while forwarding is off
+	Logging       string
+	IPv6          bool
+	Profiles      []entity.UFWProfile
+}
+
+type StepKind string
+
+const (
+	StepAdd    StepKind = "+"
+	StepRemove StepKind = "-"
+	StepChange StepKind = "~"
+	StepMove   StepKind = ">"
+)
+
+// Step is one change of a plan, the steps run in the order they are listed.
+type Step struct {
+	Kind StepKind
+	Text string
+	run  func() string
+}
+
+func (s Step) String() string {
+	return fmt.Sprintf("%s %s", s.Kind, s.Text)
+}
+
+type Plan struct {
+	Steps    []Step
+	Warnings []string
+}
+
+func (p Plan) Empty() bool {
+	return len(p.Steps) == 0
+}
+
+// Summary counts the steps per kind, e.g. "2 to add, 1 to remove, 0 to move, 1 to change".
+func (p Plan) Summary() string {
+	counts := map[StepKind]int{}
+	for _, step := range p.Steps {
+		counts[step.Kind]++
+	}
+	return fmt.Sprintf("%d to add, %d to remove, %d to move, %d to change",
+		counts[StepAdd], counts[StepRemove], counts[StepMove]/2, counts[StepChange])
+}
+
+func (p Plan) String() string {
+	lines := make([]string, 0, len(p.Steps)+len(p.Warnings)+2)
+	for _, w := range p.Warnings {
+		lines = append(lines, "! "+w)
+	}
+	if p.Empty() {
+		lines = append(lines, "No changes, the firewall matches the config.")
+		return strings.Join(lines, "\n")
+	}
+	for _, step := range p.Steps {
+		lines = append(lines, step.String())
+	}
+	lines = append(lines, "", "Plan: "+p.Summary())
+	return strings.Join(lines, "\n")
+}
+
+// Compute lists the steps that turn the live firewall into the config: settings first, then
+// profiles, rule removals from the highest number down and finally the insertions in rule order.
+// Rules are compared by the exact ufw arguments that create them, so a rule that is already in the
+// right place is never touched and a second run of the same plan finds nothing to do.
+func Compute(config Config, live Live) (Plan, error) {
+	var plan Plan
+	plan.Steps = append(plan.Steps, settingSteps(config, live)...)
+
+	profileSteps, warnings := profileSteps(config.Profiles, live.Profiles)
+	plan.Steps = append(plan.Steps, profileSteps...)
+	plan.Warnings = append(plan.Warnings, warnings...)
+
+	if !config.hasRules {
+		return plan, nil
+	}
+	ruleSteps, err := ruleSteps(config.Rules, live)
+	if err != nil {
+		return Plan{}, err
+	}
+	plan.Steps = append(plan.Steps, ruleSteps...)
+	return plan, nil
+}
+
+// Desired is the firewall a config describes on a freshly installed ufw, the sections left out of
+// the file keep ufw's defaults. The rules are numbered like `ufw status numbered` would number them.
+// It lets a config be linted or translated on a machine without ufw.
+func Desired(config Config) (Live, error) {
+	live := Live{
+		Enabled:       true,
+		Incoming:      "deny",
+		Outgoing:      "allow",
+		Routed:        "disabled",
+		ForwardPolicy: "deny",
+		Logging:       "low",
+		IPv6:          true,
+	}
+	if d := config.Defaults; d != nil {
+		live.Incoming = lo.CoalesceOrEmpty(d.Incoming, live.Incoming)
+		live.Outgoing = lo.CoalesceOrEmpty(d.Outgoing, live.Outgoing)
+		if d.Routed != "" && d.Routed != "disabled" {
+			live.Routed, live.ForwardPolicy = d.Routed, d.Routed
+		}
+	}
+	live.Logging = lo.CoalesceOrEmpty(config.Logging, live.Logging)
+
+	for _, p := range config.Profiles {
+		profile, ok := resolveProfile(p)
+		if !ok {
+			return Live{}, fmt.Errorf("profile %s has no ports and is not a predefined profile", p.Name)
+		}
+		live.Profiles = append(live.Profiles, profile)
+	}
+
+	var v4, v6 []ufw.Rule
+	for i, spec := range config.Rules {
+		rules, err := spec.Expand(live.IPv6)
+		if err != nil {
+			return Live{}, fmt.Errorf("rules[%d]: %w", i, err)
+		}
+		for _, rule := range rules {
+			if rule.V6 {
+				v6 = append(v6, rule)
+			} else {
+				v4 = append(v4, rule)
+			}
+		}
+	}
+	for _, rule := range append(v4, v6...) { // user6.rules is numbered behind user.rules
+		rule.Number = len(live.Rules) + 1
+		rule.Line = fmt.Sprintf("[%2d] %s", rule.Number, rule.Summary())
+		live.Rules = append(live.Rules, rule)
+	}
+	return live, nil
+}
+
+func settingSteps(config Config, live Live) []Step {
+	var steps []Step
+
+	if d := config.Defaults; d != nil {
+		for _, policy := range []struct{ direction, desired, current string }{
+			{"incoming", d.Incoming, live.Incoming},
+			{"outgoing", d.Outgoing, live.Outgoing},
+		} {
+			if policy.desired == "" || policy.desired == policy.current {
+				continue
+			}
+			direction, desired := policy.direction, policy.desired
+			steps = append(steps, Step{
+				Kind: StepChange,
+				Text: fmt.Sprintf("default %s: %s -> %s", direction, policy.current, desired),
+				run:  func() string { return ufw.SetDefaultPolicy(direction, desired) },
+			})
+		}
+
+		switch {
+		case d.Routed == "":
+		case d.Routed == "disabled":
+			if live.Routed != "disabled" {
+				steps = append(steps, Step{
+					Kind: StepChange,
+					Text: fmt.Sprintf("default routed: %s -> disabled (IP forwarding off)", live.Routed),
+					run:  func() string { return ufw.SetForwarding(ufw.Forwarding{}) },
+				})
+			}
+		default:
+			current, note := live.Routed, ""
+			if current == "disabled" {
+				current, note = live.ForwardPolicy, ", applies once IP forwarding is on"
+			}
+			if d.Routed != current {
+				desired := d.Routed
+				steps = append(steps, Step{
+					Kind: StepChange,
+					Text: fmt.Sprintf("default routed: %s -> %s%s", current, desired, note),
+					run:  func() string { return ufw.SetDefaultPolicy("routed", desired) },
+				})
+			}
+		}
+	}
+
+	if config.Logging != "" && config.Logging != live.Logging {
+		level := config.Logging
+		steps = append(steps, Step{
+			Kind: StepChange,
+			Text: fmt.Sprintf("logging: %s -> %s", live.Logging, level),
+			run:  func() string { return ufw.SetLogging(level) },
+		})
+	}
+	return steps
+}
+
+// profileSteps installs the missing profiles. Installed profiles are never rewritten, their files
+// often belong to a package and hold several profiles.
+func profileSteps(desired []Profile, installed []entity.UFWProfile) ([]Step, []string) {
+	var steps []Step
+	var warnings []string
+
+	byName := map[string]entity.UFWProfile{}
+	for _, p := range installed {
+		byName[p.Name] = p
+	}
+
+	for _, p := range desired {
+		if current, ok := byName[p.Name]; ok {
+			if len(p.Ports) > 0 && strings.Join(p.Ports, "|") != strings.Join(current.Ports, "|") {
+				warnings = append(warnings, fmt.Sprintf("profile %s is installed with ports %s, fwtui leaves installed profiles alone",
+					p.Name, strings.Join(current.Ports, ", ")))
+			}
+			continue
+		}
+
+		profile, ok := resolveProfile(p)
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("profile %s has no ports and is not a predefined profile, skipped", p.Name))
+			continue
+		}
+
+		steps = append(steps, Step{
+			Kind: StepAdd,
+			Text: fmt.Sprintf("profile %s (%s)", profile.Name, strings.Join(profile.Ports, ", ")),
+			run: func() string {
+				created := entity.CreateProfile(profile)
+				if created.IsErr() {
+					return "Error: " + created.Err().Error()
+				}
+				return created.Value()
+			},
+		})
+	}
+	return steps, warnings
+}
+
+// resolveProfile fills in the ports and title of a predefined profile the config names without
+// ports. It fails for other profiles without ports.
+func resolveProfile(p Profile) (entity.UFWProfile, bool) {
+	profile := entity.UFWProfile{Name: p.Name, Title: p.Title, Ports: p.Ports}
+	if len(profile.Ports) == 0 {
+		predefined, ok := lo.Find(entity.PredefinedProfiles(), func(c entity.UFWProfile) bool { return c.Name == p.Name })
+		if !ok {
+			return entity.UFWProfile{}, false
+		}
+		profile.Ports = predefined.Ports
+		if profile.Title == "" {
+			profile.Title = predefined.Title
+		}
+	}
+	if profile.Title == "" {
+		profile.Title = profile.Name
+	}
+	return profile, true
+}
+
+func ruleSteps(specs []RuleSpec, live Live) ([]Step, error) {
+	var desiredV4, desiredV6 []ufw.Rule
+	for i, spec := range specs {
+		rules, err := spec.Expand(live.IPv6)
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
+		}
+		for _, rule := range rules {
+			if rule.V6 {
+				desiredV6 = append(desiredV6, rule)
+			} else {
+				desiredV4 = append(desiredV4, rule)
+			}
+		}
+	}
+
+	var liveV4, liveV6 []ufw.Rule
+	for _, rule := range live.Rules {
+		if rule.V6 {
+			liveV6 = append(liveV6, rule)
+		} else {
+			liveV4 = append(liveV4, rule)
+		}
+	}
+	removeV4, insertV4 := diffFamily(liveV4, desiredV4, 0)
+	var removeV6 []ufw.Rule
+	var insertV6 []insertion
+	if live.IPv6 {
+		removeV6, insertV6 = diffFamily(liveV6, desiredV6, len(desiredV4))
+	}
+
+	reinserted := map[string]int{}
+	for _, ins := range append(append([]insertion(nil), insertV4...), insertV6...) {
+		reinserted[ins.rule.ExactArgs()] = ins.position
+	}
+	removed := map[string]int{}
+
+	var steps []Step
+	removals := append(removeV4, removeV6...) // user6.rules is numbered behind user.rules
+	for i := len(removals) - 1; i >= 0; i-- {
+		rule := removals[i]
+		key := rule.ExactArgs()
+		removed[key] = rule.Number
+		step := Step{
+			Kind: StepRemove,
+			Text: fmt.Sprintf("[%d] %s", rule.Number, describe(rule)),
+			run:  func() string { return ufw.DeleteRuleByNumber(rule.Number) },
+		}
+		if position, ok := reinserted[key]; ok {
+			step.Kind = StepMove
+			step.Text += fmt.Sprintf(" (moves to %d)", position)
+		}
+		steps = append(steps, step)
+	}
+
+	for _, ins := range append(insertV4, insertV6...) {
+		command := "sudo ufw " + ins.rule.InsertArgs(ins.position)
+		if ins.append {
+			command = "sudo ufw " + ins.rule.ExactArgs()
+		}
+		step := Step{
+			Kind: StepAdd,
+			Text: fmt.Sprintf("[%d] %s", ins.position, describe(ins.rule)),
+			run:  func() string { return oscmd.RunCommand(command) },
+		}
+		if number, ok := removed[ins.rule.ExactArgs()]; ok {
+			step.Kind = StepMove
+			step.Text += fmt.Sprintf(" (was %d)", number)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+type insertion struct {
+	rule     ufw.Rule
+	position int  // number in `ufw status numbered` once the rules before it are in place
+	append   bool // no kept rule follows, ufw appends the rule to the end of its family
+}
+
+// diffFamily keeps the longest common subsequence of the live and desired rules of one address
+// family in place. The other live rules are removed and the missing desired rules inserted at
+// their index, offset by the rules of the families numbered before this one.
+func diffFamily(live, desired []ufw.Rule, offset int) (remove []ufw.Rule, insert []insertion) {
+	liveKeys := make([]string, len(live))
+	for i, rule := range live {
+		liveKeys[i] = rule.ExactArgs()
+	}
+	desiredKeys := make([]string, len(desired))
+	for i, rule := range desired {
+		desiredKeys[i] = rule.ExactArgs()
+	}
+
+	// lengths[i][j] is the length of the common subsequence of liveKeys[i:] and desiredKeys[j:]
+	lengths := make([][]int, len(live)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(desired)+1)
+	}
+	for i := len(live) - 1; i >= 0; i-- {
+		for j := len(desired) - 1; j >= 0; j-- {
+			if liveKeys[i] == desiredKeys[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	keptLive := make([]bool, len(live))
+	keptDesired := make([]bool, len(desired))
+	for i, j := 0, 0; i < len(live) && j < len(desired); {
+		switch {
+		case liveKeys[i] == desiredKeys[j]:
+			keptLive[i], keptDesired[j] = true, true
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+
+	for i, rule := range live {
+		if !keptLive[i] {
+			remove = append(remove, rule)
+		}
+	}
+
+	lastKept := -1
+	for j := range desired {
+		if keptDesired[j] {
+			lastKept = j
+		}
+	}
+	for j, rule := range desired {
+		if !keptDesired[j] {
+			insert = append(insert, insertion{rule: rule, position: offset + j + 1, append: j > lastKept})
+		}
+	}
+	return remove, insert
+}
+
+func describe(rule ufw.Rule) string {
+	text := rule.Summary()
+	if rule.Comment != "" {
+		text += fmt.Sprintf(" comment '%s'", rule.Comment)
+	}
+	return text
+}
+
+// Apply backs the rules up and runs the steps of the plan, stopping at the first one that fails.
+func Apply(plan Plan) (string, error) {
+	if plan.Empty() {
+		return "Nothing to do, the firewall matches the config.", nil
+	}
+
+	path, err := ufw.Backup()
+	if err != nil {
+		return "", fmt.Errorf("backup failed, nothing applied: %w", err)
+	}
+	outputs := []string{"Backup: " + path}
+
+	for _, step := range plan.Steps {
+		output := strings.TrimSpace(step.run())
+		outputs = append(outputs, step.String())
+		if output != "" {
+			outputs = append(outputs, "  "+strings.ReplaceAll(output, "\n", "\n  "))
+		}
+		if strings.HasPrefix(output, "Error:") {
+			return strings.Join(outputs, "\n"), fmt.Errorf("%s failed, restore %s to roll back", step, path)
+		}
+	}
+	return strings.Join(outputs, "\n"), nil
+}
@@ -0,0 +1,71 @@
+package declarative
+
+import (
+	"fmt"
+	"fwtui/domain/ufw"
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+)
+
+func TestDesiredRebuildsTheExportedFirewall(t *testing.T) {
+	fw := setUp(t, map[string]string{"OpenSSH": "22/tcp"}, []string{
+		"sudo ufw default reject incoming",
+		"sudo ufw logging medium",
+		"sudo ufw limit 22/tcp",
+		"sudo ufw allow from 10.0.0.0/8 to any app OpenSSH",
+		"sudo ufw allow 443/tcp",
+		"sudo ufw deny from 2001:db8::1",
+		"sudo ufw insert 1 deny from 198.51.100.1",
+	})
+	fw.Forwarding = true
+	fw.Run("sudo ufw default allow routed")
+	live := liveOf(fw)
+
+	desired, err := Desired(Export(live))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if desired.Incoming != "reject" || desired.Outgoing != "allow" || desired.Routed != "allow" || desired.Logging != "medium" {
+		t.Errorf("settings = %s/%s/%s/%s, want reject/allow/allow/medium",
+			desired.Incoming, desired.Outgoing, desired.Routed, desired.Logging)
+	}
+	numbered := func(rules []ufw.Rule) []string {
+		return lo.Map(rules, func(r ufw.Rule, _ int) string { return fmt.Sprintf("%d %s", r.Number, r.ExactArgs()) })
+	}
+	if got, want := numbered(desired.Rules), numbered(live.Rules); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("rules:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if len(desired.Profiles) != 1 || desired.Profiles[0].Name != "OpenSSH" {
+		t.Errorf("profiles = %v, want OpenSSH", desired.Profiles)
+	}
+}
+
+func TestDesiredKeepsUfwDefaultsForMissingSections(t *testing.T) {
+	desired, err := Desired(Config{Rules: []RuleSpec{{Action: "allow", Port: "22", Proto: "tcp"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desired.Incoming != "deny" || desired.Outgoing != "allow" || desired.Routed != "disabled" || desired.Logging != "low" {
+		t.Errorf("settings = %s/%s/%s/%s, want deny/allow/disabled/low",
+			desired.Incoming, desired.Outgoing, desired.Routed, desired.Logging)
+	}
+	if len(desired.Rules) != 2 || desired.Rules[1].Number != 2 || !desired.Rules[1].V6 {
+		t.Errorf("rules = %v, want the rule and its IPv6 twin", desired.Rules)
+	}
+
+	if _, err := Desired(Config{Profiles: []Profile{{Name: "Unknown"}}}); err == nil {
+		t.Error("a profile without ports that isn't predefined passed")
+	}
+}
+
+func TestDesiredChecksInterfacesLikeUfw(t *testing.T) {
+	for iface, valid := range map[string]bool{"eth0": true, "veth1@if5": true, "eth0.100": true, "eth0:1": false, "eth+": false, "eth0;reboot": false} {
+		_, err := Desired(Config{Rules: []RuleSpec{{Action: "allow", Port: "22", Proto: "tcp", Interface: iface}}})
+		if (err == nil) != valid {
+			t.Errorf("interface %q: %v, want valid %v", iface, err, valid)
+		}
+	}
+}
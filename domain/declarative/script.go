@@ -0,0 +1,132 @@
+package declarative
+
+import (
+	"fmt"
+	"fwtui/domain/fakeufw"
+	"fwtui/domain/ufw"
+	"strings"
+	"time"
+)
+
+const FormatScript = "ufw"
+
+// Script writes the firewall as the ufw commands that build it from scratch: a reset, the default
+// policies, the logging level and the rules in the order of `ufw status numbered`. A rule standing
+// for its IPv6 twin as well is written once, like one would type it.
+func Script(live Live) string {
+	lines := []string{
+		"#!/bin/sh",
+		fmt.Sprintf("# ufw commands exported by fwtui on %s.", time.Now().Format("2006-01-02 15:04")),
+		"# Running the script replaces the firewall of the host it runs on.",
+		"set -e",
+		"",
+		"ufw --force reset",
+		fmt.Sprintf("ufw default %s incoming", live.Incoming),
+		fmt.Sprintf("ufw default %s outgoing", live.Outgoing),
+	}
+
+	switch {
+	case live.Routed != "disabled":
+		lines = append(lines, fmt.Sprintf("ufw default %s routed", live.Routed))
+	case live.ForwardPolicy != "":
+		lines = append(lines,
+			"# IP forwarding was off, routing stays disabled until it is turned on in /etc/ufw/sysctl.conf",
+			fmt.Sprintf("ufw default %s routed", live.ForwardPolicy))
+	}
+	lines = append(lines, fmt.Sprintf("ufw logging %s", live.Logging), "")
+
+	if names := apps(live.Rules); len(names) > 0 {
+		lines = append(lines, fmt.Sprintf("# needs the application profiles %s", strings.Join(names, ", ")))
+	}
+	skipped := 0
+	for _, fr := range mergeFamilies(live) {
+		if fr.rule.V6 && !live.IPv6 {
+			skipped++
+			continue
+		}
+		args := fr.rule.ExactArgs()
+		if fr.family == "" {
+			args = fr.rule.Args()
+		}
+		lines = append(lines, "ufw "+args)
+	}
+	if skipped > 0 {
+		lines = append(lines, fmt.Sprintf("# %d IPv6-only rules left out, IPv6 was off", skipped))
+	}
+
+	if live.Enabled {
+		lines = append(lines, "", "ufw --force enable")
+	} else {
+		lines = append(lines, "", "# ufw was inactive, enable it with: ufw enable")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func apps(rules []ufw.Rule) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		for _, app := range []string{rule.DestApp, rule.SrcApp} {
+			if app != "" && !seen[app] {
+				seen[app] = true
+				names = append(names, app)
+			}
+		}
+	}
+	return names
+}
+
+// VerifyScript replays the script on the fake firewall and checks that it ends up exactly like the
+// live firewall it was written from.
+func VerifyScript(script string, live Live) error {
+	fw := fakeufw.New()
+	fw.IPv6 = live.IPv6
+	fw.Forwarding = live.Routed != "disabled"
+	for _, p := range live.Profiles {
+		fw.Apps[p.Name] = strings.Join(p.Ports, "|")
+	}
+	for _, app := range apps(live.Rules) {
+		if _, ok := fw.Apps[app]; !ok {
+			fw.Apps[app] = ""
+		}
+	}
+
+	if output := fw.RunScript(script); strings.HasPrefix(output, "Error:") {
+		return fmt.Errorf("the script fails: %s", strings.TrimSpace(strings.TrimPrefix(output, "Error:")))
+	}
+
+	routed, wantRouted := fw.Routed(), live.Routed
+	if live.Routed == "disabled" && live.ForwardPolicy != "" {
+		routed, wantRouted = fw.ForwardPolicy, live.ForwardPolicy
+	}
+	for _, setting := range []struct{ name, got, want string }{
+		{"incoming policy", fw.Incoming, live.Incoming},
+		{"outgoing policy", fw.Outgoing, live.Outgoing},
+		{"routed policy", routed, wantRouted},
+		{"logging", fw.Logging, live.Logging},
+		{"status", fmt.Sprint(fw.Enabled), fmt.Sprint(live.Enabled)},
+	} {
+		if setting.got != setting.want {
+			return fmt.Errorf("%s is %s instead of %s", setting.name, setting.got, setting.want)
+		}
+	}
+
+	var want []ufw.Rule
+	for _, rule := range live.Rules {
+		if !rule.V6 || live.IPv6 {
+			want = append(want, rule)
+		}
+	}
+	got := fw.Rules()
+	for i := 0; i < max(len(got), len(want)); i++ {
+		switch {
+		case i >= len(got):
+			return fmt.Errorf("rule %d is missing: %s", i+1, want[i].ExactArgs())
+		case i >= len(want):
+			return fmt.Errorf("rule %d is extra: %s", i+1, got[i].ExactArgs())
+		case got[i].ExactArgs() != want[i].ExactArgs():
+			return fmt.Errorf("rule %d is %s instead of %s", i+1, got[i].ExactArgs(), want[i].ExactArgs())
+		}
+	}
+	return nil
+}
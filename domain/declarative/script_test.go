@@ -0,0 +1,153 @@
+package declarative
+
+import (
+	"fwtui/domain/entity"
+	"fwtui/domain/fakeufw"
+	"fwtui/domain/ufw"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// setUp runs the commands on a fresh fake firewall, with the application profiles given by name
+// and ports.
+func setUp(t *testing.T, apps map[string]string, commands []string) *fakeufw.Firewall {
+	t.Helper()
+	fw := fakeufw.New()
+	for name, ports := range apps {
+		fw.Apps[name] = ports
+	}
+	for _, command := range commands {
+		if output := fw.Run(command); strings.HasPrefix(output, "Error:") {
+			t.Fatalf("%s: %s", command, output)
+		}
+	}
+	return fw
+}
+
+// liveOf reads the fake firewall the way the state of a real host is read.
+func liveOf(fw *fakeufw.Firewall) Live {
+	live := Live{
+		Enabled:       fw.Enabled,
+		Rules:         fw.Rules(),
+		Incoming:      fw.Incoming,
+		Outgoing:      fw.Outgoing,
+		Routed:        fw.Routed(),
+		ForwardPolicy: fw.ForwardPolicy,
+		Logging:       fw.Logging,
+		IPv6:          fw.IPv6,
+	}
+	for name, ports := range fw.Apps {
+		live.Profiles = append(live.Profiles, entity.UFWProfile{Name: name, Ports: strings.Split(ports, "|"), Installed: true})
+	}
+	sort.Slice(live.Profiles, func(i, j int) bool { return live.Profiles[i].Name < live.Profiles[j].Name })
+	return live
+}
+
+func TestScriptRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		apps       map[string]string
+		forwarding bool
+		commands   []string
+	}{
+		{
+			name: "app rules",
+			apps: map[string]string{"OpenSSH": "22/tcp", "Nginx Full": "80,443/tcp"},
+			commands: []string{
+				"sudo ufw allow 'Nginx Full'",
+				"sudo ufw allow from 10.0.0.0/8 to any app OpenSSH",
+				"sudo ufw deny from any app OpenSSH to 192.0.2.1",
+			},
+		},
+		{
+			name:       "route rules",
+			forwarding: true,
+			commands: []string{
+				"sudo ufw default allow routed",
+				"sudo ufw route allow in on wg0 out on eth0 from 10.8.0.0/24",
+				"sudo ufw route deny in on eth1 to 192.168.1.0/24 port 22 proto tcp",
+				"sudo ufw route allow in on wg0 out on eth0 from fd00::/64",
+			},
+		},
+		{
+			name:     "routing disabled keeps the forward policy",
+			commands: []string{"sudo ufw default reject routed"},
+		},
+		{
+			name: "limit and port ranges",
+			commands: []string{
+				"sudo ufw limit 22/tcp",
+				"sudo ufw limit in on eth0 to any port 2222 proto tcp",
+				"sudo ufw allow 60000:61000/udp",
+			},
+		},
+		{
+			name: "logging",
+			commands: []string{
+				"sudo ufw logging medium",
+				"sudo ufw allow log-all 80/tcp",
+				"sudo ufw deny log from 203.0.113.0/24",
+			},
+		},
+		{
+			name: "comments with quotes",
+			commands: []string{
+				`sudo ufw allow 8080/tcp comment 'it'\''s the "web" port'`,
+				`sudo ufw allow from 198.51.100.7 comment "Bob's laptop"`,
+			},
+		},
+		{
+			name: "insert ordering",
+			commands: []string{
+				"sudo ufw allow 22/tcp",
+				"sudo ufw allow 443/tcp",
+				"sudo ufw insert 1 deny from 198.51.100.1",
+				"sudo ufw insert 2 deny from 203.0.113.0/24 to any port 22 proto tcp",
+				"sudo ufw insert 6 deny from 2001:db8::1",
+				"sudo ufw default reject incoming",
+				"sudo ufw default deny outgoing",
+				"sudo ufw enable",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fw := setUp(t, tc.apps, nil)
+			fw.Forwarding = tc.forwarding
+			for _, command := range tc.commands {
+				if output := fw.Run(command); strings.HasPrefix(output, "Error:") {
+					t.Fatalf("%s: %s", command, output)
+				}
+			}
+			live := liveOf(fw)
+
+			script := Script(live)
+			if err := VerifyScript(script, live); err != nil {
+				t.Fatalf("%v\n%s", err, script)
+			}
+
+			replayed := setUp(t, tc.apps, nil)
+			replayed.Forwarding = tc.forwarding
+			if output := replayed.RunScript(script); strings.HasPrefix(output, "Error:") {
+				t.Fatalf("%s\n%s", output, script)
+			}
+			got, want := liveOf(replayed), live
+			if a, b := summaries(got.Rules), summaries(want.Rules); a != b {
+				t.Errorf("rules:\n%s\nwant:\n%s", a, b)
+			}
+			got.Rules, want.Rules = nil, nil
+			if got.Enabled != want.Enabled || got.Incoming != want.Incoming || got.Outgoing != want.Outgoing ||
+				got.Routed != want.Routed || got.ForwardPolicy != want.ForwardPolicy || got.Logging != want.Logging {
+				t.Errorf("settings: %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func summaries(rules []ufw.Rule) string {
+	var lines []string
+	for _, rule := range rules {
+		lines = append(lines, rule.ExactArgs()+" | "+rule.Comment)
+	}
+	return strings.Join(lines, "\n")
+}
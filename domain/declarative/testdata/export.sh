@@ -0,0 +1,27 @@
+#!/bin/sh
+# ufw commands exported by fwtui on DATE.
+# Running the script replaces the firewall of the host it runs on.
+set -e
+
+ufw --force reset
+ufw default reject incoming
+ufw default allow outgoing
+ufw default allow routed
+ufw logging medium
+
+# needs the application profiles Nginx Full, OpenSSH, Samba
+ufw allow in from any to any app 'Nginx Full'
+ufw allow in from 10.0.0.0/8 to 0.0.0.0/0 app 'OpenSSH'
+ufw allow in from 192.168.1.0/24 to 0.0.0.0/0 app 'Samba'
+ufw limit in proto tcp from any to any port 22
+ufw allow in log proto tcp from any to any port 25
+ufw deny in log-all from 203.0.113.0/24 to 0.0.0.0/0
+ufw allow in proto udp from any to any port 60000:61000
+ufw allow in on eth0 proto tcp from any to any port 8000:8080,9000
+ufw allow in proto tcp from 2001:db8::/32 to ::/0 port 5432
+ufw route allow in on wg0 out on eth0 from 10.8.0.0/24 to 0.0.0.0/0
+ufw route deny in on eth1 out on eth0 proto tcp from 0.0.0.0/0 to 198.51.100.0/24 port 3306
+ufw reject out proto tcp from 0.0.0.0/0 to 192.0.2.10 port 25
+ufw allow in proto tcp from any to any port 8443 comment 'it'\''s the "admin" port'
+
+ufw --force enable
@@ -0,0 +1,209 @@
+package declarative
+
+import (
+	"fmt"
+	"fwtui/domain/ufw"
+	"strings"
+)
+
+// The nft and iptables-save exporters share the way a ufw rule is taken apart: a chain, the
+// interfaces and addresses, and one match per protocol and port combination, since a port without
+// protocol and the ports of application profiles stand for several kernel rules.
+
+const (
+	FormatNft       = "nft"
+	FormatIptables  = "iptables"
+	FormatIp6tables = "ip6tables"
+)
+
+// limitHits and limitSeconds are the thresholds of ufw's limit action: the sixth new connection
+// from one address within 30 seconds is rejected.
+const (
+	limitHits    = 6
+	limitSeconds = 30
+)
+
+// translatedRule is a ufw rule ready to be written as kernel rules. A rule that can't be translated
+// keeps the reason in skip.
+type translatedRule struct {
+	rule    ufw.Rule
+	family  string // as in familyRule, empty for both families
+	chain   string // input, output or forward
+	matches []portMatch
+	skip    string
+}
+
+// portMatch is one protocol and port combination of a rule, empty values match anything.
+type portMatch struct {
+	proto string
+	sport string
+	dport string
+}
+
+type portSpec struct {
+	proto string
+	ports string
+}
+
+func translateRules(live Live) []translatedRule {
+	profiles := map[string][]string{}
+	for _, p := range live.Profiles {
+		profiles[p.Name] = p.Ports
+	}
+
+	var translated []translatedRule
+	for _, fr := range mergeFamilies(live) {
+		rule := fr.rule
+		tr := translatedRule{rule: rule, family: fr.family, chain: chainOf(rule)}
+		src, errSrc := endpointPorts(rule.SrcPort, rule.SrcApp, rule.Protocol, profiles)
+		dst, errDst := endpointPorts(rule.DestPort, rule.DestApp, rule.Protocol, profiles)
+		switch {
+		case errSrc != nil:
+			tr.skip = errSrc.Error()
+		case errDst != nil:
+			tr.skip = errDst.Error()
+		default:
+			tr.matches = combinePorts(src, dst)
+			if len(tr.matches) == 0 {
+				tr.skip = "the source and destination ports have no protocol in common"
+			}
+		}
+		translated = append(translated, tr)
+	}
+	return translated
+}
+
+func chainOf(rule ufw.Rule) string {
+	switch {
+	case rule.Route:
+		return "forward"
+	case rule.Direction == "out":
+		return "output"
+	default:
+		return "input"
+	}
+}
+
+// endpointPorts lists the protocols and ports of one side of a rule. The ports of an application
+// are taken from its installed profile, a port without protocol matches tcp and udp.
+func endpointPorts(port, app, proto string, profiles map[string][]string) ([]portSpec, error) {
+	proto = exportValue(proto)
+	var specs []portSpec
+	switch {
+	case app != "":
+		entries, ok := profiles[app]
+		if !ok {
+			return nil, fmt.Errorf("the application profile %s is not installed", app)
+		}
+		for _, entry := range entries {
+			ports, entryProto, _ := strings.Cut(entry, "/")
+			specs = append(specs, portSpec{proto: entryProto, ports: ports})
+		}
+	case port != "any":
+		specs = append(specs, portSpec{proto: proto, ports: port})
+	default:
+		return []portSpec{{proto: proto}}, nil
+	}
+
+	var expanded []portSpec
+	for _, spec := range specs {
+		if spec.proto == "" {
+			expanded = append(expanded, portSpec{"tcp", spec.ports}, portSpec{"udp", spec.ports})
+		} else {
+			expanded = append(expanded, spec)
+		}
+	}
+	return expanded, nil
+}
+
+// combinePorts pairs the source and destination ports that share a protocol.
+func combinePorts(src, dst []portSpec) []portMatch {
+	var matches []portMatch
+	for _, s := range src {
+		for _, d := range dst {
+			proto := s.proto
+			switch {
+			case s.proto == "":
+				proto = d.proto
+			case d.proto != "" && d.proto != s.proto:
+				continue
+			}
+			matches = append(matches, portMatch{proto: proto, sport: s.ports, dport: d.ports})
+		}
+	}
+	return matches
+}
+
+// header describes the rule above its kernel rules, e.g. "ufw rule 3: allow in from any to any
+// port 22 proto tcp".
+func (tr translatedRule) header() string {
+	text := fmt.Sprintf("# ufw rule %d: %s", tr.rule.Number, tr.rule.Summary())
+	if tr.rule.Comment != "" {
+		text += fmt.Sprintf(" (%s)", tr.rule.Comment)
+	}
+	return text
+}
+
+// logPrefix is the prefix ufw gives to logged packets, the log module reads these lines.
+func logPrefix(action string) string {
+	if action == "allow" || action == "limit" {
+		return "[UFW ALLOW] "
+	}
+	return "[UFW BLOCK] "
+}
+
+// policyLogged tells whether ufw logs the packets a default policy blocks, which it does from the
+// low logging level on.
+func policyLogged(live Live, policy string) bool {
+	return live.Logging != ufw.LoggingOff && policy != "allow"
+}
+
+// lossyNotes are the header comments on what the translation leaves out.
+func lossyNotes(live Live) []string {
+	notes := []string{
+		"# Only the ufw user rules and the default policies are translated. The contents of",
+		"# /etc/ufw/before.rules and after.rules, including NAT, are replaced by a fixed baseline",
+		"# close to ufw's defaults: loopback, established connections, essential ICMP and DHCP.",
+		"# ufw's handling of broadcast and multicast traffic is not reproduced.",
+	}
+	if live.Logging != ufw.LoggingOff && live.Logging != ufw.LoggingLow {
+		notes = append(notes,
+			fmt.Sprintf("# ufw logged at level %s, only what the low level logs is kept: packets blocked", live.Logging),
+			"# by the default policies and the rules with log or log-all.")
+	}
+	return notes
+}
+
+// interfaces returns the interfaces a rule matches packets coming in and going out on.
+func interfaces(rule ufw.Rule) (in, out string) {
+	switch {
+	case rule.Route:
+		return rule.InterfaceIn, rule.InterfaceOut
+	case rule.Direction == "out":
+		return "", rule.InterfaceOut
+	default:
+		return rule.InterfaceIn, ""
+	}
+}
+
+// routedPolicy is the policy of forwarded traffic. While IP forwarding is off ufw still keeps one,
+// the kernel just routes nothing.
+func routedPolicy(live Live) string {
+	switch {
+	case live.Routed != "disabled":
+		return live.Routed
+	case live.ForwardPolicy != "":
+		return live.ForwardPolicy
+	default:
+		return "deny"
+	}
+}
+
+// quotedComment makes a rule comment safe to put between double quotes.
+func quotedComment(comment string) string {
+	comment = strings.NewReplacer(`"`, "'", "\n", " ").Replace(comment)
+	if len(comment) > 128 {
+		comment = comment[:128]
+	}
+	return `"` + comment + `"`
+}
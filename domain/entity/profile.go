@@ -2,10 +2,12 @@ package entity
 
 import (
 	"fmt"
+	"fwtui/domain/services"
 	"fwtui/domain/ufw"
 	"fwtui/utils/result"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/samber/lo"
@@ -65,118 +67,191 @@ func DeleteProfile(p UFWProfile) string {
 	return fmt.Sprintf("Profile with title '%s' not found", p.Title)
 }
 
+// LoadInstalledProfiles lists the application profiles ufw accepts, as `ufw app list` names them. The
+// titles and ports are read straight from the profile files instead of asking `ufw app info` for
+// each profile; a file section ufw rejects, e.g. for an invalid port, is left out like ufw leaves it
+// out.
 func LoadInstalledProfiles() ([]UFWProfile, error) {
+	list := ufw.GetProfileList()
+	if strings.HasPrefix(list, "Error:") {
+		return nil, fmt.Errorf("listing profiles: %s", strings.TrimSpace(strings.TrimPrefix(list, "Error:")))
+	}
 
-	profileNames := strings.Split(strings.TrimSpace(ufw.GetProfileList()), "\n")[1:]
-
-	var profiles []UFWProfile
-	for _, name := range profileNames {
-		name = strings.TrimSpace(name)
-		if name == "" {
+	files, err := os.ReadDir(profilesPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading profiles directory: %w", err)
+	}
+	var parsed []UFWProfile
+	for _, file := range files {
+		if file.IsDir() {
 			continue
 		}
-		profile, err := getUFWProfileInfo(name)
+		content, err := os.ReadFile(filepath.Join(profilesPath, file.Name()))
 		if err != nil {
 			continue
 		}
-		profiles = append(profiles, profile)
+		parsed = append(parsed, parseProfileFile(string(content))...)
 	}
-	return profiles, nil
+	return listedProfiles(list, parsed), nil
 }
 
-func getUFWProfileInfo(name string) (UFWProfile, error) {
-	lines := strings.Split(ufw.GetProfileInfo(name), "\n")
+// listedProfiles keeps the parsed profiles `ufw app list` names, sorted by name. A listed profile
+// whose file section couldn't be parsed is described by `ufw app info`.
+func listedProfiles(list string, parsed []UFWProfile) []UFWProfile {
+	byName := map[string]UFWProfile{}
+	for _, p := range parsed {
+		if _, ok := byName[p.Name]; !ok {
+			byName[p.Name] = p
+		}
+	}
 
-	profile := UFWProfile{
-		Name:      name,
-		Installed: true,
+	var profiles []UFWProfile
+	for _, line := range strings.Split(list, "\n") {
+		// the names are indented below the "Available applications:" header
+		if !strings.HasPrefix(line, " ") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		name := strings.TrimSpace(line)
+		profile, ok := byName[name]
+		if !ok {
+			profile = getUFWProfileInfo(name)
+		}
+		profiles = append(profiles, profile)
 	}
 
+	sort.Slice(profiles, func(i, j int) bool {
+		return strings.ToLower(profiles[i].Name) < strings.ToLower(profiles[j].Name)
+	})
+	return profiles
+}
+
+func getUFWProfileInfo(name string) UFWProfile {
+	lines := strings.Split(ufw.GetProfileInfo(name), "\n")
+	profile := UFWProfile{Name: name, Installed: true}
 	for i, line := range lines {
 		line = strings.TrimSpace(line)
 		switch {
-		case strings.HasPrefix(line, "Profile:"):
-			profile.Name = strings.TrimSpace(strings.TrimPrefix(line, "Profile:"))
 		case strings.HasPrefix(line, "Title:"):
 			profile.Title = strings.TrimSpace(strings.TrimPrefix(line, "Title:"))
 		case strings.HasPrefix(line, "Ports:") || strings.HasPrefix(line, "Port:"):
-			for j := i + 1; j < len(lines); j++ {
-				portLine := strings.TrimSpace(lines[j])
-				if portLine == "" {
+			for _, portLine := range lines[i+1:] {
+				if strings.TrimSpace(portLine) == "" {
 					break
 				}
-				profile.Ports = append(profile.Ports, portLine)
+				profile.Ports = append(profile.Ports, strings.TrimSpace(portLine))
 			}
 		}
 	}
-
-	return profile, nil
+	return profile
 }
 
-func InstallableProfiles() []UFWProfile {
-	installedProfiles, _ := LoadInstalledProfiles()
-	installedProfileNames := lo.Map(installedProfiles, func(p UFWProfile, _ int) string {
-		return p.Name
-	})
+func parseProfileFile(content string) []UFWProfile {
+	var profiles []UFWProfile
+	var current *UFWProfile
 
-	profiles := []UFWProfile{
-		// Common access
-		{Name: "OpenSSH", Title: "Secure shell access (SSH)", Ports: []string{"22/tcp"}, Installed: lo.Contains(installedProfileNames, "OpenSSH")},
-		{Name: "HTTP", Title: "Generic HTTP service", Ports: []string{"80/tcp"}, Installed: lo.Contains(installedProfileNames, "HTTP")},
-		{Name: "HTTPS", Title: "Generic HTTPS service", Ports: []string{"443/tcp"}, Installed: lo.Contains(installedProfileNames, "HTTPS")},
-
-		// Web servers
-		{Name: "Nginx HTTP", Title: "Nginx web server (HTTP only)", Ports: []string{"80/tcp"}, Installed: lo.Contains(installedProfileNames, "Nginx HTTP")},
-		{Name: "Nginx HTTPS", Title: "Nginx web server (HTTPS only)", Ports: []string{"443/tcp"}, Installed: lo.Contains(installedProfileNames, "Nginx HTTPS")},
-		{Name: "Nginx Full", Title: "Nginx web server (HTTP and HTTPS)", Ports: []string{"80,443/tcp"}, Installed: lo.Contains(installedProfileNames, "Nginx Full")},
-		{Name: "Apache", Title: "Apache web server (HTTP only)", Ports: []string{"80/tcp"}, Installed: lo.Contains(installedProfileNames, "Apache")},
-		{Name: "Apache Secure", Title: "Apache web server (HTTPS only)", Ports: []string{"443/tcp"}, Installed: lo.Contains(installedProfileNames, "Apache Secure")},
-		{Name: "Apache Full", Title: "Apache web server (HTTP and HTTPS)", Ports: []string{"80,443/tcp"}, Installed: lo.Contains(installedProfileNames, "Apache Full")},
-
-		// Databases
-		{Name: "PostgreSQL", Title: "PostgreSQL database server", Ports: []string{"5432/tcp"}, Installed: lo.Contains(installedProfileNames, "PostgreSQL")},
-		{Name: "MySQL", Title: "MySQL database server", Ports: []string{"3306/tcp"}, Installed: lo.Contains(installedProfileNames, "MySQL")},
-		{Name: "MongoDB", Title: "MongoDB database", Ports: []string{"27017/tcp"}, Installed: lo.Contains(installedProfileNames, "MongoDB")},
-		{Name: "Redis", Title: "Redis key-value store", Ports: []string{"6379/tcp"}, Installed: lo.Contains(installedProfileNames, "Redis")},
-		{Name: "InfluxDB", Title: "InfluxDB time series database", Ports: []string{"8086/tcp"}, Installed: lo.Contains(installedProfileNames, "InfluxDB")},
-		{Name: "Elasticsearch", Title: "Elasticsearch search engine", Ports: []string{"9200,9300/tcp"}, Installed: lo.Contains(installedProfileNames, "Elasticsearch")},
-
-		// DevOps / containers
-		{Name: "Docker Remote API", Title: "Docker remote API", Ports: []string{"2375,2376/tcp"}, Installed: lo.Contains(installedProfileNames, "Docker Remote API")},
-		{Name: "Kubernetes API", Title: "Kubernetes API server", Ports: []string{"6443/tcp"}, Installed: lo.Contains(installedProfileNames, "Kubernetes API")},
-		{Name: "Docker Swarm", Title: "Docker Swarm cluster communication", Ports: []string{"2377,7946/tcp", "7946,4789/udp"}, Installed: lo.Contains(installedProfileNames, "Docker Swarm")},
-
-		// VPN
-		{Name: "WireGuard", Title: "WireGuard VPN", Ports: []string{"51820/udp"}, Installed: lo.Contains(installedProfileNames, "WireGuard")},
-		{Name: "OpenVPN", Title: "OpenVPN", Ports: []string{"1194/udp"}, Installed: lo.Contains(installedProfileNames, "OpenVPN")},
-
-		// Email
-		{Name: "SMTP", Title: "Simple Mail Transfer Protocol", Ports: []string{"25/tcp"}, Installed: lo.Contains(installedProfileNames, "SMTP")},
-		{Name: "SMTPS", Title: "SMTP over SSL", Ports: []string{"465/tcp"}, Installed: lo.Contains(installedProfileNames, "SMTPS")},
-		{Name: "Submission", Title: "Mail Submission Agent", Ports: []string{"587/tcp"}, Installed: lo.Contains(installedProfileNames, "Submission")},
-		{Name: "IMAPS", Title: "IMAP over SSL", Ports: []string{"993/tcp"}, Installed: lo.Contains(installedProfileNames, "IMAPS")},
-		{Name: "POP3S", Title: "POP3 over SSL", Ports: []string{"995/tcp"}, Installed: lo.Contains(installedProfileNames, "POP3S")},
-
-		// DNS
-		{Name: "DNS", Title: "Domain name System", Ports: []string{"53/tcp", "53/udp"}, Installed: lo.Contains(installedProfileNames, "DNS")},
-
-		// File sharing
-		{Name: "Samba", Title: "Windows file/printer sharing (Samba)", Ports: []string{"137,138/udp", "139,445/tcp"}, Installed: lo.Contains(installedProfileNames, "Samba")},
-		{Name: "NFS", Title: "Network File System", Ports: []string{"111,2049/tcp", "111,2049/udp"}, Installed: lo.Contains(installedProfileNames, "NFS")},
-
-		// Misc
-		{Name: "CUPS", Title: "Common Unix Printing System", Ports: []string{"631/tcp"}, Installed: lo.Contains(installedProfileNames, "CUPS")},
-		{Name: "VNC", Title: "Virtual Network Computing (remote desktop)", Ports: []string{"5900/tcp"}, Installed: lo.Contains(installedProfileNames, "VNC")},
-		{Name: "Deluge", Title: "Deluge BitTorrent client", Ports: []string{"6881/tcp", "6881/udp"}, Installed: lo.Contains(installedProfileNames, "Deluge")},
-		{Name: "Prometheus", Title: "Prometheus monitoring", Ports: []string{"9090/tcp"}, Installed: lo.Contains(installedProfileNames, "Prometheus")},
-		{Name: "Grafana", Title: "Grafana dashboards", Ports: []string{"3000/tcp"}, Installed: lo.Contains(installedProfileNames, "Grafana")},
-		{Name: "RabbitMQ", Title: "RabbitMQ message broker", Ports: []string{"5672,15672/tcp"}, Installed: lo.Contains(installedProfileNames, "RabbitMQ")},
-		{Name: "Mosquitto", Title: "Mosquitto MQTT broker", Ports: []string{"1883,8883/tcp"}, Installed: lo.Contains(installedProfileNames, "Mosquitto")},
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			profiles = append(profiles, UFWProfile{
+				Name:      strings.TrimSuffix(strings.TrimPrefix(line, "["), "]"),
+				Installed: true,
+			})
+			current = &profiles[len(profiles)-1]
+		case current != nil:
+			key, value, found := strings.Cut(line, "=")
+			if !found {
+				continue
+			}
+			switch strings.TrimSpace(key) {
+			case "title":
+				current.Title = strings.TrimSpace(value)
+			case "ports":
+				current.Ports = strings.Split(strings.TrimSpace(value), "|")
+			}
+		}
 	}
+	return profiles
+}
 
-	profiles = lo.Filter(profiles, func(p UFWProfile, _ int) bool {
-		return !p.Installed
-	})
+// PortsWithServices lists the port specs of the profile with the services they belong to.
+func (p UFWProfile) PortsWithServices() string {
+	db := services.Default()
+	return strings.Join(lo.Map(p.Ports, func(spec string, _ int) string {
+		if label := db.Label(spec); label != "" {
+			return fmt.Sprintf("%s (%s)", spec, label)
+		}
+		return spec
+	}), ", ")
+}
 
-	return profiles
+// predefinedProfiles can be installed from the profiles view, the builtin service list names their
+// ports.
+var predefinedProfiles = []UFWProfile{
+	// Common access
+	{Name: "OpenSSH", Title: "Secure shell access (SSH)", Ports: []string{"22/tcp"}},
+	{Name: "HTTP", Title: "Generic HTTP service", Ports: []string{"80/tcp"}},
+	{Name: "HTTPS", Title: "Generic HTTPS service", Ports: []string{"443/tcp"}},
+
+	// Web servers
+	{Name: "Nginx HTTP", Title: "Nginx web server (HTTP only)", Ports: []string{"80/tcp"}},
+	{Name: "Nginx HTTPS", Title: "Nginx web server (HTTPS only)", Ports: []string{"443/tcp"}},
+	{Name: "Nginx Full", Title: "Nginx web server (HTTP and HTTPS)", Ports: []string{"80,443/tcp"}},
+	{Name: "Apache", Title: "Apache web server (HTTP only)", Ports: []string{"80/tcp"}},
+	{Name: "Apache Secure", Title: "Apache web server (HTTPS only)", Ports: []string{"443/tcp"}},
+	{Name: "Apache Full", Title: "Apache web server (HTTP and HTTPS)", Ports: []string{"80,443/tcp"}},
+
+	// Databases
+	{Name: "PostgreSQL", Title: "PostgreSQL database server", Ports: []string{"5432/tcp"}},
+	{Name: "MySQL", Title: "MySQL database server", Ports: []string{"3306/tcp"}},
+	{Name: "MongoDB", Title: "MongoDB database", Ports: []string{"27017/tcp"}},
+	{Name: "Redis", Title: "Redis key-value store", Ports: []string{"6379/tcp"}},
+	{Name: "InfluxDB", Title: "InfluxDB time series database", Ports: []string{"8086/tcp"}},
+	{Name: "Elasticsearch", Title: "Elasticsearch search engine", Ports: []string{"9200,9300/tcp"}},
+
+	// DevOps / containers
+	{Name: "Docker Remote API", Title: "Docker remote API", Ports: []string{"2375,2376/tcp"}},
+	{Name: "Kubernetes API", Title: "Kubernetes API server", Ports: []string{"6443/tcp"}},
+	{Name: "Docker Swarm", Title: "Docker Swarm cluster communication", Ports: []string{"2377,7946/tcp", "7946,4789/udp"}},
+
+	// VPN
+	{Name: "WireGuard", Title: "WireGuard VPN", Ports: []string{"51820/udp"}},
+	{Name: "OpenVPN", Title: "OpenVPN", Ports: []string{"1194/udp"}},
+
+	// Email
+	{Name: "SMTP", Title: "Simple Mail Transfer Protocol", Ports: []string{"25/tcp"}},
+	{Name: "SMTPS", Title: "SMTP over SSL", Ports: []string{"465/tcp"}},
+	{Name: "Submission", Title: "Mail Submission Agent", Ports: []string{"587/tcp"}},
+	{Name: "IMAPS", Title: "IMAP over SSL", Ports: []string{"993/tcp"}},
+	{Name: "POP3S", Title: "POP3 over SSL", Ports: []string{"995/tcp"}},
+
+	// DNS
+	{Name: "DNS", Title: "Domain name System", Ports: []string{"53/tcp", "53/udp"}},
+
+	// File sharing
+	{Name: "Samba", Title: "Windows file/printer sharing (Samba)", Ports: []string{"137,138/udp", "139,445/tcp"}},
+	{Name: "NFS", Title: "Network File System", Ports: []string{"111,2049/tcp", "111,2049/udp"}},
+
+	// Misc
+	{Name: "CUPS", Title: "Common Unix Printing System", Ports: []string{"631"}},
+	{Name: "VNC", Title: "Virtual Network Computing (remote desktop)", Ports: []string{"5900/tcp"}},
+	{Name: "Deluge", Title: "Deluge BitTorrent client", Ports: []string{"6881/tcp", "6881/udp"}},
+	{Name: "Prometheus", Title: "Prometheus monitoring", Ports: []string{"9090/tcp"}},
+	{Name: "Grafana", Title: "Grafana dashboards", Ports: []string{"3000/tcp"}},
+	{Name: "RabbitMQ", Title: "RabbitMQ message broker", Ports: []string{"5672,15672/tcp"}},
+	{Name: "Mosquitto", Title: "Mosquitto MQTT broker", Ports: []string{"1883,8883/tcp"}},
+}
+
+// PredefinedProfiles are the profiles fwtui can install, whether they are installed or not.
+func PredefinedProfiles() []UFWProfile {
+	return append([]UFWProfile(nil), predefinedProfiles...)
+}
+
+// InstallableProfiles are the predefined profiles missing from the installed ones.
+func InstallableProfiles(installed []UFWProfile) []UFWProfile {
+	return lo.Filter(predefinedProfiles, func(p UFWProfile, _ int) bool {
+		return !lo.ContainsBy(installed, func(i UFWProfile) bool { return i.Name == p.Name })
+	})
 }
@@ -0,0 +1,102 @@
+package entity
+
+import (
+	"fwtui/domain/services"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+)
+
+// Hosts without /etc/services name the ports of the profiles from the builtin list alone.
+func TestPredefinedProfilePortsHaveServices(t *testing.T) {
+	checkServices(t, predefinedProfiles)
+}
+
+// installedProfiles reads the profiles the Debian and Ubuntu packages install into
+// /etc/ufw/applications.d.
+func installedProfiles(t *testing.T) []UFWProfile {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join("testdata", "applications.d", "*"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no profiles in testdata: %v", err)
+	}
+	var profiles []UFWProfile
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		profiles = append(profiles, parseProfileFile(string(content))...)
+	}
+	return profiles
+}
+
+func TestInstalledProfilePortsHaveServices(t *testing.T) {
+	checkServices(t, installedProfiles(t))
+}
+
+// A predefined profile named like one a package installs describes the same ports, so that the
+// rules naming it mean the same on hosts with and without the package.
+func TestInstallableProfilesAgreeWithInstalled(t *testing.T) {
+	installed := installedProfiles(t)
+	for _, p := range installed {
+		predefined, found := lo.Find(predefinedProfiles, func(d UFWProfile) bool { return d.Name == p.Name })
+		if found && strings.Join(predefined.Ports, "|") != strings.Join(p.Ports, "|") {
+			t.Errorf("%s: predefined ports %v, installed %v", p.Name, predefined.Ports, p.Ports)
+		}
+	}
+	for _, p := range InstallableProfiles(installed) {
+		if lo.ContainsBy(installed, func(i UFWProfile) bool { return i.Name == p.Name }) {
+			t.Errorf("%s is installed and still offered", p.Name)
+		}
+	}
+}
+
+func checkServices(t *testing.T, profiles []UFWProfile) {
+	t.Helper()
+	db := services.Builtin()
+	for _, profile := range profiles {
+		for _, spec := range profile.Ports {
+			ports, protocol, _ := strings.Cut(spec, "/")
+			for _, p := range strings.Split(ports, ",") {
+				port, err := strconv.Atoi(p)
+				if err != nil {
+					t.Errorf("%s: invalid port %q in %s", profile.Name, p, spec)
+					continue
+				}
+				if db.NameFor(port, protocol) == "" {
+					t.Errorf("%s: no builtin service for %d/%s", profile.Name, port, protocol)
+				}
+			}
+		}
+	}
+}
+
+func TestListedProfilesFollowUfw(t *testing.T) {
+	parsed := parseProfileFile(`[OpenSSH]
+title=Secure shell server
+ports=22/tcp
+
+[Broken]
+title=Rejected by ufw
+ports=99999/tcp
+`)
+	parsed = append(parsed, parseProfileFile("[Nginx Full]\ntitle=Web Server (Nginx, HTTP + HTTPS)\nports=80,443/tcp\n")...)
+	list := "Available applications:\n  Nginx Full\n  OpenSSH\n"
+
+	profiles := listedProfiles(list, parsed)
+	var names []string
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+	if strings.Join(names, ",") != "Nginx Full,OpenSSH" {
+		t.Fatalf("profiles = %v, want the ones ufw lists", names)
+	}
+	if p := profiles[1]; p.Title != "Secure shell server" || strings.Join(p.Ports, "|") != "22/tcp" || !p.Installed {
+		t.Errorf("OpenSSH = %+v, want the title and ports of its file", p)
+	}
+}
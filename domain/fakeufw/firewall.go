@@ -0,0 +1,581 @@
+// Package fakeufw is an in-memory stand-in for ufw. It understands the ufw commands fwtui runs and
+// writes its rules the way ufw writes user.rules and user6.rules, so the parsers of the ufw package
+// read its state like the one of the real firewall.
+package fakeufw
+
+import (
+	"encoding/hex"
+	"fmt"
+	"fwtui/domain/ufw"
+	"strconv"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+type Firewall struct {
+	Enabled       bool
+	IPv6          bool
+	Forwarding    bool // IP forwarding, ufw reports the routed policy as disabled without it
+	Logging       string
+	Incoming      string
+	Outgoing      string
+	ForwardPolicy string
+	Apps          map[string]string // application profiles by name with their ports, e.g. "22/tcp"
+
+	v4 []ufw.Rule
+	v6 []ufw.Rule
+}
+
+// New returns a firewall as ufw comes after installation: inactive, no rules, incoming denied.
+func New() *Firewall {
+	f := &Firewall{IPv6: true, Apps: map[string]string{}}
+	f.reset()
+	return f
+}
+
+func (f *Firewall) reset() {
+	f.Enabled = false
+	f.Logging = ufw.LoggingLow
+	f.Incoming, f.Outgoing, f.ForwardPolicy = "deny", "allow", "deny"
+	f.v4, f.v6 = nil, nil
+}
+
+// Routed is the routed policy as `ufw status verbose` shows it.
+func (f *Firewall) Routed() string {
+	if !f.Forwarding {
+		return "disabled"
+	}
+	return f.ForwardPolicy
+}
+
+// Rules reads the rules back through the parser of the ufw package, numbered like `ufw status
+// numbered` does.
+func (f *Firewall) Rules() []ufw.Rule {
+	v4, v6 := f.UserRules()
+	return ufw.ParseRuleTuples(v4, v6)
+}
+
+// Load replaces the rules, e.g. with the ones of a real host.
+func (f *Firewall) Load(rules []ufw.Rule) {
+	f.v4, f.v6 = nil, nil
+	for _, rule := range rules {
+		rule.Number, rule.Line = 0, ""
+		if rule.V6 {
+			f.v6 = append(f.v6, rule)
+		} else {
+			f.v4 = append(f.v4, rule)
+		}
+	}
+}
+
+// RunScript runs the ufw commands of a shell script line by line, skipping comments and other
+// commands. It stops at the first failing command like a script with `set -e` does.
+func (f *Firewall) RunScript(script string) string {
+	var outputs []string
+	for n, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || !strings.Contains(line, "ufw ") {
+			continue
+		}
+		output := f.Run(line)
+		outputs = append(outputs, output)
+		if strings.HasPrefix(output, "Error:") {
+			return fmt.Sprintf("Error: line %d: %s", n+1, strings.Join(outputs, ""))
+		}
+	}
+	return strings.Join(outputs, "")
+}
+
+// Run executes one command line like "sudo ufw allow 22/tcp" or "yes | sudo ufw delete 3". Like
+// oscmd.RunCommand the output starts with "Error:" when ufw would fail.
+func (f *Firewall) Run(command string) string {
+	tokens, err := split(command)
+	if err != nil {
+		return fmt.Sprintf("Error: %s\n", err)
+	}
+	args := ufwArgs(tokens)
+	if args == nil {
+		return fmt.Sprintf("Error: not a ufw command: %s\n", command)
+	}
+	if len(args) == 0 {
+		return "Error: missing command\n"
+	}
+
+	output, err := f.run(args)
+	if err != nil {
+		return fmt.Sprintf("Error: exit status 1\nERROR: %s\n", err)
+	}
+	return output
+}
+
+// ufwArgs drops what comes before the ufw arguments, "yes |", "sudo" and "ufw", as well as --force.
+func ufwArgs(tokens []string) []string {
+	for i, token := range tokens {
+		if token != "ufw" {
+			continue
+		}
+		args := []string{}
+		for _, arg := range tokens[i+1:] {
+			if arg != "--force" && arg != "-f" {
+				args = append(args, arg)
+			}
+		}
+		return args
+	}
+	return nil
+}
+
+func (f *Firewall) run(args []string) (string, error) {
+	switch args[0] {
+	case "enable":
+		f.Enabled = true
+		return "Firewall is active and enabled on system startup\n", nil
+	case "disable":
+		f.Enabled = false
+		return "Firewall stopped and disabled on system startup\n", nil
+	case "reload":
+		return "Firewall reloaded\n", nil
+	case "reset":
+		f.reset()
+		return "Resetting all rules to installed defaults.\n", nil
+	case "status":
+		if len(args) > 1 && args[1] == "numbered" {
+			return f.StatusNumbered(), nil
+		}
+		return f.StatusVerbose(), nil
+	case "default":
+		return f.setDefault(args[1:])
+	case "logging":
+		return f.setLogging(args[1:])
+	case "delete":
+		return f.delete(args[1:], false)
+	case "insert":
+		return f.insert(args[1:], false)
+	case "prepend":
+		return f.insert(append([]string{"1"}, args[1:]...), false)
+	case "route":
+		if len(args) > 1 && args[1] == "delete" {
+			return f.delete(args[2:], true)
+		}
+		if len(args) > 1 && args[1] == "insert" {
+			return f.insert(args[2:], true)
+		}
+		return f.add(args[1:], true)
+	case "app":
+		return f.app(args[1:])
+	default:
+		return f.add(args, false)
+	}
+}
+
+func (f *Firewall) setDefault(args []string) (string, error) {
+	if len(args) != 2 {
+		return "", fmt.Errorf("usage: ufw default allow|deny|reject [incoming|outgoing|routed]")
+	}
+	policy, direction := args[0], args[1]
+	if policy != "allow" && policy != "deny" && policy != "reject" {
+		return "", fmt.Errorf("invalid policy '%s'", policy)
+	}
+	switch direction {
+	case "incoming":
+		f.Incoming = policy
+	case "outgoing":
+		f.Outgoing = policy
+	case "routed":
+		f.ForwardPolicy = policy
+	default:
+		return "", fmt.Errorf("invalid direction '%s'", direction)
+	}
+	return fmt.Sprintf("Default %s policy changed to '%s'\n", direction, policy), nil
+}
+
+func (f *Firewall) setLogging(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: ufw logging on|off|LEVEL")
+	}
+	switch level := args[0]; level {
+	case "on":
+		f.Logging = ufw.LoggingLow
+	case ufw.LoggingOff, ufw.LoggingLow, ufw.LoggingMedium, ufw.LoggingHigh, ufw.LoggingFull:
+		f.Logging = level
+	default:
+		return "", fmt.Errorf("invalid log level '%s'", level)
+	}
+	if f.Logging == ufw.LoggingOff {
+		return "Logging disabled\n", nil
+	}
+	return "Logging enabled\n", nil
+}
+
+func (f *Firewall) app(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("usage: ufw app list|info|update")
+	}
+	switch args[0] {
+	case "list":
+		lines := []string{"Available applications:"}
+		for name := range f.Apps {
+			lines = append(lines, "  "+name)
+		}
+		return strings.Join(lines, "\n") + "\n", nil
+	case "update":
+		return "Rules updated for profile '" + strings.Join(args[1:], " ") + "'\n", nil
+	default:
+		return "", fmt.Errorf("unsupported app command '%s'", args[0])
+	}
+}
+
+// add appends a rule to its family, or to both when it names no address. A rule that exists
+// already is skipped, one that only differs in its comment gets the new comment.
+func (f *Firewall) add(args []string, route bool) (string, error) {
+	rules, err := f.parseRule(args, route)
+	if err != nil {
+		return "", err
+	}
+
+	var outputs []string
+	for _, rule := range rules {
+		list := f.family(rule.V6)
+		suffix := lo.Ternary(rule.V6, " (v6)", "")
+		if i := indexOf(*list, rule); i >= 0 {
+			if (*list)[i].Comment == rule.Comment {
+				outputs = append(outputs, "Skipping adding existing rule"+suffix)
+				continue
+			}
+			(*list)[i].Comment = rule.Comment
+			outputs = append(outputs, "Rule updated"+suffix)
+			continue
+		}
+		*list = append(*list, rule)
+		outputs = append(outputs, "Rule added"+suffix)
+	}
+	return strings.Join(outputs, "\n") + "\n", nil
+}
+
+// insert puts a rule at a position of `ufw status numbered`. The position has to point at a rule of
+// the same family, IPv6 rules are numbered behind the IPv4 ones.
+func (f *Firewall) insert(args []string, route bool) (string, error) {
+	if len(args) < 2 {
+		return "", fmt.Errorf("usage: ufw insert NUM RULE")
+	}
+	position, err := strconv.Atoi(args[0])
+	if err != nil || position < 1 {
+		return "", fmt.Errorf("invalid position '%s'", args[0])
+	}
+	rules, err := f.parseRule(args[1:], route)
+	if err != nil {
+		return "", err
+	}
+
+	count := len(f.v4) + len(f.visibleV6())
+	if position > count {
+		return "", fmt.Errorf("invalid position '%d'", position)
+	}
+
+	var outputs []string
+	for _, rule := range rules {
+		list := f.family(rule.V6)
+		var index int
+		switch {
+		case !rule.V6:
+			index = position - 1
+		case len(rules) == 2:
+			index = min(position-1, len(*list)) // the twin goes to the same place among the IPv6 rules
+		default:
+			index = position - 1 - len(f.v4)
+		}
+		if index < 0 || index > len(*list) || (index == len(*list) && len(rules) == 1) {
+			return "", fmt.Errorf("invalid position '%d'", position)
+		}
+		if indexOf(*list, rule) >= 0 {
+			outputs = append(outputs, "Skipping inserting existing rule")
+			continue
+		}
+		*list = append((*list)[:index], append([]ufw.Rule{rule}, (*list)[index:]...)...)
+		outputs = append(outputs, "Rule inserted")
+	}
+	return strings.Join(outputs, "\n") + "\n", nil
+}
+
+// delete removes a rule by its number or by its rule spec.
+func (f *Firewall) delete(args []string, route bool) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("usage: ufw delete NUM|RULE")
+	}
+	if number, err := strconv.Atoi(args[0]); err == nil && len(args) == 1 && !route {
+		switch {
+		case number >= 1 && number <= len(f.v4):
+			f.v4 = append(f.v4[:number-1], f.v4[number:]...)
+		case number > len(f.v4) && number <= len(f.v4)+len(f.visibleV6()):
+			i := number - len(f.v4) - 1
+			f.v6 = append(f.v6[:i], f.v6[i+1:]...)
+		default:
+			return "", fmt.Errorf("could not find rule '%d'", number)
+		}
+		return "Rule deleted\n", nil
+	}
+
+	rules, err := f.parseRule(args, route)
+	if err != nil {
+		return "", err
+	}
+	var outputs []string
+	for _, rule := range rules {
+		list := f.family(rule.V6)
+		i := indexOf(*list, rule)
+		if i < 0 {
+			outputs = append(outputs, "Could not delete non-existent rule")
+			continue
+		}
+		*list = append((*list)[:i], (*list)[i+1:]...)
+		outputs = append(outputs, "Rule deleted")
+	}
+	return strings.Join(outputs, "\n") + "\n", nil
+}
+
+func (f *Firewall) family(v6 bool) *[]ufw.Rule {
+	if v6 {
+		return &f.v6
+	}
+	return &f.v4
+}
+
+// visibleV6 are the IPv6 rules ufw numbers, none while IPv6 is off.
+func (f *Firewall) visibleV6() []ufw.Rule {
+	if !f.IPv6 {
+		return nil
+	}
+	return f.v6
+}
+
+// indexOf finds a rule matching the same traffic with the same action, the comment aside.
+func indexOf(rules []ufw.Rule, rule ufw.Rule) int {
+	rule.Comment = ""
+	for i, other := range rules {
+		other.Comment = ""
+		if other.ExactArgs() == rule.ExactArgs() {
+			return i
+		}
+	}
+	return -1
+}
+
+// UserRules writes the rules as ufw writes user.rules and user6.rules, leaving out the iptables
+// lines that follow each tuple.
+func (f *Firewall) UserRules() (v4, v6 string) {
+	return f.rulesFile(f.v4), f.rulesFile(f.v6)
+}
+
+func (f *Firewall) rulesFile(rules []ufw.Rule) string {
+	lines := []string{"*filter", "", "### RULES ###", ""}
+	for _, rule := range rules {
+		for _, tuple := range f.tuples(rule) {
+			lines = append(lines, tuple, "")
+		}
+	}
+	lines = append(lines, "### END RULES ###", "", "COMMIT", "")
+	return strings.Join(lines, "\n")
+}
+
+// tuples writes "action proto dport dst sport src [dapp sapp] direction [comment=hex]". Like ufw
+// it writes a tuple for every port entry of an application profile.
+func (f *Firewall) tuples(r ufw.Rule) []string {
+	action := r.Action
+	if r.Log != "" {
+		action += "_" + r.Log
+	}
+	if r.Route {
+		action = "route:" + action
+	}
+
+	var lines []string
+	for _, dest := range f.appPorts(r.DestApp, r.Protocol, r.DestPort) {
+		for _, src := range f.appPorts(r.SrcApp, r.Protocol, r.SrcPort) {
+			protocol := dest.proto
+			switch {
+			case protocol == "any":
+				protocol = src.proto
+			case src.proto != "any" && src.proto != protocol:
+				continue
+			}
+			fields := []string{action, protocol, dest.port, tupleAddr(r.Dest, r.V6), src.port, tupleAddr(r.Source, r.V6)}
+			if r.DestApp != "" || r.SrcApp != "" {
+				fields = append(fields, tupleApp(r.DestApp), tupleApp(r.SrcApp))
+			}
+			fields = append(fields, tupleDirection(r))
+
+			line := "### tuple ### " + strings.Join(fields, " ")
+			if r.Comment != "" {
+				line += " comment=" + hex.EncodeToString([]byte(r.Comment))
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+type portProto struct{ port, proto string }
+
+// appPorts lists the port entries of an application profile, or the rule's own port without one.
+func (f *Firewall) appPorts(app, protocol, port string) []portProto {
+	if app == "" {
+		return []portProto{{port, protocol}}
+	}
+	var entries []portProto
+	for _, entry := range strings.Split(f.Apps[app], "|") {
+		port, proto, found := strings.Cut(entry, "/")
+		if !found {
+			proto = "any"
+		}
+		if port == "" {
+			port = "any"
+		}
+		entries = append(entries, portProto{port, proto})
+	}
+	return entries
+}
+
+func tupleAddr(addr string, v6 bool) string {
+	switch {
+	case addr != "any":
+		return addr
+	case v6:
+		return "::/0"
+	default:
+		return "0.0.0.0/0"
+	}
+}
+
+func tupleApp(app string) string {
+	if app == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(app, " ", "%20")
+}
+
+func tupleDirection(r ufw.Rule) string {
+	if !r.Route {
+		if iface := r.Interface(); iface != "" {
+			return r.Direction + "_" + iface
+		}
+		return r.Direction
+	}
+	var parts []string
+	if r.InterfaceIn != "" {
+		parts = append(parts, "in_"+r.InterfaceIn)
+	}
+	if r.InterfaceOut != "" {
+		parts = append(parts, "out_"+r.InterfaceOut)
+	}
+	if len(parts) == 0 {
+		return "in"
+	}
+	return strings.Join(parts, "!")
+}
+
+// StatusVerbose imitates `ufw status verbose`.
+func (f *Firewall) StatusVerbose() string {
+	if !f.Enabled {
+		return "Status: inactive\n"
+	}
+	logging := "off"
+	if f.Logging != ufw.LoggingOff {
+		logging = fmt.Sprintf("on (%s)", f.Logging)
+	}
+	lines := []string{
+		"Status: active",
+		"Logging: " + logging,
+		fmt.Sprintf("Default: %s (incoming), %s (outgoing), %s (routed)", f.Incoming, f.Outgoing, f.Routed()),
+		"New profiles: skip",
+		"",
+	}
+	lines = append(lines, f.statusTable(false)...)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// StatusNumbered imitates `ufw status numbered`.
+func (f *Firewall) StatusNumbered() string {
+	if !f.Enabled {
+		return "Status: inactive\n"
+	}
+	lines := append([]string{"Status: active", ""}, f.statusTable(true)...)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (f *Firewall) statusTable(numbered bool) []string {
+	rules := append(append([]ufw.Rule{}, f.v4...), f.visibleV6()...)
+	if len(rules) == 0 {
+		return nil
+	}
+	prefix := func(n int) string {
+		if !numbered {
+			return ""
+		}
+		return fmt.Sprintf("[%2d] ", n)
+	}
+	indent := strings.Repeat(" ", len(prefix(0)))
+	lines := []string{
+		fmt.Sprintf("%s%-26s %-12s%s", indent, "To", "Action", "From"),
+		fmt.Sprintf("%s%-26s %-12s%s", indent, "--", "------", "----"),
+	}
+	for i, r := range rules {
+		to := statusEndpoint(r.Dest, r.DestPort, r.DestApp, r.Protocol, r.V6)
+		from := statusEndpoint(r.Source, r.SrcPort, r.SrcApp, r.Protocol, r.V6)
+		if iface := r.Interface(); !r.Route && iface != "" {
+			to += " on " + iface
+		}
+		action := strings.ToUpper(r.Action) + " " + strings.ToUpper(r.Direction)
+		if r.Route {
+			action = strings.ToUpper(r.Action) + " FWD"
+			if r.InterfaceOut != "" {
+				to += " on " + r.InterfaceOut
+			}
+			if r.InterfaceIn != "" {
+				from += " on " + r.InterfaceIn
+			}
+		}
+		// like ufw the source column is padded only when something follows it
+		var notes []string
+		if !r.Route && r.Direction == "out" {
+			notes = append(notes, "(out)")
+		}
+		if r.Comment != "" {
+			notes = append(notes, "# "+r.Comment)
+		}
+		line := fmt.Sprintf("%s%-26s %-12s%s", prefix(i+1), to, action, from)
+		if len(notes) > 0 {
+			line = fmt.Sprintf("%s%-26s %-12s%-26s %s", prefix(i+1), to, action, from, strings.Join(notes, " "))
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "")
+	return lines
+}
+
+func statusEndpoint(addr, port, app, protocol string, v6 bool) string {
+	var text string
+	switch {
+	case app != "":
+		text = app
+		if addr != "any" {
+			text = addr + " " + app
+		}
+	case port != "any":
+		text = port
+		if protocol != "any" {
+			text += "/" + protocol
+		}
+		if addr != "any" {
+			text = addr + " " + text
+		}
+	case addr != "any":
+		text = addr
+	default:
+		text = "Anywhere"
+	}
+	if v6 && addr == "any" {
+		text += " (v6)"
+	}
+	return text
+}
@@ -0,0 +1,57 @@
+package fakeufw
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readHostFile(t *testing.T, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("testdata", "host", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// tupleLines keeps the tuples of a rules file, the part fwtui reads back.
+func tupleLines(rules string) string {
+	var tuples []string
+	for _, line := range strings.Split(rules, "\n") {
+		if strings.HasPrefix(line, "### tuple ###") {
+			tuples = append(tuples, line)
+		}
+	}
+	return strings.Join(tuples, "\n")
+}
+
+// trimLines drops the trailing blanks ufw pads its status columns with.
+func trimLines(text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// TestMatchesHost runs commands.sh and compares the result with the rule files and the status
+// ufw leaves behind for the same commands, see commands.sh for how to capture them.
+func TestMatchesHost(t *testing.T) {
+	fw := New()
+	fw.Apps["OpenSSH"] = "22/tcp"
+	if output := fw.RunScript(readHostFile(t, "commands.sh")); strings.HasPrefix(output, "Error:") {
+		t.Fatal(output)
+	}
+
+	v4, v6 := fw.UserRules()
+	for _, file := range []struct{ name, got string }{{"user.rules", v4}, {"user6.rules", v6}} {
+		if got, want := tupleLines(file.got), tupleLines(readHostFile(t, file.name)); got != want {
+			t.Errorf("%s tuples =\n%s\nwant\n%s", file.name, got, want)
+		}
+	}
+	if got, want := trimLines(fw.StatusNumbered()), trimLines(readHostFile(t, "status-numbered.txt")); got != want {
+		t.Errorf("status numbered =\n%s\nwant\n%s", got, want)
+	}
+}
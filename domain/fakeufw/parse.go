@@ -0,0 +1,281 @@
+package fakeufw
+
+import (
+	"fmt"
+	"fwtui/domain/services"
+	"fwtui/domain/ufw"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var portPattern = regexp.MustCompile(`^[0-9]+([:,][0-9]+)*$`)
+
+// split breaks a command line into words the way bash does for the quoting fwtui uses: single and
+// double quotes, backslash escapes and "|" as a word of its own.
+func split(command string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	for i := 0; i < len(command); i++ {
+		c := rune(command[i])
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inWord = c, true
+		case c == '\\' && i+1 < len(command):
+			i++
+			word.WriteByte(command[i])
+			inWord = true
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '|':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			words = append(words, "|")
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %s", command)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// parseRule reads the full syntax "ACTION [in|out [on IFACE]] [log|log-all] [proto PROTO]
+// [from ADDR [port PORT|app APP]] [to ADDR [port PORT|app APP]] [comment TEXT]" as well as the
+// simple one "ACTION [in|out] PORT[/PROTO]|SERVICE|APP". A rule naming no address stands for both
+// families while IPv6 is on.
+func (f *Firewall) parseRule(args []string, route bool) ([]ufw.Rule, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("missing rule")
+	}
+	rule := ufw.Rule{
+		Action:    args[0],
+		Route:     route,
+		Direction: "in",
+		Protocol:  "any",
+		Source:    "any",
+		Dest:      "any",
+		SrcPort:   "any",
+		DestPort:  "any",
+	}
+	switch rule.Action {
+	case "allow", "deny", "reject", "limit":
+	default:
+		return nil, fmt.Errorf("invalid action '%s'", args[0])
+	}
+
+	families := map[string]bool{}
+	fullSyntax := false
+	next := func(i int, what string) (string, error) {
+		if i+1 >= len(args) {
+			return "", fmt.Errorf("missing %s after '%s'", what, args[i])
+		}
+		return args[i+1], nil
+	}
+
+	endpoint := "" // from or to, the one a port or app belongs to
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "in", "out":
+			if i+1 < len(args) && args[i+1] == "on" {
+				iface, err := next(i+1, "interface")
+				if err != nil {
+					return nil, err
+				}
+				if arg == "in" {
+					rule.InterfaceIn = iface
+				} else {
+					rule.InterfaceOut = iface
+				}
+				i += 2
+			}
+			if !route {
+				rule.Direction = arg
+			}
+		case "log", "log-all":
+			rule.Log = arg
+		case "proto":
+			proto, err := next(i, "protocol")
+			if err != nil {
+				return nil, err
+			}
+			if proto != "tcp" && proto != "udp" && proto != "any" {
+				return nil, fmt.Errorf("unsupported protocol '%s'", proto)
+			}
+			rule.Protocol = proto
+			i++
+		case "from", "to":
+			value, err := next(i, "address")
+			if err != nil {
+				return nil, err
+			}
+			addr, family, err := normalizeAddr(value)
+			if err != nil {
+				return nil, err
+			}
+			if family != "" {
+				families[family] = true
+			}
+			if arg == "from" {
+				rule.Source = addr
+			} else {
+				rule.Dest = addr
+			}
+			endpoint, fullSyntax = arg, true
+			i++
+		case "port":
+			value, err := next(i, "port")
+			if err != nil {
+				return nil, err
+			}
+			port, err := resolvePort(value)
+			if err != nil {
+				return nil, err
+			}
+			if endpoint == "from" {
+				rule.SrcPort = port
+			} else {
+				rule.DestPort = port
+			}
+			i++
+		case "app":
+			value, err := next(i, "application")
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := f.Apps[value]; !ok {
+				return nil, fmt.Errorf("could not find profile '%s'", value)
+			}
+			if endpoint == "from" {
+				rule.SrcApp = value
+			} else {
+				rule.DestApp = value
+			}
+			i++
+		case "comment":
+			value, err := next(i, "comment")
+			if err != nil {
+				return nil, err
+			}
+			rule.Comment = value
+			i++
+		default:
+			if fullSyntax || rule.DestPort != "any" || rule.DestApp != "" {
+				return nil, fmt.Errorf("wrong number of arguments near '%s'", arg)
+			}
+			if _, ok := f.Apps[arg]; ok {
+				rule.DestApp = arg
+				continue
+			}
+			port, proto, hasProto := strings.Cut(arg, "/")
+			resolved, err := resolvePort(port)
+			if err != nil {
+				return nil, err
+			}
+			rule.DestPort = resolved
+			if hasProto {
+				rule.Protocol = proto
+			}
+		}
+	}
+
+	if rule.Route && rule.InterfaceIn == "" && rule.InterfaceOut != "" {
+		rule.Direction = "out"
+	}
+	if (rule.DestApp != "" || rule.SrcApp != "") && rule.Protocol != "any" {
+		return nil, fmt.Errorf("improper rule syntax ('proto' can't be used with 'app')")
+	}
+	if rule.Protocol == "any" && (strings.ContainsAny(rule.DestPort, ":,") || strings.ContainsAny(rule.SrcPort, ":,")) {
+		return nil, fmt.Errorf("must specify 'tcp' or 'udp' with multiple ports")
+	}
+
+	switch {
+	case families["v4"] && families["v6"]:
+		return nil, fmt.Errorf("invalid address family, mixing IPv4 and IPv6")
+	case families["v6"] && !f.IPv6:
+		return nil, fmt.Errorf("IPv6 support not enabled")
+	case families["v6"]:
+		rule.V6 = true
+		return []ufw.Rule{rule}, nil
+	case families["v4"] || !f.IPv6:
+		return []ufw.Rule{rule}, nil
+	default:
+		twin := rule
+		twin.V6 = true
+		return []ufw.Rule{rule, twin}, nil
+	}
+}
+
+// resolvePort checks a port spec and swaps a service name for its number.
+func resolvePort(port string) (string, error) {
+	if portPattern.MatchString(port) {
+		for _, part := range strings.FieldsFunc(port, func(r rune) bool { return r == ':' || r == ',' }) {
+			if n, err := strconv.Atoi(part); err != nil || n < 1 || n > 65535 {
+				return "", fmt.Errorf("invalid port '%s'", port)
+			}
+		}
+		return port, nil
+	}
+	entries := services.Default().Lookup(port)
+	if len(entries) == 0 {
+		return "", fmt.Errorf("could not find a profile matching '%s'", port)
+	}
+	return strconv.Itoa(entries[0].Port), nil
+}
+
+// normalizeAddr writes an address like ufw stores it: masked, single hosts without prefix length
+// and "any" for the whole address space. The family is empty for "any", which means both.
+func normalizeAddr(addr string) (string, string, error) {
+	if addr == "any" {
+		return "any", "", nil
+	}
+	var prefix netip.Prefix
+	var err error
+	if strings.Contains(addr, "/") {
+		prefix, err = netip.ParsePrefix(addr)
+	} else {
+		var ip netip.Addr
+		ip, err = netip.ParseAddr(addr)
+		prefix = netip.PrefixFrom(ip, ip.BitLen())
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("bad address '%s'", addr)
+	}
+
+	prefix = prefix.Masked()
+	family := "v4"
+	if prefix.Addr().Is6() {
+		family = "v6"
+	}
+	switch {
+	case prefix.Bits() == 0:
+		return "any", family, nil
+	case prefix.IsSingleIP():
+		return prefix.Addr().String(), family, nil
+	default:
+		return prefix.String(), family, nil
+	}
+}
@@ -0,0 +1,21 @@
+#!/bin/sh
+# Builds the ruleset of user.rules, user6.rules and status-numbered.txt on a freshly reset ufw with
+# the OpenSSH profile of openssh-server installed. To capture the files again on such a host:
+#   sudo sh commands.sh
+#   sudo cp /etc/ufw/user.rules /etc/ufw/user6.rules .
+#   sudo ufw status numbered > status-numbered.txt
+set -e
+ufw --force reset
+ufw allow 22/tcp
+ufw allow from 10.0.0.0/8 to any port 5432 proto tcp comment 'postgres'
+ufw deny out 25/tcp
+ufw allow from 2001:db8::/32 to any port 443 proto tcp
+ufw allow from 192.168.1.0/24 to any app OpenSSH
+ufw limit 2222/tcp
+ufw route allow in on wg0 out on eth0 from 10.8.0.0/24
+# an IPv4 rule at the top, then a rule for both families whose IPv6 half goes to the top of the
+# IPv6 rules, then an IPv6 rule placed by its number among the IPv6 rules
+ufw insert 1 deny from 203.0.113.0/24
+ufw insert 1 allow 80/tcp comment 'web'
+ufw insert 11 deny from 2001:db8:bad::/48
+ufw --force enable
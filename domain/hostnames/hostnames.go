@@ -0,0 +1,124 @@
+package hostnames
+
+import (
+	"context"
+	"fmt"
+	"fwtui/domain/ufw"
+	"net"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// commentPrefix marks the rules created for a hostname, the comment keeps the name so that the
+// addresses can be looked up again later.
+const commentPrefix = "host:"
+
+const lookupTimeout = 5 * time.Second
+
+// Resolver looks up the addresses of a host, *net.Resolver satisfies it.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+var DefaultResolver Resolver = net.DefaultResolver
+
+var hostnamePattern = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?\.)*[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?\.?$`)
+
+// IsHostname tells whether the value is a host name rather than an address or network.
+func IsHostname(value string) bool {
+	if _, err := netip.ParseAddr(value); err == nil {
+		return false
+	}
+	if _, err := netip.ParsePrefix(value); err == nil {
+		return false
+	}
+	return len(value) <= 253 && strings.ContainsAny(value, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ") &&
+		hostnamePattern.MatchString(value)
+}
+
+// Resolve returns the IPv4 and IPv6 addresses of the host, sorted and without duplicates.
+func Resolve(resolver Resolver, host string) ([]netip.Addr, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
+	found, err := resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", host, err)
+	}
+
+	seen := map[netip.Addr]bool{}
+	var addrs []netip.Addr
+	for _, addr := range found {
+		addr = addr.Unmap()
+		if !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("%s has no addresses", host)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].Less(addrs[j])
+	})
+	return addrs, nil
+}
+
+// Comment builds the comment of a rule created for the host, keeping the comment the user typed.
+func Comment(host, comment string) string {
+	if comment == "" {
+		return commentPrefix + host
+	}
+	return commentPrefix + host + " " + comment
+}
+
+// FromComment returns the host recorded in a rule comment.
+func FromComment(comment string) (string, bool) {
+	if !strings.HasPrefix(comment, commentPrefix) {
+		return "", false
+	}
+	host, _, _ := strings.Cut(strings.TrimPrefix(comment, commentPrefix), " ")
+	return host, host != ""
+}
+
+// Change is what re-resolving the rules of a host changes: rules to add for new addresses and rules
+// to delete for addresses the host no longer has.
+type Change struct {
+	Set    ufw.RuleSet
+	Add    []ufw.Rule
+	Delete []ufw.Rule
+	Err    error
+}
+
+// GroupRules collects the rules created for host names.
+func GroupRules(rules []ufw.Rule) []ufw.RuleSet {
+	return ufw.GroupRuleSets(rules, FromComment)
+}
+
+// Reresolve looks up the host of every rule set again and compares the addresses with the rules.
+func Reresolve(resolver Resolver, sets []ufw.RuleSet) []Change {
+	resolved := map[string][]string{}
+	errs := map[string]error{}
+
+	var changes []Change
+	for _, set := range sets {
+		if _, done := resolved[set.Tag]; !done && errs[set.Tag] == nil {
+			addrs, err := Resolve(resolver, set.Tag)
+			resolved[set.Tag] = lo.Map(addrs, func(addr netip.Addr, _ int) string {
+				return addr.String()
+			})
+			errs[set.Tag] = err
+		}
+		change := Change{Set: set, Err: errs[set.Tag]}
+		if change.Err == nil {
+			change.Add, change.Delete = set.Diff(resolved[set.Tag])
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
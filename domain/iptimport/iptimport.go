@@ -0,0 +1,365 @@
+// Package iptimport turns the filter table of `iptables-save` or `ip6tables-save` output into ufw
+// rules. Only the plain INPUT and OUTPUT rules ufw can express are converted, everything else is
+// listed with the reason it was left out.
+package iptimport
+
+import (
+	"fmt"
+	"fwtui/domain/ufw"
+	"fwtui/utils/oscmd"
+	"net/netip"
+	"strings"
+)
+
+// Result is what an iptables-save file turns into.
+type Result struct {
+	V6       bool
+	Rules    []ufw.Rule
+	Defaults map[string]string // incoming and outgoing, from the policies of INPUT and OUTPUT
+	Skipped  []Skipped
+
+	lines []Skipped // the line each rule comes from, without a reason
+}
+
+// Skipped is a line of the file that has no ufw equivalent.
+type Skipped struct {
+	Line   int
+	Text   string
+	Reason string
+}
+
+func (s Skipped) String() string {
+	return fmt.Sprintf("line %d: %s (%s)", s.Line, s.Text, s.Reason)
+}
+
+var actions = map[string]string{"ACCEPT": "allow", "DROP": "deny", "REJECT": "reject"}
+
+var directions = map[string]string{"INPUT": "in", "OUTPUT": "out"}
+
+// Parse reads iptables-save output. The family is IPv6 when the file comes from ip6tables-save or
+// names IPv6 addresses.
+func Parse(content string) Result {
+	result := Result{V6: isIPv6(content), Defaults: map[string]string{}}
+	table := ""
+
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		skip := func(reason string) {
+			result.Skipped = append(result.Skipped, Skipped{Line: i + 1, Text: line, Reason: reason})
+		}
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || line == "COMMIT":
+		case strings.HasPrefix(line, "*"):
+			table = strings.TrimPrefix(line, "*")
+		case table != "filter":
+			if strings.HasPrefix(line, "-A ") || strings.HasPrefix(line, "[") {
+				skip(fmt.Sprintf("only the filter table is imported, not %s", table))
+			}
+		case strings.HasPrefix(line, ":"):
+			fields := strings.Fields(strings.TrimPrefix(line, ":"))
+			if len(fields) < 2 {
+				continue
+			}
+			direction, ok := map[string]string{"INPUT": "incoming", "OUTPUT": "outgoing"}[fields[0]]
+			if policy, known := actions[fields[1]]; ok && known {
+				result.Defaults[direction] = policy
+			}
+		default:
+			rule, reason := parseRule(line, result.V6)
+			if reason != "" {
+				skip(reason)
+				continue
+			}
+			rule.Number = len(result.Rules) + 1
+			result.Rules = append(result.Rules, rule)
+			result.lines = append(result.lines, Skipped{Line: i + 1, Text: line})
+		}
+	}
+	return result
+}
+
+func isIPv6(content string) bool {
+	if strings.Contains(content, "ip6tables-save") {
+		return true
+	}
+	for _, line := range strings.Split(content, "\n") {
+		words, err := split(line)
+		if err != nil {
+			continue
+		}
+		for i := 0; i+1 < len(words); i++ {
+			if isAddrOption(words[i]) && strings.Contains(words[i+1], ":") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isAddrOption(option string) bool {
+	return option == "-s" || option == "--source" || option == "-d" || option == "--destination"
+}
+
+// parseRule converts one "-A" line, the reason is set when the line can't be expressed in ufw.
+func parseRule(line string, v6 bool) (ufw.Rule, string) {
+	words, err := split(line)
+	if err != nil {
+		return ufw.Rule{}, err.Error()
+	}
+	if len(words) > 0 && strings.HasPrefix(words[0], "[") {
+		words = words[1:] // packet and byte counters of iptables-save -c
+	}
+	if len(words) < 2 || words[0] != "-A" {
+		return ufw.Rule{}, "not a rule"
+	}
+
+	rule := ufw.Rule{
+		Protocol: "any",
+		Source:   "any",
+		Dest:     "any",
+		SrcPort:  "any",
+		DestPort: "any",
+		V6:       v6,
+	}
+	direction, ok := directions[words[1]]
+	if !ok {
+		return ufw.Rule{}, fmt.Sprintf("chain %s is not INPUT or OUTPUT", words[1])
+	}
+	rule.Direction = direction
+
+	for i := 2; i < len(words); i++ {
+		option := words[i]
+		value := ""
+		if i+1 < len(words) {
+			value = words[i+1]
+		}
+		if option == "!" || value == "!" {
+			return ufw.Rule{}, "ufw has no negated matches"
+		}
+
+		switch option {
+		case "-i", "--in-interface", "-o", "--out-interface":
+			if strings.HasSuffix(value, "+") {
+				return ufw.Rule{}, "ufw has no interface wildcards"
+			}
+			if (option == "-i" || option == "--in-interface") != (direction == "in") {
+				return ufw.Rule{}, fmt.Sprintf("%s does not fit chain %s", option, words[1])
+			}
+			if direction == "in" {
+				rule.InterfaceIn = value
+			} else {
+				rule.InterfaceOut = value
+			}
+		case "-s", "--source", "-d", "--destination":
+			addr, addrV6, err := normalizeAddr(value)
+			if err != nil {
+				return ufw.Rule{}, err.Error()
+			}
+			if addr != "any" && addrV6 != v6 {
+				return ufw.Rule{}, "the address does not fit the family of the file"
+			}
+			if option == "-s" || option == "--source" {
+				rule.Source = addr
+			} else {
+				rule.Dest = addr
+			}
+		case "-p", "--protocol":
+			switch value {
+			case "tcp", "udp":
+				rule.Protocol = value
+			case "all":
+			case "icmp", "ipv6-icmp", "icmpv6":
+				return ufw.Rule{}, "ICMP is handled by ufw's before.rules"
+			default:
+				return ufw.Rule{}, fmt.Sprintf("ufw has no rules for protocol %s", value)
+			}
+		case "-m", "--match":
+			switch value {
+			case "tcp", "udp", "multiport", "comment", "conntrack", "state":
+			default:
+				return ufw.Rule{}, fmt.Sprintf("ufw can't express the %s match", value)
+			}
+		case "--dport", "--destination-port", "--dports", "--destination-ports":
+			rule.DestPort = value
+		case "--sport", "--source-port", "--sports", "--source-ports":
+			rule.SrcPort = value
+		case "--ctstate", "--state":
+			if !strings.Contains(value, "NEW") {
+				return ufw.Rule{}, "ufw's before.rules handle established, related and invalid traffic"
+			}
+		case "--comment":
+			rule.Comment = value
+		case "-j", "--jump":
+			action, ok := actions[value]
+			if !ok {
+				return ufw.Rule{}, fmt.Sprintf("target %s is not ACCEPT, DROP or REJECT", value)
+			}
+			rule.Action = action
+		case "--reject-with":
+		default:
+			return ufw.Rule{}, fmt.Sprintf("ufw can't express %s", option)
+		}
+		i++
+	}
+
+	switch {
+	case rule.Action == "":
+		return ufw.Rule{}, "the rule has no ACCEPT, DROP or REJECT target"
+	case rule.Action == "allow" && rule.Interface() == "lo" && rule.Source == "any" && rule.Dest == "any" &&
+		rule.Protocol == "any":
+		return ufw.Rule{}, "ufw's before.rules accept loopback traffic already"
+	}
+	return rule, ""
+}
+
+// normalizeAddr writes an address like ufw stores it and tells whether it is an IPv6 one.
+func normalizeAddr(addr string) (string, bool, error) {
+	var prefix netip.Prefix
+	var err error
+	if strings.Contains(addr, "/") {
+		prefix, err = netip.ParsePrefix(addr)
+	} else {
+		var ip netip.Addr
+		ip, err = netip.ParseAddr(addr)
+		prefix = netip.PrefixFrom(ip, ip.BitLen())
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("bad address %s", addr)
+	}
+
+	prefix = prefix.Masked()
+	v6 := prefix.Addr().Is6()
+	switch {
+	case prefix.Bits() == 0:
+		return "any", v6, nil
+	case prefix.IsSingleIP():
+		return prefix.Addr().String(), v6, nil
+	default:
+		return prefix.String(), v6, nil
+	}
+}
+
+// split breaks a rule line into words, iptables-save double quotes comments and escapes quotes in
+// them with a backslash.
+func split(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord, quoted := false, false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line):
+			i++
+			word.WriteByte(line[i])
+			inWord = true
+		case c == '"':
+			quoted, inWord = !quoted, true
+		case (c == ' ' || c == '\t') && !quoted:
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// Staged is one change the import offers: a rule to add or a default policy to set.
+type Staged struct {
+	Text    string
+	Command string
+	Exists  bool // ufw has the rule or policy already
+}
+
+// maxPorts is the most ports ufw takes in one rule, a range counts as two like in iptables' multiport.
+const maxPorts = 15
+
+// Stage lists the changes of an import against the current rules and policies. Rules get the exact
+// arguments, an IPv4 rule stays without its IPv6 twin like in the file it came from. Rules ufw would
+// refuse for their number of ports are listed as skipped instead.
+func Stage(result Result, rules []ufw.Rule, defaults map[string]string) ([]Staged, []Skipped) {
+	existing := map[string]bool{}
+	for _, rule := range rules {
+		existing[rule.ExactArgs()] = true
+	}
+
+	var staged []Staged
+	for _, direction := range []string{"incoming", "outgoing"} {
+		policy, ok := result.Defaults[direction]
+		if !ok {
+			continue
+		}
+		staged = append(staged, Staged{
+			Text:    fmt.Sprintf("default %s %s", policy, direction),
+			Command: fmt.Sprintf("sudo ufw default %s %s", policy, direction),
+			Exists:  defaults[direction] == policy,
+		})
+	}
+	var skipped []Skipped
+	for i, rule := range result.Rules {
+		if portCount(rule.DestPort) > maxPorts || portCount(rule.SrcPort) > maxPorts {
+			line := Skipped{Text: rule.Summary()}
+			if i < len(result.lines) {
+				line = result.lines[i]
+			}
+			line.Reason = fmt.Sprintf("ufw takes at most %d ports in a rule", maxPorts)
+			skipped = append(skipped, line)
+			continue
+		}
+		args := rule.ExactArgs()
+		staged = append(staged, Staged{
+			Text:    rule.Summary(),
+			Command: "sudo ufw " + args,
+			Exists:  existing[args],
+		})
+		existing[args] = true
+	}
+	return staged, skipped
+}
+
+// portCount counts the ports of a list like "80,443,8000:8080", a range takes two.
+func portCount(ports string) int {
+	if ports == "any" {
+		return 0
+	}
+	count := 0
+	for _, part := range strings.Split(ports, ",") {
+		count += 1 + strings.Count(part, ":")
+	}
+	return count
+}
+
+// Apply backs up the rules and runs the staged changes in order, it stops at the first failing one.
+func Apply(staged []Staged) (string, error) {
+	if len(staged) == 0 {
+		return "Nothing to import.", nil
+	}
+	path, err := ufw.Backup()
+	if err != nil {
+		return "", fmt.Errorf("backup failed, nothing applied: %w", err)
+	}
+	outputs := []string{"Backup: " + path}
+
+	for _, s := range staged {
+		output := strings.TrimSpace(oscmd.RunCommand(s.Command))
+		outputs = append(outputs, "+ "+s.Text)
+		if output != "" {
+			outputs = append(outputs, "  "+strings.ReplaceAll(output, "\n", "\n  "))
+		}
+		if strings.HasPrefix(output, "Error:") {
+			return strings.Join(outputs, "\n"), fmt.Errorf("%s failed, restore %s to roll back", s.Text, path)
+		}
+	}
+	return strings.Join(outputs, "\n"), nil
+}
@@ -0,0 +1,176 @@
+package iptimport
+
+import (
+	"fmt"
+	"fwtui/domain/ufw"
+	"strings"
+	"testing"
+)
+
+// save wraps rule lines in the filter table of iptables-save output, or of ip6tables-save output.
+func save(v6 bool, rules ...string) string {
+	tool := "iptables-save"
+	if v6 {
+		tool = "ip6tables-save"
+	}
+	lines := []string{
+		"# Generated by " + tool + " v1.8.10 (nf_tables) on Sun Oct 18 12:00:00 2026",
+		"*filter",
+		":INPUT DROP [0:0]",
+		":FORWARD DROP [0:0]",
+		":OUTPUT ACCEPT [0:0]",
+	}
+	lines = append(append(lines, rules...), "COMMIT", "# Completed on Sun Oct 18 12:00:00 2026")
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		rule    string // the ufw arguments of the one rule the line turns into
+		reason  string // or the reason it is skipped
+	}{
+		{
+			name:    "a port",
+			content: save(false, "-A INPUT -p tcp -m tcp --dport 22 -j ACCEPT"),
+			rule:    "allow in proto tcp from 0.0.0.0/0 to 0.0.0.0/0 port 22",
+		},
+		{
+			name:    "multiport",
+			content: save(false, "-A INPUT -p tcp -m multiport --dports 80,443,8000:8080 -j ACCEPT"),
+			rule:    "allow in proto tcp from 0.0.0.0/0 to 0.0.0.0/0 port 80,443,8000:8080",
+		},
+		{
+			name:    "addresses, interface and comment",
+			content: save(false, `-A INPUT -s 10.0.0.0/8 -d 192.0.2.1/32 -i eth0 -p udp -m udp --sport 53 -m comment --comment "dns \"lan\"" -j REJECT --reject-with icmp-port-unreachable`),
+			rule:    `reject in on eth0 proto udp from 10.0.0.0/8 port 53 to 192.0.2.1 comment 'dns "lan"'`,
+		},
+		{
+			name:    "new connections only",
+			content: save(false, "-A OUTPUT -p tcp -m conntrack --ctstate NEW -m tcp --dport 25 -j DROP"),
+			rule:    "deny out proto tcp from 0.0.0.0/0 to 0.0.0.0/0 port 25",
+		},
+		{
+			name:    "counters of iptables-save -c",
+			content: save(false, "[12:3456] -A INPUT -p tcp -m tcp --dport 443 -j ACCEPT"),
+			rule:    "allow in proto tcp from 0.0.0.0/0 to 0.0.0.0/0 port 443",
+		},
+		{
+			name:    "an IPv6 file",
+			content: save(true, "-A INPUT -s 2001:db8::/32 -p tcp -m tcp --dport 22 -j ACCEPT"),
+			rule:    "allow in proto tcp from 2001:db8::/32 to ::/0 port 22",
+		},
+		{
+			name:    "negated address",
+			content: save(false, "-A INPUT ! -s 10.0.0.0/8 -j DROP"),
+			reason:  "ufw has no negated matches",
+		},
+		{
+			name:    "negated port",
+			content: save(false, "-A INPUT -p tcp -m tcp ! --dport 22 -j DROP"),
+			reason:  "ufw has no negated matches",
+		},
+		{
+			name:    "established connections",
+			content: save(false, "-A INPUT -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT"),
+			reason:  "ufw's before.rules handle established, related and invalid traffic",
+		},
+		{
+			name:    "an interface of the other direction",
+			content: save(false, "-A INPUT -o eth0 -j ACCEPT"),
+			reason:  "-o does not fit chain INPUT",
+		},
+		{
+			name:    "loopback",
+			content: save(false, "-A INPUT -i lo -j ACCEPT"),
+			reason:  "ufw's before.rules accept loopback traffic already",
+		},
+		{
+			name:    "an IPv4 address in an IPv6 file",
+			content: save(true, "-A INPUT -s 192.0.2.0/24 -j DROP"),
+			reason:  "the address does not fit the family of the file",
+		},
+		{
+			name:    "another chain",
+			content: save(false, "-A FORWARD -i wg0 -j ACCEPT"),
+			reason:  "chain FORWARD is not INPUT or OUTPUT",
+		},
+		{
+			name:    "another target",
+			content: save(false, "-A INPUT -p tcp -m tcp --dport 22 -j LOG"),
+			reason:  "target LOG is not ACCEPT, DROP or REJECT",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Parse(tc.content)
+			var rules, reasons []string
+			for _, r := range result.Rules {
+				rules = append(rules, r.ExactArgs())
+			}
+			for _, s := range result.Skipped {
+				reasons = append(reasons, s.Reason)
+			}
+			if got := strings.Join(rules, "; "); got != tc.rule {
+				t.Errorf("rules = %q, want %q", got, tc.rule)
+			}
+			if got := strings.Join(reasons, "; "); got != tc.reason {
+				t.Errorf("skipped = %q, want %q", got, tc.reason)
+			}
+			if len(result.Skipped) > 0 && result.Skipped[0].Line != 6 {
+				t.Errorf("skipped line %d, want 6", result.Skipped[0].Line)
+			}
+		})
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	result := Parse(save(false))
+	if fmt.Sprint(result.Defaults) != "map[incoming:deny outgoing:allow]" {
+		t.Errorf("defaults = %v, want incoming deny and outgoing allow", result.Defaults)
+	}
+}
+
+func TestStage(t *testing.T) {
+	ports := func(n int) string {
+		list := make([]string, n)
+		for i := range list {
+			list[i] = fmt.Sprint(1000 + i)
+		}
+		return strings.Join(list, ",")
+	}
+	result := Parse(save(false,
+		"-A INPUT -p tcp -m tcp --dport 22 -j ACCEPT",
+		"-A INPUT -p tcp -m multiport --dports "+ports(13)+",2000:2010 -j ACCEPT",
+		"-A INPUT -p tcp -m multiport --dports "+ports(16)+" -j ACCEPT",
+		"-A INPUT -p udp -m multiport --dports "+ports(14)+",2000:2010 -j ACCEPT",
+	))
+	live := []ufw.Rule{{Action: "allow", Direction: "in", Protocol: "tcp", DestPort: "22", SrcPort: "any", Source: "any", Dest: "any"}}
+	staged, skipped := Stage(result, live, map[string]string{"incoming": "deny", "outgoing": "deny"})
+
+	var got []string
+	for _, s := range staged {
+		got = append(got, fmt.Sprintf("%s exists=%t", s.Command, s.Exists))
+	}
+	want := []string{
+		"sudo ufw default deny incoming exists=true",
+		"sudo ufw default allow outgoing exists=false",
+		"sudo ufw allow in proto tcp from 0.0.0.0/0 to 0.0.0.0/0 port 22 exists=true",
+		"sudo ufw allow in proto tcp from 0.0.0.0/0 to 0.0.0.0/0 port " + ports(13) + ",2000:2010 exists=false",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("staged =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// 16 single ports and 14 with a range, which counts twice, are more than ufw takes
+	var lines []int
+	for _, s := range skipped {
+		lines = append(lines, s.Line)
+		if s.Reason != "ufw takes at most 15 ports in a rule" {
+			t.Errorf("line %d skipped for %q", s.Line, s.Reason)
+		}
+	}
+	if fmt.Sprint(lines) != "[8 9]" {
+		t.Errorf("skipped lines = %v, want [8 9]", lines)
+	}
+}
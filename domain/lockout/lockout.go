@@ -0,0 +1,135 @@
+// Package lockout keeps remote changes from cutting off SSH. A change is replayed on the fake firewall
+// first and refused when SSH could be reached before it and can't be reached afterwards.
+package lockout
+
+import (
+	"fmt"
+	"fwtui/domain/entity"
+	"fwtui/domain/fakeufw"
+	"fwtui/domain/sockets"
+	"fwtui/domain/ufw"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+const sshPort = 22
+
+// probe addresses standing for a client anywhere on the internet
+var (
+	anyV4 = netip.MustParseAddr("203.0.113.10")
+	anyV6 = netip.MustParseAddr("2001:db8::10")
+)
+
+// Firewall is the part of the firewall state the check replays the change on.
+type Firewall struct {
+	Enabled  bool
+	Rules    []ufw.Rule
+	Incoming string
+	Profiles []entity.UFWProfile
+}
+
+// SSHPorts are the ports sshd listens on, 22 when no sshd socket is found.
+func SSHPorts(listening []sockets.Socket) []int {
+	var ports []int
+	for _, s := range listening {
+		if s.Protocol == "tcp" && s.Process == "sshd" && !s.IsLocalOnly() && !lo.Contains(ports, s.Port) {
+			ports = append(ports, s.Port)
+		}
+	}
+	if len(ports) == 0 {
+		return []int{sshPort}
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// Check replays the ufw commands on a copy of the firewall and returns an error when they leave no
+// way to reach an SSH port over IPv4 or IPv6 that could be reached before. An inactive firewall blocks
+// nothing, so every change passes.
+func Check(fw Firewall, commands []string, ports []int) error {
+	if !fw.Enabled || len(commands) == 0 {
+		return nil
+	}
+
+	fake := fakeufw.New()
+	fake.Enabled = true
+	fake.Incoming = fw.Incoming
+	for _, p := range fw.Profiles {
+		fake.Apps[p.Name] = strings.Join(p.Ports, "|")
+	}
+	for _, rule := range fw.Rules {
+		for _, app := range []string{rule.DestApp, rule.SrcApp} {
+			if _, ok := fake.Apps[app]; app != "" && !ok {
+				fake.Apps[app] = ""
+			}
+		}
+	}
+	fake.Load(fw.Rules)
+	before := fake.Rules()
+
+	for _, command := range commands {
+		if output := fake.Run(command); strings.HasPrefix(output, "Error:") {
+			return fmt.Errorf("%s fails: %s", command, strings.TrimSpace(strings.TrimPrefix(output, "Error:")))
+		}
+	}
+	after := fake.Rules()
+
+	for _, port := range ports {
+		for _, v6 := range []bool{false, true} {
+			probes := lo.Filter(probes(before, port), func(p ufw.Packet, _ int) bool { return p.Source.Is6() == v6 })
+			if reachable(before, fw.Incoming, probes) && !reachable(after, fake.Incoming, probes) {
+				return fmt.Errorf("the change would block SSH on port %d over %s, nothing was changed",
+					port, lo.Ternary(v6, "IPv6", "IPv4"))
+			}
+		}
+	}
+	return nil
+}
+
+// probes are the SSH connections to try: from anywhere, and from the sources and interfaces of the
+// rules letting SSH in.
+func probes(rules []ufw.Rule, port int) []ufw.Packet {
+	packets := []ufw.Packet{
+		{Direction: ufw.PacketIn, Protocol: "tcp", Source: anyV4, Dest: anyV4, Port: port},
+		{Direction: ufw.PacketIn, Protocol: "tcp", Source: anyV6, Dest: anyV6, Port: port},
+	}
+	for _, rule := range rules {
+		if rule.Route || rule.Direction != "in" || !rule.IsAllowing() || !rule.MatchesPort(port, "tcp") {
+			continue
+		}
+		fallback := lo.Ternary(rule.V6, anyV6, anyV4)
+		packets = append(packets, ufw.Packet{
+			Direction: ufw.PacketIn,
+			Protocol:  "tcp",
+			Source:    addrIn(rule.Source, fallback),
+			Dest:      addrIn(rule.Dest, fallback),
+			Port:      port,
+			Interface: rule.Interface(),
+		})
+	}
+	return packets
+}
+
+// addrIn picks an address a rule's address matches, the first one of a subnet.
+func addrIn(addr string, fallback netip.Addr) netip.Addr {
+	if addr == "any" {
+		return fallback
+	}
+	if prefix, err := netip.ParsePrefix(addr); err == nil {
+		return prefix.Masked().Addr()
+	}
+	if ip, err := netip.ParseAddr(addr); err == nil {
+		return ip
+	}
+	return fallback
+}
+
+func reachable(rules []ufw.Rule, incoming string, probes []ufw.Packet) bool {
+	return lo.SomeBy(probes, func(p ufw.Packet) bool {
+		verdict := ufw.Simulate(rules, incoming, p)
+		return verdict.Action == "allow" || verdict.Action == "limit"
+	})
+}
@@ -0,0 +1,291 @@
+package playbooks
+
+import (
+	"fmt"
+	"fwtui/domain/entity"
+	"fwtui/domain/fakeufw"
+	"fwtui/domain/ufw"
+	"fwtui/utils/oscmd"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// commentPrefix tags the rules a playbook creates.
+const commentPrefix = "playbook:"
+
+// cloudflareRanges are the published Cloudflare edge networks, https://www.cloudflare.com/ips/.
+var cloudflareRanges = []string{
+	"173.245.48.0/20", "103.21.244.0/22", "103.22.200.0/22", "103.31.4.0/22", "141.101.64.0/18",
+	"108.162.192.0/18", "190.93.240.0/20", "188.114.96.0/20", "197.234.240.0/22", "198.41.128.0/17",
+	"162.158.0.0/15", "104.16.0.0/13", "104.24.0.0/14", "172.64.0.0/13", "131.0.72.0/22",
+	"2400:cb00::/32", "2606:4700::/32", "2803:f800::/32", "2405:b500::/32", "2405:8100::/32",
+	"2a06:98c0::/29", "2c0f:f248::/32",
+}
+
+type Param struct {
+	Name     string
+	Label    string
+	Default  string
+	Options  []string // a choice instead of free text when set
+	Optional bool
+}
+
+// Step is one change of a playbook: a ufw command, a NAT entry for the fwtui block of before.rules,
+// a default policy or a predefined application profile to install.
+type Step struct {
+	Command string
+	Nat     *ufw.NatEntry
+	Policy  *Policy
+	Profile string
+}
+
+// Policy is the default policy of a direction: incoming, outgoing or routed.
+type Policy struct {
+	Direction string
+	Action    string
+}
+
+func (s Step) String() string {
+	switch {
+	case s.Nat != nil:
+		return fmt.Sprintf("before.rules: %s (+ sudo ufw route allow %s)", s.Nat.Summary(), s.Nat.RouteRuleArgs())
+	case s.Policy != nil:
+		return ufw.DefaultPolicyCommand(s.Policy.Direction, s.Policy.Action)
+	case s.Profile != "":
+		return fmt.Sprintf("install the %s profile unless it is installed", s.Profile)
+	}
+	return s.Command
+}
+
+type Playbook struct {
+	Name        string
+	Title       string
+	Description string
+	Params      []Param
+	build       func(values map[string]string) ([]Step, error)
+}
+
+// Build validates the parameters and returns the steps of the playbook. Missing values fall back to
+// the defaults.
+func (p Playbook) Build(values map[string]string) ([]Step, error) {
+	resolved := map[string]string{}
+	for _, param := range p.Params {
+		value := strings.TrimSpace(values[param.Name])
+		if value == "" {
+			value = param.Default
+		}
+		if value == "" && !param.Optional {
+			return nil, fmt.Errorf("%s is required", param.Label)
+		}
+		if len(param.Options) > 0 && !lo.Contains(param.Options, value) {
+			return nil, fmt.Errorf("invalid %s: %s", strings.ToLower(param.Label), value)
+		}
+		resolved[param.Name] = value
+	}
+	return p.build(resolved)
+}
+
+func comment(playbook string) string {
+	return "'" + commentPrefix + playbook + "'"
+}
+
+var All = []Playbook{
+	{
+		Name:        "web-cloudflare",
+		Title:       "Web server behind Cloudflare",
+		Description: "Allow HTTP(S) only from the Cloudflare edge networks",
+		Params: []Param{
+			{Name: "profile", Label: "Web server profile", Default: "none", Options: []string{"none", "Nginx Full", "Apache Full"}},
+			{Name: "ports", Label: "Ports without a profile", Default: "80,443"},
+			{Name: "deny", Label: "Deny everybody else", Default: "yes", Options: []string{"yes", "no"}},
+		},
+		build: buildWebCloudflare,
+	},
+	{
+		Name:        "postgres-replica",
+		Title:       "PostgreSQL replica",
+		Description: "Allow PostgreSQL from the application subnet only",
+		Params: []Param{
+			{Name: "subnet", Label: "Application subnet"},
+			{Name: "port", Label: "Port", Default: "5432"},
+			{Name: "interface", Label: "Interface", Optional: true},
+		},
+		build: buildPostgresReplica,
+	},
+	{
+		Name:        "wireguard-gateway",
+		Title:       "WireGuard gateway",
+		Description: "Accept WireGuard peers and route their traffic to the internet",
+		Params: []Param{
+			{Name: "port", Label: "Listen port", Default: "51820"},
+			{Name: "subnet", Label: "VPN subnet", Default: "10.8.0.0/24"},
+			{Name: "wan", Label: "Internet interface", Default: "eth0"},
+			{Name: "routed", Label: "Other routed traffic", Default: "deny", Options: []string{"deny", "reject", "allow"}},
+		},
+		build: buildWireGuardGateway,
+	},
+}
+
+func buildWebCloudflare(values map[string]string) ([]Step, error) {
+	tag := comment("web-cloudflare")
+
+	// the rules name the ports or the profile of the web server
+	var steps []Step
+	target := ""
+	if profile := values["profile"]; profile != "none" {
+		steps = append(steps, Step{Profile: profile})
+		target = fmt.Sprintf("app '%s'", profile)
+	} else {
+		ports := strings.ReplaceAll(values["ports"], " ", "")
+		if err := validatePorts(ports); err != nil {
+			return nil, err
+		}
+		target = "port " + ports + " proto tcp"
+	}
+
+	for _, network := range cloudflareRanges {
+		steps = append(steps, Step{Command: fmt.Sprintf("sudo ufw allow from %s to any %s comment %s", network, target, tag)})
+	}
+	if values["deny"] == "yes" {
+		steps = append(steps, Step{Command: fmt.Sprintf("sudo ufw deny from any to any %s comment %s", target, tag)})
+	}
+	return steps, nil
+}
+
+func buildPostgresReplica(values map[string]string) ([]Step, error) {
+	if _, err := netip.ParsePrefix(values["subnet"]); err != nil {
+		if _, err := netip.ParseAddr(values["subnet"]); err != nil {
+			return nil, fmt.Errorf("invalid application subnet: %s", values["subnet"])
+		}
+	}
+	if err := validatePorts(values["port"]); err != nil {
+		return nil, err
+	}
+	iface := ""
+	if values["interface"] != "" {
+		if !ufw.ValidInterfaceName(values["interface"]) {
+			return nil, fmt.Errorf("invalid interface: %q", values["interface"])
+		}
+		iface = " in on " + values["interface"]
+	}
+	return []Step{{Command: fmt.Sprintf("sudo ufw allow%s proto tcp from %s to any port %s comment %s",
+		iface, values["subnet"], values["port"], comment("postgres-replica"))}}, nil
+}
+
+func buildWireGuardGateway(values map[string]string) ([]Step, error) {
+	if err := validatePorts(values["port"]); err != nil {
+		return nil, err
+	}
+	masquerade := ufw.NatEntry{Kind: ufw.NatMasquerade, Source: values["subnet"], OutInterface: values["wan"]}
+	if err := masquerade.Validate(); err != nil {
+		return nil, err
+	}
+	return []Step{
+		{Command: fmt.Sprintf("sudo ufw allow proto udp from any to any port %s comment %s", values["port"], comment("wireguard-gateway"))},
+		// the peers reach the internet through the route rule of the NAT entry, whatever else is
+		// routed meets the policy
+		{Nat: &masquerade},
+		{Policy: &Policy{Direction: "routed", Action: values["routed"]}},
+	}, nil
+}
+
+func validatePorts(ports string) error {
+	for _, port := range strings.Split(ports, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(port))
+		if err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("invalid port: %s", port)
+		}
+	}
+	return nil
+}
+
+// Apply runs the steps in order. NAT entries join the fwtui block of before.rules, entries that are
+// there already are left alone, and so are installed profiles.
+func Apply(steps []Step) string {
+	var outputs []string
+	for _, step := range steps {
+		switch {
+		case step.Command != "":
+			outputs = append(outputs, oscmd.RunCommand(step.Command))
+			continue
+		case step.Policy != nil:
+			outputs = append(outputs, ufw.SetDefaultPolicy(step.Policy.Direction, step.Policy.Action))
+			continue
+		case step.Profile != "":
+			outputs = append(outputs, installProfile(step.Profile))
+			continue
+		}
+		entries, err := ufw.ReadNatEntries()
+		if err != nil {
+			outputs = append(outputs, fmt.Sprintf("Error: %s\n", err))
+			continue
+		}
+		if lo.ContainsBy(entries, step.Nat.ConflictsWith) {
+			outputs = append(outputs, fmt.Sprintf("Skipping existing NAT entry: %s\n", step.Nat.Summary()))
+			continue
+		}
+		outputs = append(outputs, ufw.ApplyNatEntries(append(entries, *step.Nat), []ufw.NatEntry{*step.Nat}, nil))
+	}
+	return strings.Join(outputs, "")
+}
+
+// Shadowed warns about the rules of the steps that the rules in place keep from matching, e.g. a deny
+// appended behind an allow for the same ports: the steps are replayed on the fake firewall and the
+// analyzer reports the playbook rules a rule without the playbook tag decides first.
+func Shadowed(steps []Step, rules []ufw.Rule, profiles []entity.UFWProfile) []string {
+	fake := fakeufw.New()
+	for _, p := range append(profiles, entity.PredefinedProfiles()...) {
+		if _, ok := fake.Apps[p.Name]; !ok {
+			fake.Apps[p.Name] = strings.Join(p.Ports, "|")
+		}
+	}
+	for _, rule := range rules {
+		for _, app := range []string{rule.DestApp, rule.SrcApp} {
+			if _, ok := fake.Apps[app]; app != "" && !ok {
+				fake.Apps[app] = ""
+			}
+		}
+	}
+	fake.Load(rules)
+	for _, step := range steps {
+		if step.Command != "" {
+			fake.Run(step.Command)
+		}
+	}
+
+	after := fake.Rules()
+	byNumber := lo.KeyBy(after, func(r ufw.Rule) int { return r.Number })
+	var warnings []string
+	for _, f := range ufw.Analyze(after, false) {
+		if f.Kind != ufw.FindingShadowed && f.Kind != ufw.FindingConflict {
+			continue
+		}
+		earlier, later := byNumber[f.Rules[0]], byNumber[f.Rules[len(f.Rules)-1]]
+		if strings.HasPrefix(earlier.Comment, commentPrefix) || !strings.HasPrefix(later.Comment, commentPrefix) {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("%s (rule %d: %s)", f.Message, earlier.Number, earlier.Summary()))
+	}
+	return warnings
+}
+
+func installProfile(name string) string {
+	installed, err := entity.LoadInstalledProfiles()
+	if err != nil {
+		return fmt.Sprintf("Error: %s\n", err)
+	}
+	profile, found := lo.Find(entity.InstallableProfiles(installed), func(p entity.UFWProfile) bool {
+		return p.Name == name
+	})
+	if !found {
+		return fmt.Sprintf("Skipping installed profile: %s\n", name)
+	}
+	res := entity.CreateProfile(profile)
+	if res.IsErr() {
+		return fmt.Sprintf("Error: %s\n", res.Err())
+	}
+	return res.Value() + "\n"
+}
@@ -0,0 +1,86 @@
+package playbooks
+
+import (
+	"fwtui/domain/ufw"
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+)
+
+func find(t *testing.T, name string) Playbook {
+	t.Helper()
+	playbook, found := lo.Find(All, func(p Playbook) bool { return p.Name == name })
+	if !found {
+		t.Fatalf("no playbook %s", name)
+	}
+	return playbook
+}
+
+func TestPostgresReplicaRejectsInterface(t *testing.T) {
+	playbook := find(t, "postgres-replica")
+	for _, iface := range []string{"eth0;reboot", "$(id)", "eth0 to any", "`id`"} {
+		if _, err := playbook.Build(map[string]string{"subnet": "10.0.0.0/24", "interface": iface}); err == nil {
+			t.Errorf("interface %q passed", iface)
+		}
+	}
+	steps, err := playbook.Build(map[string]string{"subnet": "10.0.0.0/24", "interface": "eth1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "sudo ufw allow in on eth1 proto tcp from 10.0.0.0/24 to any port 5432 comment 'playbook:postgres-replica'"; steps[0].String() != want {
+		t.Errorf("step = %s, want %s", steps[0], want)
+	}
+}
+
+func TestWebCloudflareProfile(t *testing.T) {
+	steps, err := find(t, "web-cloudflare").Build(map[string]string{"profile": "Nginx Full"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if steps[0].Profile != "Nginx Full" {
+		t.Errorf("first step = %s, want the profile", steps[0])
+	}
+	if len(steps) != len(cloudflareRanges)+2 {
+		t.Errorf("%d steps, want the profile, one rule per network and the deny", len(steps))
+	}
+	for _, step := range steps[1:] {
+		if !strings.Contains(step.Command, "to any app 'Nginx Full' comment") {
+			t.Errorf("step = %s, want a rule for the profile", step)
+		}
+	}
+}
+
+func TestWireGuardGatewayRoutedPolicy(t *testing.T) {
+	steps, err := find(t, "wireguard-gateway").Build(map[string]string{"routed": "allow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := steps[len(steps)-1]
+	if last.Policy == nil || *last.Policy != (Policy{Direction: "routed", Action: "allow"}) {
+		t.Errorf("last step = %s, want the routed policy", last)
+	}
+	if _, err := find(t, "wireguard-gateway").Build(map[string]string{"wan": "eth0|sh"}); err == nil {
+		t.Error("internet interface eth0|sh passed")
+	}
+}
+
+func TestWebCloudflareWarnsAboutShadowedDeny(t *testing.T) {
+	steps, err := find(t, "web-cloudflare").Build(map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warnings := Shadowed(steps, nil, nil); len(warnings) != 0 {
+		t.Errorf("warnings on an empty firewall = %v, want none", warnings)
+	}
+
+	web := ufw.Rule{Number: 1, Action: "allow", Direction: "in", Protocol: "tcp", DestPort: "80,443", SrcPort: "any", Source: "any", Dest: "any"}
+	ssh := ufw.Rule{Number: 2, Action: "allow", Direction: "in", Protocol: "tcp", DestPort: "22", SrcPort: "any", Source: "any", Dest: "any"}
+	warnings := Shadowed(steps, []ufw.Rule{web, ssh}, nil)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "rule 1 matches the same traffic and allows it") {
+		t.Errorf("warnings = %v, want one about the deny behind rule 1", warnings)
+	}
+	if warnings := Shadowed(steps, []ufw.Rule{ssh}, nil); len(warnings) != 0 {
+		t.Errorf("warnings with other ports only = %v, want none", warnings)
+	}
+}
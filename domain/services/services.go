@@ -0,0 +1,223 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const servicesPath = "/etc/services"
+
+type Service struct {
+	Name     string
+	Port     int
+	Protocol string // tcp or udp
+	Aliases  []string
+}
+
+func (s Service) PortProto() string {
+	return fmt.Sprintf("%d/%s", s.Port, s.Protocol)
+}
+
+// builtin backs up /etc/services on minimal systems that ship without it or with a trimmed copy.
+// It covers the well known ports and the ports of the installable profiles.
+var builtin = []Service{
+	{Name: "ftp", Port: 21, Protocol: "tcp"},
+	{Name: "ssh", Port: 22, Protocol: "tcp"},
+	{Name: "telnet", Port: 23, Protocol: "tcp"},
+	{Name: "smtp", Port: 25, Protocol: "tcp", Aliases: []string{"mail"}},
+	{Name: "domain", Port: 53, Protocol: "tcp", Aliases: []string{"dns"}},
+	{Name: "domain", Port: 53, Protocol: "udp", Aliases: []string{"dns"}},
+	{Name: "bootps", Port: 67, Protocol: "udp"},
+	{Name: "bootpc", Port: 68, Protocol: "udp"},
+	{Name: "http", Port: 80, Protocol: "tcp", Aliases: []string{"www"}},
+	{Name: "pop3", Port: 110, Protocol: "tcp"},
+	{Name: "sunrpc", Port: 111, Protocol: "tcp", Aliases: []string{"portmapper"}},
+	{Name: "sunrpc", Port: 111, Protocol: "udp", Aliases: []string{"portmapper"}},
+	{Name: "ntp", Port: 123, Protocol: "udp"},
+	{Name: "netbios-ns", Port: 137, Protocol: "udp"},
+	{Name: "netbios-dgm", Port: 138, Protocol: "udp"},
+	{Name: "netbios-ssn", Port: 139, Protocol: "tcp"},
+	{Name: "imap2", Port: 143, Protocol: "tcp", Aliases: []string{"imap"}},
+	{Name: "snmp", Port: 161, Protocol: "udp"},
+	{Name: "ldap", Port: 389, Protocol: "tcp"},
+	{Name: "https", Port: 443, Protocol: "tcp"},
+	{Name: "https", Port: 443, Protocol: "udp"},
+	{Name: "microsoft-ds", Port: 445, Protocol: "tcp"},
+	{Name: "submissions", Port: 465, Protocol: "tcp", Aliases: []string{"ssmtp", "smtps"}},
+	{Name: "submission", Port: 587, Protocol: "tcp"},
+	{Name: "ipp", Port: 631, Protocol: "tcp"},
+	{Name: "ldaps", Port: 636, Protocol: "tcp"},
+	{Name: "rsync", Port: 873, Protocol: "tcp"},
+	{Name: "imaps", Port: 993, Protocol: "tcp"},
+	{Name: "pop3s", Port: 995, Protocol: "tcp"},
+	{Name: "openvpn", Port: 1194, Protocol: "tcp"},
+	{Name: "openvpn", Port: 1194, Protocol: "udp"},
+	{Name: "mqtt", Port: 1883, Protocol: "tcp"},
+	{Name: "nfs", Port: 2049, Protocol: "tcp"},
+	{Name: "nfs", Port: 2049, Protocol: "udp"},
+	{Name: "docker", Port: 2375, Protocol: "tcp"},
+	{Name: "docker-s", Port: 2376, Protocol: "tcp"},
+	{Name: "docker-swarm", Port: 2377, Protocol: "tcp"},
+	{Name: "grafana", Port: 3000, Protocol: "tcp"},
+	{Name: "mysql", Port: 3306, Protocol: "tcp"},
+	{Name: "ms-wbt-server", Port: 3389, Protocol: "tcp", Aliases: []string{"rdp"}},
+	{Name: "epmd", Port: 4369, Protocol: "tcp"},
+	{Name: "vxlan", Port: 4789, Protocol: "udp"},
+	{Name: "sip", Port: 5060, Protocol: "tcp"},
+	{Name: "sip", Port: 5060, Protocol: "udp"},
+	{Name: "mdns", Port: 5353, Protocol: "udp"},
+	{Name: "postgresql", Port: 5432, Protocol: "tcp", Aliases: []string{"postgres"}},
+	{Name: "amqp", Port: 5672, Protocol: "tcp"},
+	{Name: "rfb", Port: 5900, Protocol: "tcp", Aliases: []string{"vnc"}},
+	{Name: "redis", Port: 6379, Protocol: "tcp"},
+	{Name: "kube-apiserver", Port: 6443, Protocol: "tcp", Aliases: []string{"kubernetes"}},
+	{Name: "bittorrent", Port: 6881, Protocol: "tcp"},
+	{Name: "bittorrent", Port: 6881, Protocol: "udp"},
+	{Name: "docker-gossip", Port: 7946, Protocol: "tcp"},
+	{Name: "docker-gossip", Port: 7946, Protocol: "udp"},
+	{Name: "influxdb", Port: 8086, Protocol: "tcp"},
+	{Name: "secure-mqtt", Port: 8883, Protocol: "tcp"},
+	{Name: "prometheus", Port: 9090, Protocol: "tcp"},
+	{Name: "elasticsearch", Port: 9200, Protocol: "tcp"},
+	{Name: "elasticsearch-transport", Port: 9300, Protocol: "tcp"},
+	{Name: "rabbitmq-management", Port: 15672, Protocol: "tcp"},
+	{Name: "mongodb", Port: 27017, Protocol: "tcp"},
+	{Name: "wireguard", Port: 51820, Protocol: "udp"},
+}
+
+// Database indexes services by name, alias and port.
+type Database struct {
+	byName map[string][]Service
+	byPort map[string]Service
+	names  []string
+}
+
+var (
+	loadOnce sync.Once
+	loaded   *Database
+)
+
+// Builtin returns the builtin list alone, without what /etc/services adds.
+func Builtin() *Database {
+	return NewDatabase(builtin)
+}
+
+// Default returns the services of /etc/services completed by the builtin list, read once.
+func Default() *Database {
+	loadOnce.Do(func() {
+		content, _ := os.ReadFile(servicesPath)
+		loaded = NewDatabase(append(Parse(string(content)), builtin...))
+	})
+	return loaded
+}
+
+// Parse reads the tcp and udp entries of /etc/services content.
+func Parse(content string) []Service {
+	var services []Service
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		portStr, proto, found := strings.Cut(fields[1], "/")
+		port, err := strconv.Atoi(portStr)
+		if !found || err != nil || (proto != "tcp" && proto != "udp") {
+			continue
+		}
+		services = append(services, Service{Name: fields[0], Port: port, Protocol: proto, Aliases: fields[2:]})
+	}
+	return services
+}
+
+// NewDatabase indexes the services, the first entry for a port or name/protocol pair wins.
+func NewDatabase(services []Service) *Database {
+	db := &Database{byName: map[string][]Service{}, byPort: map[string]Service{}}
+	for _, s := range services {
+		if _, known := db.byPort[s.PortProto()]; !known {
+			db.byPort[s.PortProto()] = s
+		}
+		for _, name := range append([]string{s.Name}, s.Aliases...) {
+			key := strings.ToLower(name)
+			if lookupProto(db.byName[key], s.Protocol) != nil {
+				continue
+			}
+			if len(db.byName[key]) == 0 {
+				db.names = append(db.names, key)
+			}
+			db.byName[key] = append(db.byName[key], s)
+		}
+	}
+	sort.Strings(db.names)
+	return db
+}
+
+// Lookup finds the entries of a service name or alias, one per protocol.
+func (db *Database) Lookup(name string) []Service {
+	return db.byName[strings.ToLower(strings.TrimSpace(name))]
+}
+
+// NameFor returns the service name of a port, protocol "any" looks at tcp first.
+func (db *Database) NameFor(port int, protocol string) string {
+	for _, proto := range []string{"tcp", "udp"} {
+		if protocol != "any" && protocol != "" && protocol != proto {
+			continue
+		}
+		if s, ok := db.byPort[fmt.Sprintf("%d/%s", port, proto)]; ok {
+			return s.Name
+		}
+	}
+	return ""
+}
+
+// Complete lists the service names starting with the prefix, at most limit of them.
+func (db *Database) Complete(prefix string, limit int) []string {
+	prefix = strings.ToLower(prefix)
+	if prefix == "" {
+		return nil
+	}
+	start := sort.SearchStrings(db.names, prefix)
+	var matches []string
+	for _, name := range db.names[start:] {
+		if !strings.HasPrefix(name, prefix) || len(matches) == limit {
+			break
+		}
+		matches = append(matches, name)
+	}
+	return matches
+}
+
+func lookupProto(services []Service, protocol string) *Service {
+	for i := range services {
+		if services[i].Protocol == protocol {
+			return &services[i]
+		}
+	}
+	return nil
+}
+
+// Label names the services of a port spec as written by ufw, e.g. "22/tcp" gives "ssh" and
+// "80,443/tcp" gives "http, https". Ranges and unknown ports are left out.
+func (db *Database) Label(spec string) string {
+	ports, protocol, found := strings.Cut(spec, "/")
+	if !found {
+		protocol = "any"
+	}
+	var names []string
+	for _, p := range strings.Split(ports, ",") {
+		port, err := strconv.Atoi(p)
+		if err != nil {
+			continue
+		}
+		if name := db.NameFor(port, protocol); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,204 @@
+package sockets
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const procPath = "/proc"
+
+// socket states as written in /proc/net/{tcp,udp}
+const tcpListen = "0A"
+const udpUnconnected = "07"
+
+type Socket struct {
+	Protocol string // tcp or udp
+	V6       bool
+	Address  net.IP
+	Port     int
+	Inode    string
+	PID      int
+	Process  string
+}
+
+// IsLocalOnly tells whether the socket is bound to a loopback address and can't be reached from outside.
+func (s Socket) IsLocalOnly() bool {
+	return s.Address.IsLoopback()
+}
+
+// IsWildcard tells whether the socket accepts connections on every address.
+func (s Socket) IsWildcard() bool {
+	return s.Address.IsUnspecified()
+}
+
+func (s Socket) BindAddress() string {
+	if s.V6 {
+		return fmt.Sprintf("[%s]:%d", s.Address, s.Port)
+	}
+	return fmt.Sprintf("%s:%d", s.Address, s.Port)
+}
+
+// ListListening reads the listening TCP and bound UDP sockets and the processes owning them.
+func ListListening() ([]Socket, error) {
+	var sockets []Socket
+	for _, table := range []struct {
+		file     string
+		protocol string
+		v6       bool
+		state    string
+	}{
+		{"tcp", "tcp", false, tcpListen},
+		{"tcp6", "tcp", true, tcpListen},
+		{"udp", "udp", false, udpUnconnected},
+		{"udp6", "udp", true, udpUnconnected},
+	} {
+		content, err := os.ReadFile(filepath.Join(procPath, "net", table.file))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("reading /proc/net/%s: %w", table.file, err)
+		}
+		sockets = append(sockets, parseTable(string(content), table.protocol, table.v6, table.state)...)
+	}
+
+	owners := socketOwners()
+	for i := range sockets {
+		if owner, ok := owners[sockets[i].Inode]; ok {
+			sockets[i].PID = owner.pid
+			sockets[i].Process = owner.name
+		}
+	}
+
+	sockets = dedupe(sockets)
+	sort.Slice(sockets, func(i, j int) bool {
+		if sockets[i].Port != sockets[j].Port {
+			return sockets[i].Port < sockets[j].Port
+		}
+		if sockets[i].Protocol != sockets[j].Protocol {
+			return sockets[i].Protocol < sockets[j].Protocol
+		}
+		return sockets[i].BindAddress() < sockets[j].BindAddress()
+	})
+	return sockets, nil
+}
+
+func parseTable(content, protocol string, v6 bool, state string) []Socket {
+	var sockets []Socket
+	lines := strings.Split(content, "\n")
+	for _, line := range lines[min(1, len(lines)):] {
+		fields := strings.Fields(line)
+		if len(fields) < 10 || fields[3] != state {
+			continue
+		}
+		address, port, err := parseAddress(fields[1])
+		if err != nil {
+			continue
+		}
+		// an unconnected UDP socket with a remote address is a connected client, not a server
+		if protocol == "udp" && !strings.HasSuffix(fields[2], ":0000") {
+			continue
+		}
+		sockets = append(sockets, Socket{
+			Protocol: protocol,
+			V6:       v6,
+			Address:  address,
+			Port:     port,
+			Inode:    fields[9],
+		})
+	}
+	return sockets
+}
+
+// parseAddress decodes "0100007F:0016". The address is stored as 32 bit words in host byte order.
+func parseAddress(field string) (net.IP, int, error) {
+	hexAddr, hexPort, found := strings.Cut(field, ":")
+	if !found {
+		return nil, 0, fmt.Errorf("invalid address: %s", field)
+	}
+
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid port: %s", hexPort)
+	}
+
+	raw, err := hex.DecodeString(hexAddr)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return nil, 0, fmt.Errorf("invalid address: %s", hexAddr)
+	}
+	ip := make(net.IP, len(raw))
+	for word := 0; word < len(raw); word += 4 {
+		for i := 0; i < 4; i++ {
+			ip[word+i] = raw[word+3-i]
+		}
+	}
+	if v4 := ip.To4(); v4 != nil && len(raw) == net.IPv6len && !ip.IsUnspecified() {
+		ip = v4
+	}
+	return ip, int(port), nil
+}
+
+type owner struct {
+	pid  int
+	name string
+}
+
+// socketOwners maps socket inodes to the processes holding them by walking /proc/*/fd.
+func socketOwners() map[string]owner {
+	owners := map[string]owner{}
+
+	entries, err := os.ReadDir(procPath)
+	if err != nil {
+		return owners
+	}
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		fdDir := filepath.Join(procPath, entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		var name string
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			inode := strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")
+			if _, seen := owners[inode]; seen {
+				continue
+			}
+			if name == "" {
+				comm, _ := os.ReadFile(filepath.Join(procPath, entry.Name(), "comm"))
+				name = strings.TrimSpace(string(comm))
+			}
+			owners[inode] = owner{pid: pid, name: name}
+		}
+	}
+	return owners
+}
+
+// dedupe drops sockets sharing protocol, address and port, e.g. SO_REUSEPORT workers.
+func dedupe(sockets []Socket) []Socket {
+	seen := map[string]bool{}
+	var result []Socket
+	for _, s := range sockets {
+		key := s.Protocol + " " + s.BindAddress()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, s)
+	}
+	return result
+}
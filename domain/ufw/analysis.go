@@ -0,0 +1,262 @@
+package ufw
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+type FindingKind string
+
+const (
+	FindingShadowed    FindingKind = "shadowed"
+	FindingDuplicate   FindingKind = "duplicate"
+	FindingOverlap     FindingKind = "overlap"
+	FindingConflict    FindingKind = "conflict"
+	FindingMissingIPv6 FindingKind = "missing-v6"
+)
+
+type Finding struct {
+	Kind    FindingKind
+	Rules   []int // numbers of the rules involved, the offending one last
+	Message string
+}
+
+// Analyze walks the rules in the order ufw evaluates them and reports rules that can never match,
+// duplicates, allow/deny conflicts and, while IPv6 is on, IPv4 rules without an IPv6 twin.
+func Analyze(rules []Rule, ipv6 bool) []Finding {
+	var findings []Finding
+
+	for j, later := range rules {
+		for _, earlier := range rules[:j] {
+			if !sameScope(earlier, later) {
+				continue
+			}
+			finding, found := compare(earlier, later)
+			if !found {
+				continue
+			}
+			findings = append(findings, finding)
+			if sameMatch(earlier, later) || covers(earlier, later) {
+				// no rule after this one gets to see the traffic of the later rule, a partial overlap
+				// leaves some of it to the rules further down
+				break
+			}
+		}
+	}
+
+	if ipv6 {
+		findings = append(findings, missingIPv6(rules)...)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Rules[len(findings[i].Rules)-1] < findings[j].Rules[len(findings[j].Rules)-1]
+	})
+	return findings
+}
+
+// FindingsByRule indexes the findings by the numbers of the rules involved.
+func FindingsByRule(findings []Finding) map[int][]Finding {
+	byRule := map[int][]Finding{}
+	for _, f := range findings {
+		for _, n := range f.Rules {
+			byRule[n] = append(byRule[n], f)
+		}
+	}
+	return byRule
+}
+
+func compare(earlier, later Rule) (Finding, bool) {
+	involved := []int{earlier.Number, later.Number}
+
+	if sameMatch(earlier, later) {
+		if earlier.Action == later.Action {
+			return Finding{FindingDuplicate, involved, fmt.Sprintf("rule %d duplicates rule %d", later.Number, earlier.Number)}, true
+		}
+		return Finding{FindingShadowed, involved, fmt.Sprintf("rule %d never matches, rule %d matches the same traffic and %ss it", later.Number, earlier.Number, earlier.Action)}, true
+	}
+
+	if covers(earlier, later) {
+		if earlier.Action == later.Action {
+			return Finding{FindingOverlap, involved, fmt.Sprintf("rule %d is redundant, rule %d already %ss all of its traffic", later.Number, earlier.Number, earlier.Action)}, true
+		}
+		return Finding{FindingShadowed, involved, fmt.Sprintf("rule %d never matches, rule %d %ss all of its traffic first", later.Number, earlier.Number, earlier.Action)}, true
+	}
+
+	if !intersects(earlier, later) {
+		return Finding{}, false
+	}
+	if earlier.Action == later.Action {
+		return Finding{FindingOverlap, involved, fmt.Sprintf("rules %d and %d partly %s the same traffic", earlier.Number, later.Number, earlier.Action)}, true
+	}
+	if earlier.IsAllowing() != later.IsAllowing() && earlier.Source == later.Source {
+		return Finding{FindingConflict, involved, fmt.Sprintf("rule %d %ss and rule %d %ss the same port from %s, rule %d wins", earlier.Number, earlier.Action, later.Number, later.Action, earlier.Source, earlier.Number)}, true
+	}
+	return Finding{}, false
+}
+
+func missingIPv6(rules []Rule) []Finding {
+	var findings []Finding
+	for _, rule := range rules {
+		if rule.V6 || isIPv4Addr(rule.Source) || isIPv4Addr(rule.Dest) {
+			continue
+		}
+		hasTwin := false
+		for _, other := range rules {
+			if other.V6 && sameScope(rule, other) && rule.Action == other.Action && sameMatchIgnoringFamily(rule, other) {
+				hasTwin = true
+				break
+			}
+		}
+		if !hasTwin {
+			findings = append(findings, Finding{FindingMissingIPv6, []int{rule.Number}, fmt.Sprintf("rule %d has no IPv6 counterpart", rule.Number)})
+		}
+	}
+	return findings
+}
+
+// sameScope tells whether both rules are evaluated in the same chain.
+func sameScope(a, b Rule) bool {
+	return a.Route == b.Route && a.Direction == b.Direction
+}
+
+func sameMatch(a, b Rule) bool {
+	return a.V6 == b.V6 && sameMatchIgnoringFamily(a, b)
+}
+
+func sameMatchIgnoringFamily(a, b Rule) bool {
+	return a.Protocol == b.Protocol && a.DestPort == b.DestPort && a.SrcPort == b.SrcPort &&
+		a.Dest == b.Dest && a.Source == b.Source && a.DestApp == b.DestApp && a.SrcApp == b.SrcApp &&
+		a.InterfaceIn == b.InterfaceIn && a.InterfaceOut == b.InterfaceOut
+}
+
+// covers tells whether every packet matching b matches a as well.
+func covers(a, b Rule) bool {
+	return a.V6 == b.V6 &&
+		ifaceCovers(a.InterfaceIn, b.InterfaceIn) && ifaceCovers(a.InterfaceOut, b.InterfaceOut) &&
+		(a.Protocol == "any" || a.Protocol == b.Protocol) &&
+		portsCover(a.DestPort, b.DestPort) && portsCover(a.SrcPort, b.SrcPort) &&
+		addrCovers(a.Dest, b.Dest) && addrCovers(a.Source, b.Source)
+}
+
+// intersects tells whether some packet matches both rules.
+func intersects(a, b Rule) bool {
+	return a.V6 == b.V6 &&
+		(a.InterfaceIn == "" || b.InterfaceIn == "" || a.InterfaceIn == b.InterfaceIn) &&
+		(a.InterfaceOut == "" || b.InterfaceOut == "" || a.InterfaceOut == b.InterfaceOut) &&
+		(a.Protocol == "any" || b.Protocol == "any" || a.Protocol == b.Protocol) &&
+		portsIntersect(a.DestPort, b.DestPort) && portsIntersect(a.SrcPort, b.SrcPort) &&
+		(addrCovers(a.Dest, b.Dest) || addrCovers(b.Dest, a.Dest)) &&
+		(addrCovers(a.Source, b.Source) || addrCovers(b.Source, a.Source))
+}
+
+func ifaceCovers(a, b string) bool {
+	return a == "" || a == b
+}
+
+func portsCover(a, b string) bool {
+	outer := ParsePortRanges(a)
+	for _, inner := range ParsePortRanges(b) {
+		covered := false
+		for _, r := range outer {
+			if r.From <= inner.From && inner.To <= r.To {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+func portsIntersect(a, b string) bool {
+	for _, x := range ParsePortRanges(a) {
+		for _, y := range ParsePortRanges(b) {
+			if x.From <= y.To && y.From <= x.To {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func addrCovers(a, b string) bool {
+	if a == "any" {
+		return true
+	}
+	if b == "any" {
+		return false
+	}
+	outer, errA := parsePrefix(a)
+	inner, errB := parsePrefix(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return outer.Bits() <= inner.Bits() && outer.Contains(inner.Addr())
+}
+
+func parsePrefix(addr string) (netip.Prefix, error) {
+	if strings.Contains(addr, "/") {
+		prefix, err := netip.ParsePrefix(addr)
+		return prefix.Masked(), err
+	}
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
+
+func isIPv4Addr(addr string) bool {
+	prefix, err := parsePrefix(addr)
+	return err == nil && prefix.Addr().Is4()
+}
+
+// IPv6Twins pairs every IPv4 rule with the IPv6 rule ufw added for it, both ways round. ufw adds
+// the twin when a rule names no IPv4 address, so the pair matches the same traffic with the same
+// action.
+func IPv6Twins(rules []Rule) map[int]int {
+	twins := map[int]int{}
+	for _, rule := range rules {
+		if rule.V6 || isIPv4Addr(rule.Source) || isIPv4Addr(rule.Dest) {
+			continue
+		}
+		for _, other := range rules {
+			if _, paired := twins[other.Number]; paired || !other.V6 {
+				continue
+			}
+			if sameScope(rule, other) && rule.Action == other.Action && sameMatchIgnoringFamily(rule, other) {
+				twins[rule.Number] = other.Number
+				twins[other.Number] = rule.Number
+				break
+			}
+		}
+	}
+	return twins
+}
+
+// GroupTwins moves every IPv6 twin right behind its IPv4 rule, keeping the order otherwise.
+func GroupTwins(rules []Rule, twins map[int]int) []Rule {
+	byNumber := map[int]Rule{}
+	for _, rule := range rules {
+		byNumber[rule.Number] = rule
+	}
+
+	grouped := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		twin, hasTwin := twins[rule.Number]
+		if rule.V6 && hasTwin {
+			if _, listed := byNumber[twin]; listed {
+				continue // already placed behind its IPv4 rule
+			}
+		}
+		grouped = append(grouped, rule)
+		if twinRule, listed := byNumber[twin]; !rule.V6 && hasTwin && listed {
+			grouped = append(grouped, twinRule)
+		}
+	}
+	return grouped
+}
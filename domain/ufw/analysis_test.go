@@ -0,0 +1,155 @@
+package ufw
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// in is an incoming IPv4 rule to any destination.
+func in(action, protocol, port, source string) Rule {
+	return Rule{Action: action, Direction: "in", Protocol: protocol, DestPort: port, SrcPort: "any", Source: source, Dest: "any"}
+}
+
+func v6(rule Rule) Rule {
+	rule.V6 = true
+	return rule
+}
+
+func numbered(rules ...Rule) []Rule {
+	for i := range rules {
+		rules[i].Number = i + 1
+	}
+	return rules
+}
+
+// summarize writes a finding as its kind and rule numbers, e.g. "shadowed 2,3".
+func summarize(findings []Finding) string {
+	var lines []string
+	for _, f := range findings {
+		numbers := make([]string, len(f.Rules))
+		for i, n := range f.Rules {
+			numbers[i] = fmt.Sprint(n)
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", f.Kind, strings.Join(numbers, ",")))
+	}
+	return strings.Join(lines, "; ")
+}
+
+func TestAnalyze(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		rules []Rule
+		ipv6  bool
+		want  string
+	}{
+		{
+			name:  "duplicate",
+			rules: numbered(in("allow", "tcp", "22", "any"), in("allow", "tcp", "22", "any")),
+			want:  "duplicate 1,2",
+		},
+		{
+			name:  "same match with another action never matches",
+			rules: numbered(in("deny", "tcp", "22", "any"), in("allow", "tcp", "22", "any")),
+			want:  "shadowed 1,2",
+		},
+		{
+			name:  "covered by a wider rule",
+			rules: numbered(in("deny", "any", "any", "any"), in("allow", "tcp", "22", "10.0.0.0/8")),
+			want:  "shadowed 1,2",
+		},
+		{
+			name:  "redundant under a wider rule with the same action",
+			rules: numbered(in("allow", "tcp", "20:30", "any"), in("allow", "tcp", "22", "192.0.2.0/24")),
+			want:  "overlap 1,2",
+		},
+		{
+			name: "a partial overlap doesn't hide a later full cover",
+			rules: numbered(
+				in("allow", "tcp", "20:25", "any"),
+				in("deny", "any", "any", "any"),
+				in("allow", "tcp", "22:30", "any"),
+			),
+			want: "conflict 1,2; overlap 1,3; shadowed 2,3",
+		},
+		{
+			name:  "the first full cover ends the search",
+			rules: numbered(in("deny", "any", "any", "any"), in("deny", "tcp", "any", "any"), in("allow", "tcp", "22", "any")),
+			want:  "overlap 1,2; shadowed 1,3",
+		},
+		{
+			name:  "conflict on the same source",
+			rules: numbered(in("allow", "tcp", "20:25", "any"), in("deny", "tcp", "22:30", "any")),
+			want:  "conflict 1,2",
+		},
+		{
+			name:  "disjoint ports",
+			rules: numbered(in("allow", "tcp", "22", "any"), in("deny", "tcp", "23", "any")),
+			want:  "",
+		},
+		{
+			name:  "families don't shadow each other",
+			rules: numbered(in("deny", "any", "any", "any"), v6(in("allow", "tcp", "22", "any"))),
+			want:  "",
+		},
+		{
+			name:  "other directions don't shadow",
+			rules: numbered(Rule{Action: "deny", Direction: "out", Protocol: "any", DestPort: "any", SrcPort: "any", Source: "any", Dest: "any"}, in("allow", "tcp", "22", "any")),
+			want:  "",
+		},
+		{
+			name:  "missing IPv6 twin",
+			ipv6:  true,
+			rules: numbered(in("allow", "tcp", "22", "any"), in("allow", "tcp", "80", "any"), v6(in("allow", "tcp", "22", "any"))),
+			want:  "missing-v6 2",
+		},
+		{
+			name:  "an IPv4 address needs no twin",
+			ipv6:  true,
+			rules: numbered(in("allow", "tcp", "22", "10.0.0.0/8")),
+			want:  "",
+		},
+		{
+			name:  "no twins expected while IPv6 is off",
+			rules: numbered(in("allow", "tcp", "22", "any"), in("allow", "tcp", "80", "any")),
+			want:  "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := summarize(Analyze(tc.rules, tc.ipv6)); got != tc.want {
+				t.Errorf("findings = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIPv6Twins(t *testing.T) {
+	rules := numbered(
+		in("allow", "tcp", "22", "any"),
+		in("allow", "tcp", "80", "10.0.0.0/8"),
+		in("deny", "tcp", "25", "any"),
+		v6(in("allow", "tcp", "22", "any")),
+		v6(in("allow", "tcp", "25", "any")),
+		v6(in("deny", "tcp", "25", "any")),
+	)
+	twins := IPv6Twins(rules)
+	want := map[int]int{1: 4, 4: 1, 3: 6, 6: 3}
+	if fmt.Sprint(twins) != fmt.Sprint(want) {
+		t.Errorf("twins = %v, want %v", twins, want)
+	}
+
+	order := func(rules []Rule) string {
+		var numbers []string
+		for _, r := range rules {
+			numbers = append(numbers, fmt.Sprint(r.Number))
+		}
+		return strings.Join(numbers, " ")
+	}
+	if got := order(GroupTwins(rules, twins)); got != "1 4 2 3 6 5" {
+		t.Errorf("grouped order = %s, want 1 4 2 3 6 5", got)
+	}
+	// a twin whose IPv4 rule is filtered out keeps its place
+	if got := order(GroupTwins(rules[1:], twins)); got != "2 3 6 4 5" {
+		t.Errorf("grouped order without rule 1 = %s, want 2 3 6 4 5", got)
+	}
+}
@@ -0,0 +1,140 @@
+package ufw
+
+import (
+	"fmt"
+	"fwtui/utils/oscmd"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const backupDir = "/etc/ufw/backup"
+
+// Backup saves the restore script of GetStateFromFiles to the backup directory. Nothing is written
+// when the newest backup already holds the same rules, the returned path is then the one of that
+// backup.
+func Backup() (string, error) {
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		return "", fmt.Errorf("creating backup directory: %w", err)
+	}
+
+	latest, err := latestBackup()
+	if err != nil {
+		return "", err
+	}
+
+	current, err := GetStateFromFiles()
+	if err != nil {
+		return "", fmt.Errorf("exporting the state for backup: %w", err)
+	}
+
+	if latest != "" {
+		data, err := os.ReadFile(latest)
+		if err != nil {
+			return "", fmt.Errorf("reading %s: %w", latest, err)
+		}
+		if string(data) == current {
+			return latest, nil
+		}
+	}
+
+	path := filepath.Join(backupDir, time.Now().Format("2006-01-02_15-04-05")+".sh")
+	if err := os.WriteFile(path, []byte(current), 0755); err != nil {
+		return "", fmt.Errorf("writing %s: %w", path, err)
+	}
+	return path, nil
+}
+
+func latestBackup() (string, error) {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		return "", fmt.Errorf("reading backup directory: %w", err)
+	}
+
+	var latestFile string
+	var latestTime int64
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if ctime := getCreationTime(info); ctime > latestTime {
+			latestTime = ctime
+			latestFile = filepath.Join(backupDir, entry.Name())
+		}
+	}
+	return latestFile, nil
+}
+
+func getCreationTime(info fs.FileInfo) int64 {
+	stat := info.Sys().(*syscall.Stat_t)
+	return stat.Ctim.Sec
+}
+
+type BackupFile struct {
+	Path    string
+	Created time.Time
+}
+
+// ListBackups lists the backups written by Backup, the newest first.
+func ListBackups() ([]BackupFile, error) {
+	entries, err := os.ReadDir(backupDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading backup directory: %w", err)
+	}
+
+	var backups []BackupFile
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".sh" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, BackupFile{
+			Path:    filepath.Join(backupDir, entry.Name()),
+			Created: time.Unix(getCreationTime(info), 0),
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Created.After(backups[j].Created)
+	})
+	return backups, nil
+}
+
+// RestoreBackup runs a backup script, a bare file name is looked up in the backup directory. The
+// current rules are backed up first so that the restore can be undone.
+func RestoreBackup(name string) string {
+	path := name
+	if !strings.Contains(name, "/") {
+		path = filepath.Join(backupDir, name)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Sprintf("Error: reading the backup: %s\n", err)
+	}
+	if !strings.Contains(string(content), "cat <<'EOF' > /etc/ufw/user.rules") {
+		return fmt.Sprintf("Error: %s is not a fwtui backup\n", path)
+	}
+
+	current, err := Backup()
+	if err != nil {
+		return fmt.Sprintf("Error: backing up the current rules: %s\n", err)
+	}
+	output := oscmd.RunCommand(fmt.Sprintf("sudo bash %s", shellQuote(path)))
+	if strings.HasPrefix(output, "Error:") {
+		return output
+	}
+	return fmt.Sprintf("%sThe rules before the restore are in %s\n", output, current)
+}
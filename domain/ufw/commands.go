@@ -3,7 +3,12 @@ package ufw
 import (
 	"fmt"
 	"fwtui/utils/oscmd"
+	"math"
 	"os"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
 )
 
 func StatusVerbose() string {
@@ -25,15 +30,38 @@ func Disable() string {
 	return oscmd.RunCommand("sudo ufw disable")
 }
 
-func EnableLogging() string {
-	return oscmd.RunCommand("sudo ufw logging on")
-}
-func DisableLogging() string {
-	return oscmd.RunCommand("sudo ufw logging off")
+func SetLogging(level string) string {
+	return oscmd.RunCommand(fmt.Sprintf("sudo ufw logging %s", level))
 }
 
 func DeleteRuleByNumber(num int) string {
-	return oscmd.RunCommand(fmt.Sprintf("yes | sudo ufw delete %d", num))
+	return oscmd.RunCommand(deleteCommand(num))
+}
+
+func deleteCommand(num int) string {
+	return fmt.Sprintf("yes | sudo ufw delete %d", num)
+}
+
+// DeleteCommands are the commands deleting the rules from the highest number down, otherwise the
+// numbers of the remaining rules shift after each deletion.
+func DeleteCommands(numbers []int) []string {
+	sorted := append([]int(nil), numbers...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+
+	var commands []string
+	for _, num := range sorted {
+		commands = append(commands, deleteCommand(num))
+	}
+	return commands
+}
+
+// DeleteRulesByNumber deletes the rules from the highest number down.
+func DeleteRulesByNumber(numbers []int) string {
+	var outputs []string
+	for _, command := range DeleteCommands(numbers) {
+		outputs = append(outputs, oscmd.RunCommand(command))
+	}
+	return strings.Join(outputs, "")
 }
 
 func LoadProfile(name string) string {
@@ -49,11 +77,23 @@ func GetProfileList() string {
 }
 
 func AllowProfile(name string) string {
-	return oscmd.RunCommand(fmt.Sprintf("sudo ufw allow \"%s\"", name))
+	return oscmd.RunCommand(AllowProfileCommand(name))
+}
+
+func AllowProfileCommand(name string) string {
+	return fmt.Sprintf("sudo ufw allow \"%s\"", name)
+}
+
+func Show(report string) string {
+	return oscmd.RunCommand(fmt.Sprintf("sudo ufw show %s", report))
 }
 
 func SetDefaultPolicy(direction, action string) string {
-	return oscmd.RunCommand(fmt.Sprintf("sudo ufw default %s %s", action, direction))
+	return oscmd.RunCommand(DefaultPolicyCommand(direction, action))
+}
+
+func DefaultPolicyCommand(direction, action string) string {
+	return fmt.Sprintf("sudo ufw default %s %s", action, direction)
 }
 
 func GetStateFromFiles() (string, error) {
@@ -85,3 +125,65 @@ echo "UFW rules restored."
 `
 	return script, nil
 }
+
+// ApplyRuleChanges deletes rules and adds new ones in the order of RuleChangeCommands.
+func ApplyRuleChanges(rules, remove, add []Rule) string {
+	var outputs []string
+	for _, command := range RuleChangeCommands(rules, remove, add) {
+		outputs = append(outputs, oscmd.RunCommand(command))
+	}
+	return strings.Join(outputs, "")
+}
+
+// RuleChangeCommands lists the commands deleting the rules to remove and adding the new ones. The
+// deletions go first, adding a rule shifts the numbers of the rules behind it. A rule to add carrying
+// the number of a current rule is inserted at that place, so that a replacement keeps the precedence
+// of the rule it replaces; the others are appended. ufw inserts only at a rule of the same family.
+func RuleChangeCommands(rules, remove, add []Rule) []string {
+	numbers := make([]int, 0, len(remove))
+	removed := map[int]bool{}
+	for _, rule := range remove {
+		numbers = append(numbers, rule.Number)
+		removed[rule.Number] = true
+	}
+	commands := DeleteCommands(numbers)
+
+	// the rules in the order of `ufw status numbered` after the deletions, each placed by the number
+	// of the rule it stands for
+	type placed struct {
+		v6    bool
+		place int
+	}
+	var list []placed
+	for _, rule := range rules {
+		if !removed[rule.Number] {
+			list = append(list, placed{v6: rule.V6, place: rule.Number})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].place < list[j].place })
+
+	for _, rule := range add {
+		// ahead of the first rule of the family that came after the replaced one
+		index := -1
+		for i, p := range list {
+			if rule.Number > 0 && p.v6 == rule.V6 && p.place > rule.Number {
+				index = i
+				break
+			}
+		}
+		place := rule.Number
+		if index < 0 {
+			place = math.MaxInt
+			commands = append(commands, "sudo ufw "+rule.ExactArgs())
+			// IPv6 rules are numbered behind the IPv4 ones
+			index = len(list)
+			if !rule.V6 {
+				index = len(lo.Filter(list, func(p placed, _ int) bool { return !p.v6 }))
+			}
+		} else {
+			commands = append(commands, "sudo ufw "+rule.InsertArgs(index+1))
+		}
+		list = append(list[:index], append([]placed{{v6: rule.V6, place: place}}, list[index:]...)...)
+	}
+	return commands
+}
@@ -0,0 +1,177 @@
+package ufw
+
+import (
+	"fmt"
+	"fwtui/utils/oscmd"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Counter struct {
+	Packets uint64
+	Bytes   uint64
+}
+
+// chainRule locates one iptables rule generated for a ufw rule.
+type chainRule struct {
+	chain    string
+	position int
+	observer bool // LOG rules and rules without a target see the packets of the rule that decides
+}
+
+// ReadRuleCounters reads the packet and byte counters of the iptables rules ufw generated and sums
+// them up per ufw rule number.
+func ReadRuleCounters() (map[int]Counter, error) {
+	rulesV4, err := os.ReadFile(userRulesPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading user.rules: %w", err)
+	}
+	rulesV6, err := readUserRules6()
+	if err != nil {
+		return nil, err
+	}
+
+	listingV4 := oscmd.RunCommand("sudo iptables -L -v -x -n")
+	if strings.HasPrefix(listingV4, "Error:") {
+		return nil, fmt.Errorf("listing iptables chains: %s", listingV4)
+	}
+	var listingV6 string
+	if rulesV6 != "" {
+		listingV6 = oscmd.RunCommand("sudo ip6tables -L -v -x -n")
+		if strings.HasPrefix(listingV6, "Error:") {
+			return nil, fmt.Errorf("listing ip6tables chains: %s", listingV6)
+		}
+	}
+
+	return MapRuleCounters(string(rulesV4), listingV4, rulesV6, listingV6), nil
+}
+
+// MapRuleCounters matches the rows of `iptables -L -v -x -n` to the rule tuples of user.rules and
+// user6.rules. ufw restores the chains from these files, so the n-th "-A chain" line of a file is the
+// n-th row of that chain.
+func MapRuleCounters(rulesV4, listingV4, rulesV6, listingV6 string) map[int]Counter {
+	counters := map[int]Counter{}
+
+	offset := 0
+	for _, family := range []struct{ rules, listing string }{{rulesV4, listingV4}, {rulesV6, listingV6}} {
+		generated, tuples := chainRulesByTuple(family.rules)
+		chains := ParseChainCounters(family.listing)
+
+		for i := 0; i < tuples; i++ {
+			var total Counter
+			for _, cr := range generated[i] {
+				rows := chains[cr.chain]
+				if cr.observer || cr.position >= len(rows) {
+					continue
+				}
+				total.Packets += rows[cr.position].Packets
+				total.Bytes += rows[cr.position].Bytes
+			}
+			counters[offset+i+1] = total
+		}
+		offset += tuples
+	}
+	return counters
+}
+
+// chainRulesByTuple lists the iptables rules following each valid tuple of a rules file, numbered
+// like ParseRuleTuples numbers the rules.
+func chainRulesByTuple(content string) (map[int][]chainRule, int) {
+	generated := map[int][]chainRule{}
+	positions := map[string]int{}
+	apps := map[string]int{}
+	current := -1
+	tuples := 0
+
+	for _, line := range strings.Split(content, "\n") {
+		switch {
+		case strings.HasPrefix(line, tuplePrefix):
+			current = -1
+			rule, ok := parseTuple(strings.TrimPrefix(line, tuplePrefix))
+			if !ok {
+				continue
+			}
+			// the further tuples of an application rule count for its first one
+			key := rule.appTupleKey()
+			if i, seen := apps[key]; key != "" && seen {
+				current = i
+				continue
+			}
+			apps[key] = tuples
+			current = tuples
+			tuples++
+		case strings.HasPrefix(line, "###"):
+			current = -1
+		case strings.HasPrefix(line, "-A "):
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			chain := fields[1]
+			if current >= 0 {
+				generated[current] = append(generated[current], chainRule{
+					chain:    chain,
+					position: positions[chain],
+					observer: strings.Contains(line, "-j LOG") || !strings.Contains(line, " -j "),
+				})
+			}
+			positions[chain]++
+		}
+	}
+	return generated, tuples
+}
+
+// ParseChainCounters reads the counters of every chain in `iptables -L -v -x -n` output.
+func ParseChainCounters(listing string) map[string][]Counter {
+	chains := map[string][]Counter{}
+	chain := ""
+
+	for _, line := range strings.Split(listing, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "Chain" {
+			chain = fields[1]
+			chains[chain] = nil
+			continue
+		}
+		if chain == "" || len(fields) < 3 {
+			continue
+		}
+		packets, errPackets := strconv.ParseUint(fields[0], 10, 64)
+		bytes, errBytes := strconv.ParseUint(fields[1], 10, 64)
+		if errPackets != nil || errBytes != nil {
+			continue
+		}
+		chains[chain] = append(chains[chain], Counter{Packets: packets, Bytes: bytes})
+	}
+	return chains
+}
+
+// ResetRuleCounters zeroes the counters of the chains holding the user rules.
+func ResetRuleCounters() string {
+	var outputs []string
+	for _, file := range []struct{ path, command string }{{userRulesPath, "iptables"}, {userRules6Path, "ip6tables"}} {
+		content, err := os.ReadFile(file.path)
+		if err != nil {
+			outputs = append(outputs, fmt.Sprintf("Error: reading %s: %s", file.path, err))
+			continue
+		}
+		generated, _ := chainRulesByTuple(string(content))
+		chains := map[string]bool{}
+		for _, rules := range generated {
+			for _, cr := range rules {
+				chains[cr.chain] = true
+			}
+		}
+		names := make([]string, 0, len(chains))
+		for chain := range chains {
+			names = append(names, chain)
+		}
+		sort.Strings(names)
+		for _, chain := range names {
+			outputs = append(outputs, oscmd.RunCommand(fmt.Sprintf("sudo %s -Z %s", file.command, chain)))
+		}
+	}
+	return strings.TrimSpace(strings.Join(outputs, "") + "\nCounters reset")
+}
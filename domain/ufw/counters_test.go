@@ -0,0 +1,103 @@
+package ufw
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("testdata", "counters", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestMapRuleCounters(t *testing.T) {
+	counters := MapRuleCounters(
+		readFixture(t, "user.rules"), readFixture(t, "iptables.txt"),
+		readFixture(t, "user6.rules"), readFixture(t, "ip6tables.txt"),
+	)
+
+	want := map[int]Counter{
+		1:  {120, 7200}, // allow 22/tcp
+		2:  {40, 2400},  // limit 2222/tcp: the recent --set row only observes, the limit and accept rows decide
+		3:  {15, 15000}, // allow log 80/tcp: the LOG row only observes
+		4:  {5, 300},    // deny from 10.0.0.0/8
+		5:  {6, 376},    // allow 53: one row per protocol
+		6:  {1, 60},     // deny out 25/tcp, ufw-user-output
+		7:  {11, 880},   // route allow in on wg0 out on eth0, ufw-user-forward
+		8:  {7, 560},    // allow 22/tcp (v6), numbered after the IPv4 rules
+		9:  {3, 240},    // allow log 80/tcp (v6)
+		10: {2, 160},    // deny out 25/tcp (v6), after the row of the unreadable tuple
+	}
+	if !reflect.DeepEqual(counters, want) {
+		t.Errorf("counters:\n got %v\nwant %v", counters, want)
+	}
+}
+
+func TestMapRuleCountersMissingRows(t *testing.T) {
+	// the chains were flushed since ufw wrote the rules file, the rules show no traffic
+	counters := MapRuleCounters(readFixture(t, "user.rules"), "Chain ufw-user-input (1 references)\n", "", "")
+	if len(counters) != 7 {
+		t.Fatalf("got %d counters, want 7", len(counters))
+	}
+	for number, counter := range counters {
+		if counter != (Counter{}) {
+			t.Errorf("rule %d: got %v, want no traffic", number, counter)
+		}
+	}
+}
+
+func TestChainRulesByTuple(t *testing.T) {
+	generated, tuples := chainRulesByTuple(readFixture(t, "user.rules"))
+	if tuples != 7 {
+		t.Fatalf("got %d tuples, want 7", tuples)
+	}
+
+	want := []chainRule{
+		{chain: "ufw-user-input", position: 1, observer: true},
+		{chain: "ufw-user-input", position: 2},
+		{chain: "ufw-user-input", position: 3},
+	}
+	if !reflect.DeepEqual(generated[1], want) {
+		t.Errorf("limit rule:\n got %+v\nwant %+v", generated[1], want)
+	}
+	if got := generated[6]; len(got) != 1 || got[0].chain != "ufw-user-forward" || got[0].position != 0 {
+		t.Errorf("route rule: got %+v", got)
+	}
+}
+
+func TestChainRulesByTupleSkipsInvalidTuples(t *testing.T) {
+	generated, tuples := chainRulesByTuple(readFixture(t, "user6.rules"))
+	if tuples != 3 {
+		t.Fatalf("got %d tuples, want 3", tuples)
+	}
+	// the row of the unreadable tuple still takes a position in the chain
+	if got := generated[2]; len(got) != 1 || got[0].chain != "ufw6-user-output" || got[0].position != 0 {
+		t.Errorf("deny out rule: got %+v", got)
+	}
+	if got := generated[1]; len(got) != 2 || got[1].position != 2 {
+		t.Errorf("log rule: got %+v", got)
+	}
+}
+
+func TestParseChainCounters(t *testing.T) {
+	chains := ParseChainCounters(readFixture(t, "iptables.txt"))
+
+	if got := len(chains["ufw-user-input"]); got != 9 {
+		t.Errorf("ufw-user-input: got %d rows, want 9", got)
+	}
+	if rows, ok := chains["ufw-user-logging-input"]; !ok || len(rows) != 0 {
+		t.Errorf("ufw-user-logging-input: got %v, want an empty chain", rows)
+	}
+	if got := chains["ufw-user-input"][1]; got != (Counter{40, 2400}) {
+		t.Errorf("row without a target: got %v", got)
+	}
+	if got := chains["ufw-user-output"]; !reflect.DeepEqual(got, []Counter{{1, 60}}) {
+		t.Errorf("ufw-user-output: got %v", got)
+	}
+}
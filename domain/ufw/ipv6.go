@@ -0,0 +1,91 @@
+package ufw
+
+import (
+	"fmt"
+	"fwtui/utils/oscmd"
+	"os"
+	"strings"
+)
+
+const defaultsPath = "/etc/default/ufw"
+
+// ReadIPv6Setting reads the IPV6 switch of /etc/default/ufw, which decides whether ufw manages
+// ip6tables at all.
+func ReadIPv6Setting() (bool, error) {
+	content, err := os.ReadFile(defaultsPath)
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", defaultsPath, err)
+	}
+	enabled, found := ParseIPv6Setting(string(content))
+	if !found {
+		return false, fmt.Errorf("IPV6 not set in %s", defaultsPath)
+	}
+	return enabled, nil
+}
+
+func ParseIPv6Setting(content string) (enabled bool, found bool) {
+	for _, line := range strings.Split(content, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || strings.TrimSpace(key) != "IPV6" {
+			continue
+		}
+		enabled = strings.EqualFold(strings.Trim(strings.TrimSpace(value), `"'`), "yes")
+		found = true
+	}
+	return enabled, found
+}
+
+// ReadForwardPolicy reads DEFAULT_FORWARD_POLICY of /etc/default/ufw as allow, deny or reject. ufw
+// reports the routed policy as disabled while IP forwarding is off, this is the policy it keeps for
+// when forwarding is turned on.
+func ReadForwardPolicy() (string, error) {
+	content, err := os.ReadFile(defaultsPath)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", defaultsPath, err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || strings.TrimSpace(key) != "DEFAULT_FORWARD_POLICY" {
+			continue
+		}
+		switch strings.ToUpper(strings.Trim(strings.TrimSpace(value), `"'`)) {
+		case "ACCEPT":
+			return "allow", nil
+		case "REJECT":
+			return "reject", nil
+		default:
+			return "deny", nil
+		}
+	}
+	return "", fmt.Errorf("DEFAULT_FORWARD_POLICY not set in %s", defaultsPath)
+}
+
+// SetIPv6 flips the IPV6 switch of /etc/default/ufw and reloads ufw so that the IPv6 chains are
+// loaded or dropped.
+func SetIPv6(enabled bool) string {
+	content, err := os.ReadFile(defaultsPath)
+	if err != nil {
+		return fmt.Sprintf("Error: reading %s: %s", defaultsPath, err)
+	}
+
+	value := "IPV6=no"
+	if enabled {
+		value = "IPV6=yes"
+	}
+	lines := strings.Split(string(content), "\n")
+	replaced := false
+	for i, line := range lines {
+		if key, _, ok := strings.Cut(strings.TrimSpace(line), "="); ok && strings.TrimSpace(key) == "IPV6" {
+			lines[i] = value
+			replaced = true
+		}
+	}
+	if !replaced {
+		lines = append(lines, value)
+	}
+
+	if err := os.WriteFile(defaultsPath, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		return fmt.Sprintf("Error: writing %s: %s", defaultsPath, err)
+	}
+	return oscmd.RunCommand("sudo ufw reload")
+}
@@ -0,0 +1,44 @@
+package ufw
+
+// RuleJSON is a rule as fwtui writes it for scripts, "any" stands for no restriction like in ufw.
+type RuleJSON struct {
+	Number       int    `json:"number"`
+	Action       string `json:"action"`
+	Direction    string `json:"direction"`
+	Route        bool   `json:"route"`
+	InterfaceIn  string `json:"interface_in,omitempty"`
+	InterfaceOut string `json:"interface_out,omitempty"`
+	Log          string `json:"log,omitempty"`
+	Protocol     string `json:"protocol"`
+	From         string `json:"from"`
+	FromPort     string `json:"from_port"`
+	FromApp      string `json:"from_app,omitempty"`
+	To           string `json:"to"`
+	Port         string `json:"port"`
+	App          string `json:"app,omitempty"`
+	V6           bool   `json:"v6"`
+	Comment      string `json:"comment,omitempty"`
+	Line         string `json:"line"`
+}
+
+func (r Rule) JSON() RuleJSON {
+	return RuleJSON{
+		Number:       r.Number,
+		Action:       r.Action,
+		Direction:    r.Direction,
+		Route:        r.Route,
+		InterfaceIn:  r.InterfaceIn,
+		InterfaceOut: r.InterfaceOut,
+		Log:          r.Log,
+		Protocol:     r.Protocol,
+		From:         r.Source,
+		FromPort:     r.SrcPort,
+		FromApp:      r.SrcApp,
+		To:           r.Dest,
+		Port:         r.DestPort,
+		App:          r.DestApp,
+		V6:           r.V6,
+		Comment:      r.Comment,
+		Line:         r.Line,
+	}
+}
@@ -0,0 +1,383 @@
+package ufw
+
+import (
+	"fmt"
+	"fwtui/utils/oscmd"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	beforeRulesPath      = "/etc/ufw/before.rules"
+	beforeRulesBackupDir = "/etc/ufw/backup/before-rules"
+
+	natBlockBegin = "# BEGIN fwtui nat"
+	natBlockEnd   = "# END fwtui nat"
+	natComment    = "fwtui nat"
+
+	// the entries live in chains of their own that are flushed on every load, ufw reloads before.rules
+	// without flushing the nat table
+	natPrerouting  = "fwtui-nat-PREROUTING"
+	natPostrouting = "fwtui-nat-POSTROUTING"
+)
+
+type NatKind string
+
+const (
+	NatMasquerade NatKind = "masquerade"
+	NatForward    NatKind = "forward"
+)
+
+// NatEntry is one line of the fwtui block in before.rules: a MASQUERADE of a source subnet leaving
+// through an interface, or a DNAT of a public port to an internal host.
+type NatEntry struct {
+	Kind NatKind
+
+	// masquerade
+	Source       string
+	OutInterface string
+
+	// forward
+	InInterface string
+	Protocol    string
+	Port        int
+	ToAddr      string
+	ToPort      int // 0 keeps the public port
+}
+
+func (e NatEntry) TargetPort() int {
+	if e.ToPort == 0 {
+		return e.Port
+	}
+	return e.ToPort
+}
+
+func (e NatEntry) Validate() error {
+	switch e.Kind {
+	case NatMasquerade:
+		if _, err := parsePrefix(e.Source); err != nil || !isIPv4Addr(e.Source) {
+			return fmt.Errorf("invalid source subnet: %s", e.Source)
+		}
+		if !ValidInterfaceName(e.OutInterface) {
+			return fmt.Errorf("invalid outgoing interface: %q", e.OutInterface)
+		}
+	case NatForward:
+		if !ValidInterfaceName(e.InInterface) {
+			return fmt.Errorf("invalid incoming interface: %q", e.InInterface)
+		}
+		if e.Protocol != "tcp" && e.Protocol != "udp" {
+			return fmt.Errorf("invalid protocol: %s. Must be either TCP or UDP", e.Protocol)
+		}
+		if e.Port < 1 || e.Port > 65535 {
+			return fmt.Errorf("invalid port: %d", e.Port)
+		}
+		if addr, err := netip.ParseAddr(e.ToAddr); err != nil || !addr.Is4() {
+			return fmt.Errorf("invalid destination address: %s", e.ToAddr)
+		}
+		if e.ToPort < 0 || e.ToPort > 65535 {
+			return fmt.Errorf("invalid destination port: %d", e.ToPort)
+		}
+	default:
+		return fmt.Errorf("invalid NAT kind: %s", e.Kind)
+	}
+	return nil
+}
+
+// ConflictsWith tells whether both entries would claim the same traffic.
+func (e NatEntry) ConflictsWith(other NatEntry) bool {
+	if e.Kind != other.Kind {
+		return false
+	}
+	if e.Kind == NatMasquerade {
+		return e.Source == other.Source && e.OutInterface == other.OutInterface
+	}
+	return e.InInterface == other.InInterface && e.Protocol == other.Protocol && e.Port == other.Port
+}
+
+func (e NatEntry) Summary() string {
+	if e.Kind == NatMasquerade {
+		return fmt.Sprintf("masquerade %s out on %s", e.Source, e.OutInterface)
+	}
+	return fmt.Sprintf("forward %d/%s on %s to %s:%d", e.Port, e.Protocol, e.InInterface, e.ToAddr, e.TargetPort())
+}
+
+// natRule renders the entry as a line of the *nat table.
+func (e NatEntry) natRule() string {
+	if e.Kind == NatMasquerade {
+		return fmt.Sprintf("-A %s -s %s -o %s -j MASQUERADE", natPostrouting, e.Source, e.OutInterface)
+	}
+	return fmt.Sprintf("-A %s -i %s -p %s --dport %d -j DNAT --to-destination %s:%d",
+		natPrerouting, e.InInterface, e.Protocol, e.Port, e.ToAddr, e.TargetPort())
+}
+
+// RouteRuleArgs returns the `ufw route allow` arguments that let the translated traffic through the
+// forward chain.
+func (e NatEntry) RouteRuleArgs() string {
+	if e.Kind == NatMasquerade {
+		return fmt.Sprintf("out on %s from %s", e.OutInterface, e.Source)
+	}
+	return fmt.Sprintf("in on %s to %s port %d proto %s", e.InInterface, e.ToAddr, e.TargetPort(), e.Protocol)
+}
+
+func ReadNatEntries() ([]NatEntry, error) {
+	content, err := os.ReadFile(beforeRulesPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading before.rules: %w", err)
+	}
+	return ParseNatBlock(string(content))
+}
+
+// ParseNatBlock reads the entries of the fwtui block of before.rules. Lines outside the block are
+// not fwtui's business and are ignored.
+func ParseNatBlock(content string) ([]NatEntry, error) {
+	var entries []NatEntry
+	inBlock := false
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, natBlockBegin):
+			inBlock = true
+		case strings.HasPrefix(line, natBlockEnd):
+			return entries, nil
+		case !inBlock, line == "", strings.HasPrefix(line, "#"), strings.HasPrefix(line, "*"),
+			strings.HasPrefix(line, ":"), strings.HasPrefix(line, "-F "), line == "COMMIT", isNatJump(line):
+		default:
+			entry, err := parseNatRule(line)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+	}
+	if inBlock {
+		return nil, fmt.Errorf("fwtui block in before.rules is not closed with %q", natBlockEnd)
+	}
+	return entries, nil
+}
+
+func parseNatRule(line string) (NatEntry, error) {
+	fields := strings.Fields(line)
+	options := map[string]string{}
+	for i := 0; i+1 < len(fields); i += 2 {
+		options[fields[i]] = fields[i+1]
+	}
+
+	// blocks written before the entries got chains of their own append to the builtin ones
+	switch chain := options["-A"]; {
+	case (chain == natPostrouting || chain == "POSTROUTING") && options["-j"] == "MASQUERADE":
+		return NatEntry{Kind: NatMasquerade, Source: options["-s"], OutInterface: options["-o"]}, nil
+	case (chain == natPrerouting || chain == "PREROUTING") && options["-j"] == "DNAT":
+		port, errPort := strconv.Atoi(options["--dport"])
+		addr, toPort, found := strings.Cut(options["--to-destination"], ":")
+		target := 0
+		if found {
+			target, _ = strconv.Atoi(toPort)
+		}
+		if errPort != nil || addr == "" {
+			break
+		}
+		entry := NatEntry{Kind: NatForward, InInterface: options["-i"], Protocol: options["-p"], Port: port, ToAddr: addr}
+		if target != port {
+			entry.ToPort = target
+		}
+		return entry, nil
+	}
+	return NatEntry{}, fmt.Errorf("unrecognized line in the fwtui block of before.rules: %s", line)
+}
+
+// ReplaceNatBlock writes the entries into the fwtui block. The block goes into the *nat table when
+// before.rules has one already, iptables-restore takes only one section per table, and is a *nat
+// table of its own ahead of the *filter table otherwise. Without entries the block is removed.
+func ReplaceNatBlock(content string, entries []NatEntry) string {
+	lines := strings.Split(content, "\n")
+
+	begin, end := -1, -1
+	ownTable := -1 // where a block with its own table was, it keeps its place
+	for i, line := range lines {
+		if begin < 0 && strings.HasPrefix(strings.TrimSpace(line), natBlockBegin) {
+			begin = i
+		}
+		if begin >= 0 && strings.HasPrefix(strings.TrimSpace(line), natBlockEnd) {
+			end = i
+			break
+		}
+	}
+	if begin >= 0 && end >= 0 {
+		// the blank line written after a block with its own table goes with it
+		if strings.TrimSpace(lines[end-1]) == "COMMIT" {
+			ownTable = begin
+			if end+1 < len(lines) && lines[end+1] == "" {
+				end++
+			}
+		}
+		lines = append(lines[:begin:begin], lines[end+1:]...)
+	}
+
+	if len(entries) == 0 {
+		return strings.Join(lines, "\n")
+	}
+
+	rules := []string{
+		fmt.Sprintf(":%s - [0:0]", natPrerouting),
+		fmt.Sprintf(":%s - [0:0]", natPostrouting),
+		"-F " + natPrerouting,
+		"-F " + natPostrouting,
+		"-A PREROUTING -j " + natPrerouting,
+		"-A POSTROUTING -j " + natPostrouting,
+	}
+	for _, entry := range entries {
+		rules = append(rules, entry.natRule())
+	}
+
+	var block []string
+	at := natTableStart(lines)
+	if at >= 0 {
+		// after the table header and the chains it declares
+		for at < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[at]), ":") {
+			at++
+		}
+		block = append(append([]string{natBlockBegin + " (managed by fwtui, changes inside this block are overwritten)"}, rules...), natBlockEnd)
+	} else {
+		at = ownTable
+		if at < 0 {
+			at = natFilterStart(lines)
+		}
+		block = []string{
+			natBlockBegin + " (managed by fwtui, changes inside this block are overwritten)",
+			"*nat",
+			":PREROUTING ACCEPT [0:0]",
+			":POSTROUTING ACCEPT [0:0]",
+		}
+		block = append(append(block, rules...), "COMMIT", natBlockEnd, "")
+	}
+
+	result := append(append(lines[:at:at], block...), lines[at:]...)
+	return strings.Join(result, "\n")
+}
+
+// natFilterStart returns where a block with its own table goes: ahead of the *filter table and the
+// comments heading it, or at the top of the file.
+func natFilterStart(lines []string) int {
+	at := len(lines)
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "*filter") {
+			at = i
+			break
+		}
+	}
+	// keep the comments heading the *filter table together with it
+	for at < len(lines) && at > 0 && strings.HasPrefix(strings.TrimSpace(lines[at-1]), "#") {
+		at--
+	}
+	if at == len(lines) {
+		at = 0
+	}
+	return at
+}
+
+// natTableStart returns the index of the line following the header of the *nat table, or -1 when
+// there is none.
+func natTableStart(lines []string) int {
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "*nat" {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// isNatJump tells whether a line of the fwtui block is one of the jumps into its chains.
+func isNatJump(line string) bool {
+	return line == "-A PREROUTING -j "+natPrerouting || line == "-A POSTROUTING -j "+natPostrouting
+}
+
+// ApplyNatEntries backs up before.rules, writes the new fwtui block, adds and removes the route
+// rules paired with the changed entries, turns IPv4 forwarding on and reloads ufw. When the reload
+// fails before.rules is put back from the backup and the route rules changed are changed back.
+func ApplyNatEntries(entries, added, removed []NatEntry) string {
+	content, err := os.ReadFile(beforeRulesPath)
+	if err != nil {
+		return fmt.Sprintf("Error: reading %s: %s", beforeRulesPath, err)
+	}
+	for _, entry := range entries {
+		if err := entry.Validate(); err != nil {
+			return fmt.Sprintf("Error: %s", err)
+		}
+	}
+
+	if err := os.MkdirAll(beforeRulesBackupDir, 0755); err != nil {
+		return fmt.Sprintf("Error: creating %s: %s", beforeRulesBackupDir, err)
+	}
+	backupPath := filepath.Join(beforeRulesBackupDir, time.Now().Format("2006-01-02_15-04-05")+".rules")
+	if err := os.WriteFile(backupPath, content, 0640); err != nil {
+		return fmt.Sprintf("Error: backing up before.rules: %s", err)
+	}
+	if err := os.WriteFile(beforeRulesPath, []byte(ReplaceNatBlock(string(content), entries)), 0640); err != nil {
+		return fmt.Sprintf("Error: writing %s: %s", beforeRulesPath, err)
+	}
+
+	outputs := []string{fmt.Sprintf("before.rules backed up to %s\n", backupPath)}
+	var deleted, allowed []NatEntry
+	for _, entry := range removed {
+		output := oscmd.RunCommand(routeDeleteCommand(entry))
+		if !strings.HasPrefix(output, "Error:") {
+			deleted = append(deleted, entry)
+		}
+		outputs = append(outputs, output)
+	}
+	for _, entry := range added {
+		output := oscmd.RunCommand(routeAllowCommand(entry))
+		if !strings.HasPrefix(output, "Error:") {
+			allowed = append(allowed, entry)
+		}
+		outputs = append(outputs, output)
+	}
+
+	var reload string
+	forwarding, err := ReadForwarding()
+	if err == nil && !forwarding.V4 {
+		forwarding.V4 = true
+		reload = SetForwarding(forwarding)
+	} else {
+		reload = oscmd.RunCommand("sudo ufw reload")
+	}
+	outputs = append(outputs, reload)
+	if strings.HasPrefix(reload, "Error:") {
+		// a block ufw can't load leaves the firewall on the old rules until the next reload or boot
+		if err := os.WriteFile(beforeRulesPath, content, 0640); err != nil {
+			outputs = append(outputs, fmt.Sprintf("Error: restoring %s from %s: %s\n", beforeRulesPath, backupPath, err))
+		} else {
+			outputs = append(outputs, fmt.Sprintf("Error: ufw failed to load the new block, %s restored from %s\n", beforeRulesPath, backupPath))
+			outputs = append(outputs, oscmd.RunCommand("sudo ufw reload"))
+		}
+		for _, entry := range allowed {
+			outputs = append(outputs, oscmd.RunCommand(routeDeleteCommand(entry)))
+		}
+		for _, entry := range deleted {
+			outputs = append(outputs, oscmd.RunCommand(routeAllowCommand(entry)))
+		}
+	}
+	return strings.Join(outputs, "")
+}
+
+func routeAllowCommand(entry NatEntry) string {
+	return fmt.Sprintf("sudo ufw route allow %s comment '%s'", entry.RouteRuleArgs(), natComment)
+}
+
+func routeDeleteCommand(entry NatEntry) string {
+	return "sudo ufw route delete allow " + entry.RouteRuleArgs()
+}
+
+// interfaceNamePattern is what the kernel accepts as an interface name minus the characters a shell
+// or before.rules could read as syntax.
+var interfaceNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.@-]{1,15}$`)
+
+// ValidInterfaceName tells whether an interface name can go into a ufw command or before.rules.
+func ValidInterfaceName(name string) bool {
+	return interfaceNamePattern.MatchString(name)
+}
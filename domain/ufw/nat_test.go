@@ -0,0 +1,159 @@
+package ufw
+
+import "testing"
+
+func TestNatEntryValidateInterface(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		iface string
+		valid bool
+	}{
+		{"plain", "eth0", true},
+		{"vlan", "eth0.100", true},
+		{"veth peer", "veth1@if5", true},
+		{"dashes and underscores", "br-lan_2", true},
+		{"longest", "abcdefghijklmno", true},
+		{"empty", "", false},
+		{"too long", "abcdefghijklmnop", false},
+		{"space", "eth0 -j ACCEPT", false},
+		{"semicolon", "eth0;reboot", false},
+		{"dollar", "$(id)", false},
+		{"pipe", "eth0|sh", false},
+		{"ampersand", "eth0&", false},
+		{"backtick", "`id`", false},
+		{"quote", "eth0'", false},
+		{"slash", "eth/0", false},
+		{"colon", "eth0:1", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			masquerade := NatEntry{Kind: NatMasquerade, Source: "10.8.0.0/24", OutInterface: tc.iface}
+			forward := NatEntry{Kind: NatForward, InInterface: tc.iface, Protocol: "tcp", Port: 80, ToAddr: "10.0.0.2"}
+			for _, entry := range []NatEntry{masquerade, forward} {
+				if err := entry.Validate(); (err == nil) != tc.valid {
+					t.Errorf("%s: Validate() = %v, want valid %v", entry.Kind, err, tc.valid)
+				}
+			}
+		})
+	}
+}
+
+const beforeRules = `#
+# rules.before
+#
+
+# Don't delete these required lines, otherwise there will be errors
+*filter
+:ufw-before-input - [0:0]
+COMMIT
+`
+
+func TestReplaceNatBlock(t *testing.T) {
+	masquerade := NatEntry{Kind: NatMasquerade, Source: "10.8.0.0/24", OutInterface: "eth0"}
+	forward := NatEntry{Kind: NatForward, InInterface: "eth0", Protocol: "tcp", Port: 8080, ToAddr: "10.0.0.2", ToPort: 80}
+	chains := `:fwtui-nat-PREROUTING - [0:0]
+:fwtui-nat-POSTROUTING - [0:0]
+-F fwtui-nat-PREROUTING
+-F fwtui-nat-POSTROUTING
+-A PREROUTING -j fwtui-nat-PREROUTING
+-A POSTROUTING -j fwtui-nat-POSTROUTING
+-A fwtui-nat-POSTROUTING -s 10.8.0.0/24 -o eth0 -j MASQUERADE
+-A fwtui-nat-PREROUTING -i eth0 -p tcp --dport 8080 -j DNAT --to-destination 10.0.0.2:80
+`
+	ownTable := `# BEGIN fwtui nat (managed by fwtui, changes inside this block are overwritten)
+*nat
+:PREROUTING ACCEPT [0:0]
+:POSTROUTING ACCEPT [0:0]
+` + chains + `COMMIT
+# END fwtui nat
+
+`
+	natTable := `*nat
+:POSTROUTING ACCEPT [0:0]
+-A POSTROUTING -s 192.168.1.0/24 -o eth1 -j MASQUERADE
+COMMIT
+
+`
+	mergedTable := `*nat
+:POSTROUTING ACCEPT [0:0]
+# BEGIN fwtui nat (managed by fwtui, changes inside this block are overwritten)
+` + chains + `# END fwtui nat
+-A POSTROUTING -s 192.168.1.0/24 -o eth1 -j MASQUERADE
+COMMIT
+
+`
+	oldBlock := `# BEGIN fwtui nat (managed by fwtui, changes inside this block are overwritten)
+*nat
+:PREROUTING ACCEPT [0:0]
+:POSTROUTING ACCEPT [0:0]
+-A POSTROUTING -s 10.8.0.0/24 -o eth0 -j MASQUERADE
+COMMIT
+# END fwtui nat
+
+`
+
+	for _, tc := range []struct {
+		name    string
+		content string
+		entries []NatEntry
+		want    string
+	}{
+		{
+			name:    "a table of its own ahead of the filter table",
+			content: beforeRules,
+			entries: []NatEntry{masquerade, forward},
+			want:    "#\n# rules.before\n#\n\n" + ownTable + "# Don't delete these required lines, otherwise there will be errors\n*filter\n:ufw-before-input - [0:0]\nCOMMIT\n",
+		},
+		{
+			name:    "into an existing nat table",
+			content: natTable + beforeRules,
+			entries: []NatEntry{masquerade, forward},
+			want:    mergedTable + beforeRules,
+		},
+		{
+			name:    "replacing a block",
+			content: mergedTable + beforeRules,
+			entries: []NatEntry{masquerade, forward},
+			want:    mergedTable + beforeRules,
+		},
+		{
+			name:    "replacing a block written without chains",
+			content: oldBlock + beforeRules,
+			entries: []NatEntry{masquerade, forward},
+			want:    ownTable + beforeRules,
+		},
+		{
+			name:    "removing a block with its own table",
+			content: ownTable + beforeRules,
+			want:    beforeRules,
+		},
+		{
+			name:    "removing a block from a nat table",
+			content: mergedTable + beforeRules,
+			want:    natTable + beforeRules,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ReplaceNatBlock(tc.content, tc.entries)
+			if got != tc.want {
+				t.Fatalf("ReplaceNatBlock =\n%s\nwant\n%s", got, tc.want)
+			}
+			entries, err := ParseNatBlock(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tc.entries) {
+				t.Fatalf("entries read back = %+v, want %+v", entries, tc.entries)
+			}
+			for i := range entries {
+				if entries[i] != tc.entries[i] {
+					t.Errorf("entry %d read back = %+v, want %+v", i, entries[i], tc.entries[i])
+				}
+			}
+		})
+	}
+
+	entries, err := ParseNatBlock(oldBlock)
+	if err != nil || len(entries) != 1 || entries[0] != masquerade {
+		t.Errorf("entries of a block written without chains = %+v, %v, want the masquerade", entries, err)
+	}
+}
@@ -0,0 +1,44 @@
+package ufw
+
+import (
+	"strconv"
+	"strings"
+)
+
+type PortRange struct {
+	From int
+	To   int
+}
+
+// ParsePortRanges reads a ufw port spec like "22", "80,443" or "6000:6007". "any" is the whole port range.
+func ParsePortRanges(spec string) []PortRange {
+	if spec == "any" || spec == "" {
+		return []PortRange{{1, 65535}}
+	}
+	var ranges []PortRange
+	for _, part := range strings.Split(spec, ",") {
+		from, to, isRange := strings.Cut(part, ":")
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			continue
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(to); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, PortRange{start, end})
+	}
+	return ranges
+}
+
+// PortSpecContains tells whether a ufw port spec covers the port.
+func PortSpecContains(spec string, port int) bool {
+	for _, r := range ParsePortRanges(spec) {
+		if port >= r.From && port <= r.To {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,441 @@
+package ufw
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"fwtui/domain/services"
+	"os"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+const userRulesPath = "/etc/ufw/user.rules"
+const userRules6Path = "/etc/ufw/user6.rules"
+
+const tuplePrefix = "### tuple ###"
+
+// Rule is a single ufw rule. Line is the rule as `ufw status` shows it, the remaining fields are read
+// from the rule tuples ufw keeps in user.rules and user6.rules.
+type Rule struct {
+	Number int
+	Line   string
+
+	Action       string // allow, deny, reject or limit
+	Log          string // empty, log or log-all
+	Route        bool
+	Direction    string // in or out
+	InterfaceIn  string
+	InterfaceOut string
+	Protocol     string // tcp, udp or any
+	DestPort     string
+	Dest         string
+	SrcPort      string
+	Source       string
+	DestApp      string
+	SrcApp       string
+	V6           bool
+	Comment      string
+}
+
+// LoadRules reads the rules in the order ufw numbers them and attaches the matching lines of the
+// `ufw status verbose` output. When the rule files can't be read only the status lines are returned.
+func LoadRules(status string) []Rule {
+	statusRules := ParseStatusRules(status)
+
+	rules, err := ReadRuleTuples()
+	if err != nil {
+		return statusRules
+	}
+
+	for i := range rules {
+		if i < len(statusRules) {
+			rules[i].Line = statusRules[i].Line
+		} else {
+			rules[i].Line = fmt.Sprintf("[%2d] %s", rules[i].Number, rules[i].Summary())
+		}
+		rules[i].Line = annotateServices(rules[i])
+	}
+	return rules
+}
+
+// ReadRules is LoadRules for changing the rules. It fails when the status or the rule files can't be
+// read, the status lines alone are no base for deleting by number.
+func ReadRules() ([]Rule, error) {
+	status := StatusVerbose()
+	if strings.HasPrefix(status, "Error:") {
+		return nil, errors.New(strings.TrimSpace(strings.TrimPrefix(status, "Error:")))
+	}
+	if _, err := ReadRuleTuples(); err != nil {
+		return nil, err
+	}
+	return LoadRules(status), nil
+}
+
+// annotateServices names the service behind the destination port of the rule line, turning
+// "22/tcp    ALLOW IN" into "22/tcp (ssh) ALLOW IN" while keeping the columns aligned where the
+// padding allows it.
+func annotateServices(rule Rule) string {
+	if rule.DestPort == "any" || rule.DestApp != "" {
+		return rule.Line
+	}
+	token := rule.DestPort
+	if rule.Protocol != "any" {
+		token += "/" + rule.Protocol
+	}
+	label := services.Default().Label(token)
+	if label == "" {
+		return rule.Line
+	}
+
+	fields := strings.Fields(rule.Line)
+	index := -1
+	offset := 0
+	for _, field := range fields {
+		pos := strings.Index(rule.Line[offset:], field) + offset
+		offset = pos + len(field)
+		if field == token {
+			index = pos
+			break
+		}
+	}
+	if index < 0 {
+		return rule.Line
+	}
+
+	end := index + len(token)
+	insert := " (" + label + ")"
+	// the column ends where the padding of at least two spaces starts, e.g. after "22/tcp (v6)"
+	gap := strings.Index(rule.Line[end:], "  ")
+	if gap < 0 {
+		return rule.Line[:end] + insert + rule.Line[end:]
+	}
+	gap += end
+	rest := strings.TrimLeft(rule.Line[gap:], " ")
+	padding := len(rule.Line) - gap - len(rest)
+	return rule.Line[:end] + insert + rule.Line[end:gap] + strings.Repeat(" ", max(1, padding-len(insert))) + rest
+}
+
+// ReadRuleTuples parses user.rules followed by user6.rules, which is the order of `ufw status numbered`.
+func ReadRuleTuples() ([]Rule, error) {
+	rulesV4, err := os.ReadFile(userRulesPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading user.rules: %w", err)
+	}
+
+	rulesV6, err := readUserRules6()
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseRuleTuples(string(rulesV4), rulesV6), nil
+}
+
+// readUserRules6 reads user6.rules, or nothing while IPV6=no: ufw then leaves the IPv6 rules out of
+// `ufw status numbered` and they take no numbers. When the switch can't be read the file is read.
+func readUserRules6() (string, error) {
+	if enabled, err := ReadIPv6Setting(); err == nil && !enabled {
+		return "", nil
+	}
+	rulesV6, err := os.ReadFile(userRules6Path)
+	if err != nil {
+		return "", fmt.Errorf("reading user6.rules: %w", err)
+	}
+	return string(rulesV6), nil
+}
+
+// ParseRuleTuples reads the rules of user.rules and user6.rules. ufw writes one tuple per port entry
+// of an application profile and lists them as one rule, so they are merged the same way.
+func ParseRuleTuples(rulesV4, rulesV6 string) []Rule {
+	var rules []Rule
+	for _, file := range []struct {
+		content string
+		v6      bool
+	}{{rulesV4, false}, {rulesV6, true}} {
+		apps := map[string]int{}
+		for _, line := range strings.Split(file.content, "\n") {
+			if !strings.HasPrefix(line, tuplePrefix) {
+				continue
+			}
+			rule, ok := parseTuple(strings.TrimPrefix(line, tuplePrefix))
+			if !ok {
+				continue
+			}
+			if key := rule.appTupleKey(); key != "" {
+				if i, seen := apps[key]; seen {
+					rules[i] = rules[i].withAppEntry(rule)
+					continue
+				}
+				apps[key] = len(rules)
+			}
+			rule.V6 = file.v6
+			rule.Number = len(rules) + 1
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// appTupleKey identifies the tuples ufw writes for one application rule, the way `ufw status`
+// folds them. It is empty for rules without an application.
+func (r Rule) appTupleKey() string {
+	if r.DestApp == "" && r.SrcApp == "" {
+		return ""
+	}
+	dest := lo.Ternary(r.DestApp != "", r.DestApp, r.DestPort)
+	src := lo.Ternary(r.SrcApp != "", r.SrcApp, r.SrcPort)
+	key := fmt.Sprintf("%s %s %s %s", dest, r.Dest, src, r.Source)
+	if r.InterfaceIn != "" {
+		key += " in_" + r.InterfaceIn
+	}
+	if r.InterfaceOut != "" {
+		key += " out_" + r.InterfaceOut
+	}
+	return key
+}
+
+// withAppEntry adds the ports of another tuple of the application rule, a rule whose entries differ
+// in protocol matches any protocol.
+func (r Rule) withAppEntry(entry Rule) Rule {
+	if r.Protocol != entry.Protocol {
+		r.Protocol = "any"
+	}
+	if r.DestApp != "" {
+		r.DestPort = joinPorts(r.DestPort, entry.DestPort)
+	}
+	if r.SrcApp != "" {
+		r.SrcPort = joinPorts(r.SrcPort, entry.SrcPort)
+	}
+	return r
+}
+
+func joinPorts(ports, more string) string {
+	list := strings.Split(ports, ",")
+	for _, port := range strings.Split(more, ",") {
+		if !lo.Contains(list, port) {
+			list = append(list, port)
+		}
+	}
+	return strings.Join(list, ",")
+}
+
+// parseTuple reads "action proto dport dst sport src [dapp sapp] direction [comment=hex]".
+func parseTuple(tuple string) (Rule, bool) {
+	fields := strings.Fields(tuple)
+
+	var rule Rule
+	if len(fields) > 0 && strings.HasPrefix(fields[len(fields)-1], "comment=") {
+		encoded := strings.TrimPrefix(fields[len(fields)-1], "comment=")
+		if decoded, err := hex.DecodeString(encoded); err == nil {
+			rule.Comment = string(decoded)
+		}
+		fields = fields[:len(fields)-1]
+	}
+
+	if len(fields) != 7 && len(fields) != 9 {
+		return Rule{}, false
+	}
+
+	action := fields[0]
+	if strings.HasPrefix(action, "route:") {
+		rule.Route = true
+		action = strings.TrimPrefix(action, "route:")
+	}
+	rule.Action, rule.Log, _ = strings.Cut(action, "_")
+	rule.Protocol = fields[1]
+	rule.DestPort = fields[2]
+	rule.Dest = normalizeAddr(fields[3])
+	rule.SrcPort = fields[4]
+	rule.Source = normalizeAddr(fields[5])
+
+	direction := fields[len(fields)-1]
+	if len(fields) == 9 {
+		rule.DestApp = decodeApp(fields[6])
+		rule.SrcApp = decodeApp(fields[7])
+	}
+
+	for i, part := range strings.Split(direction, "!") {
+		dir, iface, _ := strings.Cut(part, "_")
+		if i == 0 {
+			rule.Direction = dir
+		}
+		switch dir {
+		case "in":
+			rule.InterfaceIn = iface
+		case "out":
+			rule.InterfaceOut = iface
+		}
+	}
+
+	return rule, true
+}
+
+func normalizeAddr(addr string) string {
+	if addr == "0.0.0.0/0" || addr == "::/0" {
+		return "any"
+	}
+	return addr
+}
+
+func decodeApp(app string) string {
+	if app == "-" {
+		return ""
+	}
+	return strings.ReplaceAll(app, "%20", " ")
+}
+
+// Summary describes the rule in roughly the words of the ufw command that created it.
+func (r Rule) Summary() string {
+	var parts []string
+	if r.Route {
+		parts = append(parts, "route", r.Action)
+		if r.InterfaceIn != "" {
+			parts = append(parts, "in on", r.InterfaceIn)
+		}
+		if r.InterfaceOut != "" {
+			parts = append(parts, "out on", r.InterfaceOut)
+		}
+	} else {
+		parts = append(parts, r.Action, r.Direction)
+		if iface := r.Interface(); iface != "" {
+			parts = append(parts, "on", iface)
+		}
+	}
+	parts = append(parts, "from", describeEndpoint(r.Source, r.SrcPort, r.SrcApp))
+	parts = append(parts, "to", describeEndpoint(r.Dest, r.DestPort, r.DestApp))
+	if r.Protocol != "any" && r.DestApp == "" && r.SrcApp == "" {
+		parts = append(parts, "proto", r.Protocol)
+	}
+	if r.V6 {
+		parts = append(parts, "(v6)")
+	}
+	return strings.Join(parts, " ")
+}
+
+// Args returns the arguments of the ufw command that creates the rule, e.g.
+// "allow in on eth0 from 10.0.0.0/8 to any port 22 proto tcp comment 'ssh'". For an IPv4 rule
+// without addresses ufw adds the IPv6 twin as well.
+func (r Rule) Args() string {
+	return r.args(false)
+}
+
+// ExactArgs is like Args but spells out the address family, so that the command creates exactly
+// this rule and no twin. It identifies a rule regardless of its number.
+func (r Rule) ExactArgs() string {
+	return r.args(true)
+}
+
+// InsertArgs returns the ExactArgs of the rule inserted at a position of `ufw status numbered`.
+func (r Rule) InsertArgs(position int) string {
+	args := r.ExactArgs()
+	if r.Route {
+		return fmt.Sprintf("route insert %d %s", position, strings.TrimPrefix(args, "route "))
+	}
+	return fmt.Sprintf("insert %d %s", position, args)
+}
+
+func (r Rule) args(exact bool) string {
+	var parts []string
+	if r.Route {
+		parts = append(parts, "route", r.Action)
+		if r.InterfaceIn != "" {
+			parts = append(parts, "in", "on", r.InterfaceIn)
+		}
+		if r.InterfaceOut != "" {
+			parts = append(parts, "out", "on", r.InterfaceOut)
+		}
+	} else {
+		parts = append(parts, r.Action, r.Direction)
+		if iface := r.Interface(); iface != "" {
+			parts = append(parts, "on", iface)
+		}
+	}
+	if r.Log != "" {
+		parts = append(parts, r.Log)
+	}
+	if r.Protocol != "any" && r.DestApp == "" && r.SrcApp == "" {
+		parts = append(parts, "proto", r.Protocol)
+	}
+	parts = append(parts, "from", argsAddr(r.Source, r.V6, exact))
+	parts = append(parts, endpointArgs(r.SrcPort, r.SrcApp)...)
+	parts = append(parts, "to", argsAddr(r.Dest, r.V6, exact))
+	parts = append(parts, endpointArgs(r.DestPort, r.DestApp)...)
+	if r.Comment != "" {
+		parts = append(parts, "comment", shellQuote(r.Comment))
+	}
+	return strings.Join(parts, " ")
+}
+
+// argsAddr keeps the address family of v6 rules, "any" would make ufw add the v4 twin as well.
+func argsAddr(addr string, v6, exact bool) string {
+	switch {
+	case addr != "any":
+		return addr
+	case v6:
+		return "::/0"
+	case exact:
+		return "0.0.0.0/0"
+	default:
+		return addr
+	}
+}
+
+func endpointArgs(port, app string) []string {
+	switch {
+	case app != "":
+		return []string{"app", shellQuote(app)}
+	case port != "any":
+		return []string{"port", port}
+	default:
+		return nil
+	}
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func describeEndpoint(addr, port, app string) string {
+	switch {
+	case app != "":
+		return fmt.Sprintf("%s app %s", addr, app)
+	case port != "any":
+		return fmt.Sprintf("%s port %s", addr, port)
+	default:
+		return addr
+	}
+}
+
+// Interface returns the interface of a non-route rule.
+func (r Rule) Interface() string {
+	if r.Direction == "out" {
+		return r.InterfaceOut
+	}
+	return r.InterfaceIn
+}
+
+// FirstOfFamily returns the number of the first rule of the family, where a rule that has to come
+// before all others of it is inserted, or 0 when the family has none and the rule is appended.
+// ufw refuses to insert a rule at the position of a rule of the other family.
+func FirstOfFamily(rules []Rule, v6 bool) int {
+	for _, rule := range rules {
+		if rule.V6 == v6 {
+			return rule.Number
+		}
+	}
+	return 0
+}
+
+// IsAllowing tells whether matching traffic is let through.
+func (r Rule) IsAllowing() bool {
+	return r.Action == "allow" || r.Action == "limit"
+}
+
+// MatchesPort tells whether the rule applies to the destination port and protocol.
+func (r Rule) MatchesPort(port int, protocol string) bool {
+	if r.Protocol != "any" && r.Protocol != protocol {
+		return false
+	}
+	return PortSpecContains(r.DestPort, port)
+}
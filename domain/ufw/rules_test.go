@@ -0,0 +1,75 @@
+package ufw
+
+import "testing"
+
+func TestParseRuleTuplesFoldsAppEntries(t *testing.T) {
+	v4 := `*filter
+### RULES ###
+
+### tuple ### allow udp 137,138 0.0.0.0/0 any 192.168.1.0/24 Samba - in
+-A ufw-user-input -p udp -m multiport --dports 137,138 -s 192.168.1.0/24 -j ACCEPT -m comment --comment 'dapp_Samba'
+
+### tuple ### allow tcp 139,445 0.0.0.0/0 any 192.168.1.0/24 Samba - in
+-A ufw-user-input -p tcp -m multiport --dports 139,445 -s 192.168.1.0/24 -j ACCEPT -m comment --comment 'dapp_Samba'
+
+### tuple ### allow udp 137,138 0.0.0.0/0 any 10.0.0.0/8 Samba - in
+-A ufw-user-input -p udp -m multiport --dports 137,138 -s 10.0.0.0/8 -j ACCEPT -m comment --comment 'dapp_Samba'
+
+### tuple ### allow tcp 22 0.0.0.0/0 any 0.0.0.0/0 in
+-A ufw-user-input -p tcp --dport 22 -j ACCEPT
+
+### tuple ### allow tcp 139,445 0.0.0.0/0 any 10.0.0.0/8 Samba - in
+-A ufw-user-input -p tcp -m multiport --dports 139,445 -s 10.0.0.0/8 -j ACCEPT -m comment --comment 'dapp_Samba'
+
+### END RULES ###
+COMMIT
+`
+	rules := ParseRuleTuples(v4, "")
+	want := []struct {
+		number   int
+		source   string
+		protocol string
+		ports    string
+	}{
+		{1, "192.168.1.0/24", "any", "137,138,139,445"},
+		{2, "10.0.0.0/8", "any", "137,138,139,445"},
+		{3, "any", "tcp", "22"},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("%d rules, want %d: %+v", len(rules), len(want), rules)
+	}
+	for i, w := range want {
+		r := rules[i]
+		if r.Number != w.number || r.Source != w.source || r.Protocol != w.protocol || r.DestPort != w.ports {
+			t.Errorf("rule %d = %d %s %s %s, want %+v", i+1, r.Number, r.Source, r.Protocol, r.DestPort, w)
+		}
+	}
+
+	listing := `Chain ufw-user-input (1 references)
+    pkts      bytes target     prot opt in     out     source               destination
+       1       100 ACCEPT     udp  --  *      *       192.168.1.0/24       0.0.0.0/0
+       2       200 ACCEPT     tcp  --  *      *       192.168.1.0/24       0.0.0.0/0
+       4       400 ACCEPT     udp  --  *      *       10.0.0.0/8           0.0.0.0/0
+       8       800 ACCEPT     tcp  --  *      *       0.0.0.0/0            0.0.0.0/0
+      16      1600 ACCEPT     tcp  --  *      *       10.0.0.0/8           0.0.0.0/0
+`
+	counters := MapRuleCounters(v4, listing, "", "")
+	for number, c := range map[int]Counter{1: {3, 300}, 2: {20, 2000}, 3: {8, 800}} {
+		if counters[number] != c {
+			t.Errorf("counter of rule %d = %v, want %v", number, counters[number], c)
+		}
+	}
+}
+
+func TestFirstOfFamily(t *testing.T) {
+	rules := numbered(in("allow", "tcp", "22", "any"), in("allow", "tcp", "80", "any"), v6(in("allow", "tcp", "22", "any")))
+	if got := FirstOfFamily(rules, false); got != 1 {
+		t.Errorf("first IPv4 rule = %d, want 1", got)
+	}
+	if got := FirstOfFamily(rules, true); got != 3 {
+		t.Errorf("first IPv6 rule = %d, want 3", got)
+	}
+	if got := FirstOfFamily(rules[:2], true); got != 0 {
+		t.Errorf("first IPv6 rule without any = %d, want 0 to append", got)
+	}
+}
@@ -0,0 +1,132 @@
+package ufw
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// RuleSet is a set of rules created from one template for a list of addresses, like the addresses
+// of a host name or the members of an address group. The rules carry the tag in their comment and
+// differ only in the address.
+type RuleSet struct {
+	Tag      string
+	Template Rule // the first rule, new addresses get a copy of it
+	Rules    []Rule
+}
+
+// addrIsSource tells on which side of the rule the address was written: the source, unless the rule
+// names no source.
+func (r Rule) addrIsSource() bool {
+	return r.Source != "any"
+}
+
+// SetAddr returns the address the rule was created for within its rule set.
+func (r Rule) SetAddr() string {
+	if r.addrIsSource() {
+		return r.Source
+	}
+	return r.Dest
+}
+
+// WithSetAddr returns a copy of the rule for another address of its rule set.
+func (r Rule) WithSetAddr(addr string) Rule {
+	if r.addrIsSource() {
+		r.Source = addr
+	} else {
+		r.Dest = addr
+	}
+	r.V6 = strings.Contains(addr, ":")
+	r.Number, r.Line = 0, ""
+	return r
+}
+
+// GroupRuleSets collects the rules whose comment carries a tag, rules differing only in the address
+// and the address family belong to one set.
+func GroupRuleSets(rules []Rule, tagOf func(comment string) (string, bool)) []RuleSet {
+	var sets []RuleSet
+	index := map[string]int{}
+	for _, rule := range rules {
+		tag, ok := tagOf(rule.Comment)
+		if !ok {
+			continue
+		}
+		key := fmt.Sprintf("%s|%s", tag, rule.WithSetAddr("0.0.0.0").Args())
+		i, known := index[key]
+		if !known {
+			i = len(sets)
+			index[key] = i
+			sets = append(sets, RuleSet{Tag: tag, Template: rule})
+		}
+		sets[i].Rules = append(sets[i].Rules, rule)
+	}
+	return sets
+}
+
+// Diff compares the rules of the set with the addresses it should cover and returns the rules to
+// add for new addresses and the rules of addresses that are gone. Addresses are compared the way ufw
+// writes them, e.g. 10.0.0.1/24 matches a rule for 10.0.0.0/24. A rule to add carries the number of
+// the rule it replaces, see RuleChangeCommands.
+func (s RuleSet) Diff(addrs []string) (add []Rule, remove []Rule) {
+	current := map[string]bool{}
+	for _, rule := range s.Rules {
+		current[CanonicalAddr(rule.SetAddr())] = true
+	}
+	wanted := map[string]bool{}
+	var added []string
+	for _, addr := range addrs {
+		addr = CanonicalAddr(addr)
+		if !current[addr] && !wanted[addr] {
+			added = append(added, addr)
+		}
+		wanted[addr] = true
+	}
+	for _, rule := range s.Rules {
+		if !wanted[CanonicalAddr(rule.SetAddr())] {
+			remove = append(remove, rule)
+		}
+	}
+
+	replaced := map[int]bool{}
+	for _, addr := range added {
+		rule := s.Template.WithSetAddr(addr)
+		rule.Number = s.placeFor(rule.V6, remove, replaced)
+		add = append(add, rule)
+	}
+	return add, remove
+}
+
+// placeFor picks the number a new rule of the family takes: that of a removed rule of the family not
+// replaced yet, else that of the last rule of the family in the set, else 0 to append it.
+func (s RuleSet) placeFor(v6 bool, remove []Rule, replaced map[int]bool) int {
+	for _, rule := range remove {
+		if rule.V6 == v6 && !replaced[rule.Number] {
+			replaced[rule.Number] = true
+			return rule.Number
+		}
+	}
+	place := 0
+	for _, rule := range s.Rules {
+		if rule.V6 == v6 {
+			place = max(place, rule.Number)
+		}
+	}
+	return place
+}
+
+// CanonicalAddr writes an address or network like ufw stores it: the network of a prefix with the
+// host bits cleared, a single address without its prefix length and IPv6 in its short lower case
+// form. Anything else is returned as is.
+func CanonicalAddr(addr string) string {
+	if prefix, err := netip.ParsePrefix(addr); err == nil {
+		prefix = prefix.Masked()
+		if prefix.IsSingleIP() {
+			return prefix.Addr().String()
+		}
+		return prefix.String()
+	}
+	if ip, err := netip.ParseAddr(addr); err == nil {
+		return ip.String()
+	}
+	return addr
+}
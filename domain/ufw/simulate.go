@@ -0,0 +1,109 @@
+package ufw
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+const (
+	PacketIn     = "in"
+	PacketOut    = "out"
+	PacketRouted = "routed"
+)
+
+type Packet struct {
+	Direction    string // in, out or routed
+	Protocol     string // tcp or udp
+	Source       netip.Addr
+	Dest         netip.Addr
+	Port         int
+	SourcePort   int    // 0 when unknown, rules naming a source port don't match then
+	Interface    string // interface the packet arrives on, or leaves through for outgoing packets
+	InterfaceOut string // interface a routed packet leaves through
+}
+
+type Verdict struct {
+	Action string
+	Rule   *Rule // nil when the default policy decided
+	Reason string
+}
+
+// VerdictRoutingOff is the action for a routed packet while IP forwarding is off: the host doesn't
+// route it, whatever the rules say.
+const VerdictRoutingOff = "routing off"
+
+// Simulate walks the rules in order like ufw does and returns the action of the first matching rule,
+// or the default policy of the packet's direction.
+func Simulate(rules []Rule, defaultPolicy string, packet Packet) Verdict {
+	if packet.Direction == PacketRouted && (defaultPolicy == "disabled" || defaultPolicy == "") {
+		return Verdict{Action: VerdictRoutingOff, Reason: "IP forwarding is off, the host doesn't route packets"}
+	}
+
+	for _, rule := range rules {
+		if !rule.MatchesPacket(packet) {
+			continue
+		}
+		reason := fmt.Sprintf("rule %d: %s", rule.Number, rule.Summary())
+		if rule.Action == "limit" {
+			reason += " (denied when the source opens 6 or more connections within 30 seconds)"
+		}
+		return Verdict{Action: rule.Action, Rule: &rule, Reason: reason}
+	}
+
+	return Verdict{Action: defaultPolicy, Reason: fmt.Sprintf("default %s policy", directionPolicyName(packet.Direction))}
+}
+
+func directionPolicyName(direction string) string {
+	switch direction {
+	case PacketIn:
+		return "incoming"
+	case PacketOut:
+		return "outgoing"
+	default:
+		return "routed"
+	}
+}
+
+// MatchesPacket tells whether the rule applies to the packet. A rule matching a specific source port
+// only matches a packet whose source port is known.
+func (r Rule) MatchesPacket(p Packet) bool {
+	if r.V6 != p.Source.Is6() {
+		return false
+	}
+	if r.Route != (p.Direction == PacketRouted) {
+		return false
+	}
+	if !r.Route && r.Direction != p.Direction {
+		return false
+	}
+
+	if r.Route {
+		if r.InterfaceIn != "" && r.InterfaceIn != p.Interface {
+			return false
+		}
+		if r.InterfaceOut != "" && r.InterfaceOut != p.InterfaceOut {
+			return false
+		}
+	} else if iface := r.Interface(); iface != "" && iface != p.Interface {
+		return false
+	}
+
+	if !r.MatchesPort(p.Port, p.Protocol) {
+		return false
+	}
+	if r.SrcPort != "any" && (p.SourcePort == 0 || !PortSpecContains(r.SrcPort, p.SourcePort)) {
+		return false
+	}
+	return addrMatches(r.Source, p.Source) && addrMatches(r.Dest, p.Dest)
+}
+
+func addrMatches(ruleAddr string, addr netip.Addr) bool {
+	if ruleAddr == "any" {
+		return true
+	}
+	prefix, err := parsePrefix(ruleAddr)
+	if err != nil {
+		return false
+	}
+	return prefix.Contains(addr)
+}
@@ -0,0 +1,117 @@
+package ufw
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	client4, host4 := netip.MustParseAddr("203.0.113.7"), netip.MustParseAddr("192.0.2.1")
+	client6, host6 := netip.MustParseAddr("2001:db8::7"), netip.MustParseAddr("2001:db8::1")
+
+	web := in("allow", "tcp", "80,443", "any")
+	web.DestApp = "Nginx Full"
+	dns := in("allow", "udp", "any", "any")
+	dns.SrcPort = "53"
+	rules := numbered(
+		in("deny", "tcp", "22", "198.51.100.0/24"),
+		in("limit", "tcp", "22", "any"),
+		in("allow", "tcp", "6000:6010", "any"),
+		web,
+		dns,
+		v6(in("deny", "tcp", "8080", "any")),
+		in("allow", "tcp", "8080", "any"),
+		v6(in("allow", "tcp", "8080", "any")),
+		Rule{Action: "allow", Route: true, InterfaceIn: "wg0", Protocol: "any", DestPort: "any", SrcPort: "any", Source: "any", Dest: "any"},
+	)
+
+	for _, tc := range []struct {
+		name   string
+		packet Packet
+		policy string
+		action string
+		rule   int
+	}{
+		{
+			name:   "the first matching rule decides",
+			packet: Packet{Direction: PacketIn, Protocol: "tcp", Source: netip.MustParseAddr("198.51.100.9"), Dest: host4, Port: 22},
+			action: "deny", rule: 1,
+		},
+		{
+			name:   "a later rule decides when the earlier ones don't match",
+			packet: Packet{Direction: PacketIn, Protocol: "tcp", Source: client4, Dest: host4, Port: 22},
+			action: "limit", rule: 2,
+		},
+		{
+			name:   "inside a port range",
+			packet: Packet{Direction: PacketIn, Protocol: "tcp", Source: client4, Dest: host4, Port: 6005},
+			action: "allow", rule: 3,
+		},
+		{
+			name:   "past a port range",
+			packet: Packet{Direction: PacketIn, Protocol: "tcp", Source: client4, Dest: host4, Port: 6011},
+			policy: "deny", action: "deny",
+		},
+		{
+			name:   "an IPv6 packet skips an IPv4 app rule",
+			packet: Packet{Direction: PacketIn, Protocol: "tcp", Source: client6, Dest: host6, Port: 443},
+			policy: "deny", action: "deny",
+		},
+		{
+			name:   "an IPv4 port of an app rule",
+			packet: Packet{Direction: PacketIn, Protocol: "tcp", Source: client4, Dest: host4, Port: 443},
+			action: "allow", rule: 4,
+		},
+		{
+			name:   "the protocol of an app rule",
+			packet: Packet{Direction: PacketIn, Protocol: "udp", Source: client4, Dest: host4, Port: 443},
+			policy: "reject", action: "reject",
+		},
+		{
+			name:   "a known source port",
+			packet: Packet{Direction: PacketIn, Protocol: "udp", Source: client4, Dest: host4, Port: 33000, SourcePort: 53},
+			action: "allow", rule: 5,
+		},
+		{
+			name:   "an unknown source port",
+			packet: Packet{Direction: PacketIn, Protocol: "udp", Source: client4, Dest: host4, Port: 33000},
+			policy: "deny", action: "deny",
+		},
+		{
+			name:   "IPv4 skips the IPv6 rules",
+			packet: Packet{Direction: PacketIn, Protocol: "tcp", Source: client4, Dest: host4, Port: 8080},
+			action: "allow", rule: 7,
+		},
+		{
+			name:   "IPv6 skips the IPv4 rules",
+			packet: Packet{Direction: PacketIn, Protocol: "tcp", Source: client6, Dest: host6, Port: 8080},
+			action: "deny", rule: 6,
+		},
+		{
+			name:   "outgoing packets skip incoming rules",
+			packet: Packet{Direction: PacketOut, Protocol: "tcp", Source: host4, Dest: client4, Port: 22},
+			policy: "allow", action: "allow",
+		},
+		{
+			name:   "route rule",
+			packet: Packet{Direction: PacketRouted, Protocol: "tcp", Source: client4, Dest: host4, Port: 22, Interface: "wg0", InterfaceOut: "eth0"},
+			policy: "deny", action: "allow", rule: 9,
+		},
+		{
+			name:   "routing off",
+			packet: Packet{Direction: PacketRouted, Protocol: "tcp", Source: client4, Dest: host4, Port: 22, Interface: "wg0", InterfaceOut: "eth0"},
+			policy: "disabled", action: VerdictRoutingOff,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			verdict := Simulate(rules, tc.policy, tc.packet)
+			rule := 0
+			if verdict.Rule != nil {
+				rule = verdict.Rule.Number
+			}
+			if verdict.Action != tc.action || rule != tc.rule {
+				t.Errorf("verdict = %s by rule %d (%s), want %s by rule %d", verdict.Action, rule, verdict.Reason, tc.action, tc.rule)
+			}
+		})
+	}
+}
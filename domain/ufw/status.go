@@ -0,0 +1,71 @@
+package ufw
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseStatusRules extracts the rules from `ufw status verbose` output. The rules are listed in the
+// same order as `ufw status numbered`, so the number is derived from the position.
+func ParseStatusRules(status string) []Rule {
+	lines := strings.Split(status, "\n")
+
+	var rules []Rule
+	inRules := false
+	for _, line := range lines {
+		if !inRules {
+			inRules = strings.HasPrefix(line, "--")
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		rules = append(rules, Rule{
+			Number: len(rules) + 1,
+			Line:   fmt.Sprintf("[%2d] %s", len(rules)+1, line),
+		})
+	}
+	return rules
+}
+
+const (
+	LoggingOff    = "off"
+	LoggingLow    = "low"
+	LoggingMedium = "medium"
+	LoggingHigh   = "high"
+	LoggingFull   = "full"
+)
+
+var LoggingLevels = []string{LoggingOff, LoggingLow, LoggingMedium, LoggingHigh, LoggingFull}
+
+// ParseStatusFlags reads whether ufw is active and the logging level from `ufw status verbose` output.
+func ParseStatusFlags(status string) (enabled bool, loggingLevel string) {
+	loggingLevel = LoggingOff
+	lines := strings.Split(status, "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "Status: active") {
+			enabled = true
+		}
+		if strings.HasPrefix(line, "Logging:") {
+			loggingLevel = parseLoggingLevel(strings.TrimPrefix(line, "Logging:"))
+		}
+	}
+	return
+}
+
+// parseLoggingLevel reads "on (low)" or "off". "on" without a level means ufw's default, low.
+func parseLoggingLevel(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || fields[0] != "on" {
+		return LoggingOff
+	}
+	if len(fields) > 1 {
+		level := strings.Trim(fields[1], "()")
+		for _, known := range LoggingLevels {
+			if level == known {
+				return level
+			}
+		}
+	}
+	return LoggingLow
+}
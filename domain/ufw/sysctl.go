@@ -0,0 +1,156 @@
+package ufw
+
+import (
+	"fmt"
+	"fwtui/utils/oscmd"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	sysctlPath      = "/etc/ufw/sysctl.conf"
+	sysctlBackupDir = "/etc/ufw/backup/sysctl"
+)
+
+const (
+	forwardingV4Key = "net/ipv4/ip_forward"
+	forwardingV6Key = "net/ipv6/conf/all/forwarding"
+)
+
+// Forwarding holds the IP forwarding switches ufw applies from its sysctl.conf on every start.
+type Forwarding struct {
+	V4 bool
+	V6 bool
+}
+
+func ReadForwarding() (Forwarding, error) {
+	content, err := os.ReadFile(sysctlPath)
+	if err != nil {
+		return Forwarding{}, fmt.Errorf("reading sysctl.conf: %w", err)
+	}
+	return ParseForwarding(string(content)), nil
+}
+
+// ParseForwarding reads the forwarding keys from sysctl.conf content. Keys may be written with dots
+// or slashes, commented out keys are off and the last assignment wins.
+func ParseForwarding(content string) Forwarding {
+	var forwarding Forwarding
+	for _, line := range strings.Split(content, "\n") {
+		key, value, ok := parseSysctlLine(line)
+		if !ok {
+			continue
+		}
+		switch key {
+		case forwardingV4Key:
+			forwarding.V4 = value == "1"
+		case forwardingV6Key:
+			forwarding.V6 = value == "1"
+		}
+	}
+	return forwarding
+}
+
+// SetForwardingInConf rewrites the forwarding keys of sysctl.conf content whose value changes, keys
+// that already have the wanted value are left as they are. The first line mentioning a changed key,
+// commented out or not, takes the new value and later assignments are dropped, so the rest of the
+// file stays as it was.
+func SetForwardingInConf(content string, forwarding Forwarding) string {
+	current := ParseForwarding(content)
+	values := map[string]bool{}
+	if forwarding.V4 != current.V4 {
+		values[forwardingV4Key] = forwarding.V4
+	}
+	if forwarding.V6 != current.V6 {
+		values[forwardingV6Key] = forwarding.V6
+	}
+	written := map[string]bool{}
+
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		key, ok := sysctlLineKey(line)
+		if _, managed := values[key]; !ok || !managed {
+			lines = append(lines, line)
+			continue
+		}
+		commented := strings.HasPrefix(strings.TrimSpace(line), "#")
+		if written[key] {
+			if !commented {
+				continue
+			}
+			lines = append(lines, line)
+			continue
+		}
+		written[key] = true
+		lines = append(lines, sysctlAssignment(key, values[key]))
+	}
+
+	for _, key := range []string{forwardingV4Key, forwardingV6Key} {
+		if _, managed := values[key]; managed && !written[key] {
+			if len(lines) > 0 && lines[len(lines)-1] == "" {
+				lines = lines[:len(lines)-1]
+			}
+			lines = append(lines, sysctlAssignment(key, values[key]), "")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SetForwarding backs up ufw's sysctl.conf, writes the changed forwarding keys to it and reloads ufw
+// to apply them. The output starts with that of the reload, so that a failed one reads as an error.
+func SetForwarding(forwarding Forwarding) string {
+	content, err := os.ReadFile(sysctlPath)
+	if err != nil {
+		return fmt.Sprintf("Error: reading %s: %s", sysctlPath, err)
+	}
+	updated := SetForwardingInConf(string(content), forwarding)
+	if updated == string(content) {
+		return oscmd.RunCommand("sudo ufw reload")
+	}
+
+	if err := os.MkdirAll(sysctlBackupDir, 0755); err != nil {
+		return fmt.Sprintf("Error: creating %s: %s", sysctlBackupDir, err)
+	}
+	backupPath := filepath.Join(sysctlBackupDir, time.Now().Format("2006-01-02_15-04-05")+".conf")
+	if err := os.WriteFile(backupPath, content, 0644); err != nil {
+		return fmt.Sprintf("Error: backing up sysctl.conf: %s", err)
+	}
+	if err := os.WriteFile(sysctlPath, []byte(updated), 0644); err != nil {
+		return fmt.Sprintf("Error: writing %s: %s", sysctlPath, err)
+	}
+	return oscmd.RunCommand("sudo ufw reload") + fmt.Sprintf("sysctl.conf backed up to %s\n", backupPath)
+}
+
+func sysctlAssignment(key string, on bool) string {
+	if on {
+		return key + "=1"
+	}
+	return key + "=0"
+}
+
+func parseSysctlLine(line string) (key, value string, ok bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+		return "", "", false
+	}
+	key, value, ok = strings.Cut(line, "=")
+	if !ok {
+		return "", "", false
+	}
+	return normalizeSysctlKey(key), strings.TrimSpace(value), true
+}
+
+// sysctlLineKey returns the key of an assignment, looking through a leading comment marker.
+func sysctlLineKey(line string) (string, bool) {
+	line = strings.TrimLeft(strings.TrimSpace(line), "#; ")
+	key, _, ok := strings.Cut(line, "=")
+	if !ok || strings.ContainsAny(strings.TrimSpace(key), " \t") {
+		return "", false
+	}
+	return normalizeSysctlKey(key), true
+}
+
+func normalizeSysctlKey(key string) string {
+	return strings.ReplaceAll(strings.TrimSpace(key), ".", "/")
+}
@@ -0,0 +1,58 @@
+package ufw
+
+import "testing"
+
+func TestSetForwardingInConf(t *testing.T) {
+	conf := `# Uncomment this to allow this host to route packets between interfaces
+#net/ipv4/ip_forward=1
+#net/ipv6/conf/default/forwarding=1
+#net/ipv6/conf/all/forwarding=1
+net.ipv4.icmp_echo_ignore_broadcasts=1
+`
+	for _, tc := range []struct {
+		name       string
+		content    string
+		forwarding Forwarding
+		want       string
+	}{
+		{
+			name:       "a changed key takes the place of its commented out line",
+			content:    conf,
+			forwarding: Forwarding{V4: true},
+			want: `# Uncomment this to allow this host to route packets between interfaces
+net/ipv4/ip_forward=1
+#net/ipv6/conf/default/forwarding=1
+#net/ipv6/conf/all/forwarding=1
+net.ipv4.icmp_echo_ignore_broadcasts=1
+`,
+		},
+		{
+			name:       "nothing changes",
+			content:    conf,
+			forwarding: Forwarding{},
+			want:       conf,
+		},
+		{
+			name:       "a key that keeps its value keeps its lines",
+			content:    "net.ipv4.ip_forward = 1\nnet/ipv6/conf/all/forwarding=0\nnet/ipv6/conf/all/forwarding=0\n",
+			forwarding: Forwarding{V4: true, V6: true},
+			want:       "net.ipv4.ip_forward = 1\nnet/ipv6/conf/all/forwarding=1\n",
+		},
+		{
+			name:       "a missing key is appended",
+			content:    "net/ipv4/ip_forward=1\n",
+			forwarding: Forwarding{V4: true, V6: true},
+			want:       "net/ipv4/ip_forward=1\nnet/ipv6/conf/all/forwarding=1\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := SetForwardingInConf(tc.content, tc.forwarding)
+			if got != tc.want {
+				t.Errorf("SetForwardingInConf =\n%s\nwant\n%s", got, tc.want)
+			}
+			if parsed := ParseForwarding(got); parsed != tc.forwarding {
+				t.Errorf("forwarding read back = %+v, want %+v", parsed, tc.forwarding)
+			}
+		})
+	}
+}
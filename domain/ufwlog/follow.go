@@ -0,0 +1,142 @@
+package ufwlog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// how far a search for the start of a time window steps back through the log file at once
+const searchChunk = 64 * 1024
+
+// Follower reads the ufw log piece by piece for a poller that keeps the entries itself: the first
+// read goes back to a point in time, every later one returns only what was logged since. The zero
+// value is ready to use.
+type Follower struct {
+	started bool
+	offset  int64  // end of the last complete line read from the log file
+	cursor  string // kernel journal cursor, when the log file doesn't exist
+	source  string
+}
+
+// Source names what the last read read, the log file or JournalSource.
+func (f *Follower) Source() string {
+	return f.source
+}
+
+// Read returns the entries logged since the previous read, or from since on for the first read and
+// after the log file was rotated. It reads at most about limit entries, a zero since reads the last
+// ones.
+func (f *Follower) Read(since time.Time, limit int) ([]Entry, error) {
+	file, err := os.Open(logPath)
+	if os.IsNotExist(err) {
+		f.source = JournalSource
+		return f.readJournal(since, limit)
+	}
+	f.source = logPath
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", logPath, err)
+	}
+	defer file.Close()
+
+	entries, err := f.readFile(file, since, limit, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", logPath, err)
+	}
+	return entries, nil
+}
+
+func (f *Follower) readJournal(since time.Time, limit int) ([]Entry, error) {
+	if f.started && f.cursor != "" {
+		entries, next, err := ReadJournalAfter(f.cursor, limit)
+		if err == nil {
+			f.cursor = next
+		}
+		return entries, err
+	}
+
+	command := fmt.Sprintf("journalctl -k --no-pager -o short-iso -g 'UFW ' --show-cursor -n %d", limit)
+	if !since.IsZero() {
+		command += fmt.Sprintf(" --since '@%d'", since.Unix())
+	}
+	entries, next, err := readJournal(command, "")
+	if err != nil {
+		return nil, err
+	}
+	f.started, f.cursor = true, next
+	return entries, nil
+}
+
+func (f *Follower) readFile(file *os.File, since time.Time, limit int, now time.Time) ([]Entry, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	floor := max(size-int64(limit*bytesPerEntry), 0)
+
+	start := f.offset
+	if !f.started || size < f.offset {
+		// a file shorter than what was read before is a new one after a rotation
+		if start, err = windowStart(file, size, floor, since, now); err != nil {
+			return nil, err
+		}
+	}
+	start = max(start, floor)
+
+	// one byte before the start tells whether it cuts a line in half
+	from := max(start-1, 0)
+	content := make([]byte, size-from)
+	if _, err := file.ReadAt(content, from); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if start > 0 {
+		if content[0] != '\n' {
+			cut := bytes.IndexByte(content, '\n')
+			if cut < 0 {
+				content = nil
+			} else {
+				content = content[cut:]
+			}
+		}
+		if len(content) > 0 {
+			content = content[1:]
+		}
+	}
+
+	// a line still being written is read once it is complete
+	complete := bytes.LastIndexByte(content, '\n') + 1
+	f.started, f.offset = true, size-int64(len(content)-complete)
+
+	var entries []Entry
+	for _, line := range strings.Split(string(content[:complete]), "\n") {
+		if entry, ok := Parse(line, now); ok && !entry.Time.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// windowStart steps back from the end of the file until it finds a line logged before since and
+// returns an offset in front of it, or floor when the window reaches further back.
+func windowStart(file *os.File, size, floor int64, since time.Time, now time.Time) (int64, error) {
+	for end := size; end > floor; {
+		start := max(end-searchChunk, floor)
+		chunk := make([]byte, end-start)
+		if _, err := file.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return 0, err
+		}
+		// the first line of the chunk may be cut, the second one is whole
+		if first := bytes.IndexByte(chunk, '\n'); first >= 0 {
+			line, _, _ := bytes.Cut(chunk[first+1:], []byte("\n"))
+			if entry, ok := Parse(string(line), now); ok && entry.Time.Before(since) {
+				return start, nil
+			}
+		}
+		end = start
+	}
+	return floor, nil
+}
@@ -0,0 +1,103 @@
+package ufwlog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+// logLine is a ufw log line written minutesAgo before testNow, told apart by its source port.
+func logLine(minutesAgo, port int) string {
+	return fmt.Sprintf("%s host kernel: [UFW BLOCK] IN=eth0 OUT= SRC=203.0.113.9 DST=10.0.0.2 PROTO=TCP SPT=%d DPT=22\n",
+		testNow.Add(-time.Duration(minutesAgo)*time.Minute).Format(time.RFC3339), port)
+}
+
+func appendLog(t *testing.T, path, content string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func followFile(t *testing.T, f *Follower, path string, since time.Time) []int {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	entries, err := f.readFile(file, since, 1000, testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ports []int
+	for _, e := range entries {
+		ports = append(ports, e.SrcPort)
+	}
+	return ports
+}
+
+func TestFollowerReadsOnlyNewLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ufw.log")
+	var old strings.Builder
+	for i := 0; i < 3000; i++ {
+		old.WriteString(logLine(60, 40000))
+	}
+	appendLog(t, path, old.String()+logLine(10, 1)+logLine(4, 2)+logLine(1, 3))
+
+	var f Follower
+	since := testNow.Add(-5 * time.Minute)
+	if got := followFile(t, &f, path, since); fmt.Sprint(got) != "[2 3]" {
+		t.Errorf("first read = %v, want the entries of the window [2 3]", got)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	info, _ := file.Stat()
+	if start, err := windowStart(file, info.Size(), 0, since, testNow); err != nil || start == 0 {
+		t.Errorf("windowStart = %d, %v, want it to stop short of the start of the file", start, err)
+	}
+
+	if got := followFile(t, &f, path, since); len(got) != 0 {
+		t.Errorf("read without new lines = %v, want none", got)
+	}
+
+	// a line is only read once it is complete
+	line := logLine(0, 4)
+	appendLog(t, path, line[:20])
+	if got := followFile(t, &f, path, since); len(got) != 0 {
+		t.Errorf("read of a half written line = %v, want none", got)
+	}
+	appendLog(t, path, line[20:]+logLine(0, 5))
+	if got := followFile(t, &f, path, since); fmt.Sprint(got) != "[4 5]" {
+		t.Errorf("read after appending = %v, want [4 5]", got)
+	}
+}
+
+func TestFollowerStartsOverAfterRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ufw.log")
+	appendLog(t, path, logLine(2, 1)+logLine(1, 2)+logLine(1, 3))
+
+	var f Follower
+	since := testNow.Add(-5 * time.Minute)
+	followFile(t, &f, path, since)
+
+	if err := os.WriteFile(path, []byte(logLine(0, 4)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := followFile(t, &f, path, since); fmt.Sprint(got) != "[4]" {
+		t.Errorf("read after rotation = %v, want [4]", got)
+	}
+}
@@ -0,0 +1,102 @@
+package ufwlog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Count struct {
+	Key   string
+	Count int
+}
+
+type Stats struct {
+	Since      time.Time
+	Blocked    int
+	Sources    []Count
+	Ports      []Count // keyed "port/proto"
+	Protocols  []Count
+	Buckets    []int // blocked entries per bucket, oldest first
+	BucketSize time.Duration
+}
+
+// IsBlocked tells whether the entry logs a dropped packet, including the ones over a limit rule.
+func (e Entry) IsBlocked() bool {
+	return strings.Contains(e.Action, "BLOCK")
+}
+
+// Aggregate counts the blocked entries within the window before now and splits them into at most
+// maxBuckets buckets of whole minutes.
+func Aggregate(entries []Entry, window time.Duration, now time.Time, top int, maxBuckets int) Stats {
+	bucketSize := time.Minute
+	if minutes := int(window / time.Minute); minutes > maxBuckets {
+		bucketSize = time.Duration((minutes+maxBuckets-1)/maxBuckets) * time.Minute
+	}
+	bucketCount := max(int(window/bucketSize), 1)
+
+	stats := Stats{
+		Since:      now.Add(-window),
+		Buckets:    make([]int, bucketCount),
+		BucketSize: bucketSize,
+	}
+
+	sources := map[string]int{}
+	ports := map[string]int{}
+	protocols := map[string]int{}
+	for _, e := range entries {
+		if !e.IsBlocked() || e.Time.Before(stats.Since) || e.Time.After(now) {
+			continue
+		}
+		stats.Blocked++
+		sources[e.Src]++
+		protocols[e.Proto]++
+		if e.DstPort != 0 {
+			ports[fmt.Sprintf("%d/%s", e.DstPort, strings.ToLower(e.Proto))]++
+		}
+		bucket := min(int(e.Time.Sub(stats.Since)/bucketSize), bucketCount-1)
+		stats.Buckets[bucket]++
+	}
+
+	stats.Sources = topCounts(sources, top)
+	stats.Ports = topCounts(ports, top)
+	stats.Protocols = topCounts(protocols, top)
+	return stats
+}
+
+func topCounts(counts map[string]int, top int) []Count {
+	result := make([]Count, 0, len(counts))
+	for key, count := range counts {
+		result = append(result, Count{Key: key, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+	if len(result) > top {
+		result = result[:top]
+	}
+	return result
+}
+
+var sparkLevels = []rune(" ▁▂▃▄▅▆▇█")
+
+// Sparkline draws the values scaled to the largest one.
+func Sparkline(values []int) string {
+	highest := 0
+	for _, v := range values {
+		highest = max(highest, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		level := 0
+		if highest > 0 {
+			level = (v*(len(sparkLevels)-1) + highest - 1) / highest
+		}
+		b.WriteRune(sparkLevels[level])
+	}
+	return b.String()
+}
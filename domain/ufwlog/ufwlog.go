@@ -0,0 +1,231 @@
+package ufwlog
+
+import (
+	"fmt"
+	"fwtui/utils/oscmd"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const logPath = "/var/log/ufw.log"
+
+// how much of the end of the log file is read per requested entry
+const bytesPerEntry = 512
+
+type Entry struct {
+	Time    time.Time
+	Action  string // BLOCK, ALLOW, AUDIT or LIMIT BLOCK
+	In      string
+	Out     string
+	Src     string
+	Dst     string
+	Proto   string
+	SrcPort int
+	DstPort int
+	Raw     string
+}
+
+// IsIncoming tells whether the packet came in from the network rather than being sent by this host.
+func (e Entry) IsIncoming() bool {
+	return e.In != ""
+}
+
+// IsRouted tells whether the packet was forwarded through this host, it names both interfaces.
+func (e Entry) IsRouted() bool {
+	return e.In != "" && e.Out != ""
+}
+
+// Field returns a field by its log name, e.g. SRC or DPT, or "action".
+func (e Entry) Field(name string) string {
+	switch strings.ToUpper(name) {
+	case "ACTION":
+		return e.Action
+	case "IN":
+		return e.In
+	case "OUT":
+		return e.Out
+	case "SRC":
+		return e.Src
+	case "DST":
+		return e.Dst
+	case "PROTO":
+		return e.Proto
+	case "SPT":
+		return portString(e.SrcPort)
+	case "DPT":
+		return portString(e.DstPort)
+	}
+	return ""
+}
+
+func portString(port int) string {
+	if port == 0 {
+		return ""
+	}
+	return strconv.Itoa(port)
+}
+
+// ReadRecent returns the ufw log entries from the end of /var/log/ufw.log, or from the kernel journal
+// when the file doesn't exist.
+func ReadRecent(limit int) ([]Entry, string, error) {
+	lines, source, err := readLines(limit)
+	if err != nil {
+		return nil, source, err
+	}
+
+	var entries []Entry
+	for _, line := range lines {
+		if entry, ok := Parse(line, time.Now()); ok {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, source, nil
+}
+
+// JournalSource is the source ReadRecent and Follower report when they read the kernel journal.
+const JournalSource = "journal"
+
+// ReadJournalAfter returns the ufw entries the kernel journal got after the cursor and the cursor to
+// continue from, so that following the journal doesn't read the same entries on every poll. Without
+// a cursor it reads the last limit entries. The cursor stays the same when nothing was logged.
+func ReadJournalAfter(cursor string, limit int) ([]Entry, string, error) {
+	command := fmt.Sprintf("journalctl -k --no-pager -o short-iso -g 'UFW ' --show-cursor -n %d", limit)
+	if cursor != "" {
+		command += fmt.Sprintf(" --after-cursor '%s'", strings.ReplaceAll(cursor, "'", ""))
+	}
+	return readJournal(command, cursor)
+}
+
+// readJournal runs a journalctl command with --show-cursor and returns the entries and the cursor it
+// reports, or the given cursor when nothing new was logged.
+func readJournal(command, cursor string) ([]Entry, string, error) {
+	output := oscmd.RunCommand(command)
+	if strings.HasPrefix(output, "Error:") {
+		// journalctl exits with 1 when the pattern matches nothing new
+		if !strings.Contains(output, "-- No entries --") {
+			return nil, cursor, fmt.Errorf("reading the kernel journal: %s", output)
+		}
+	}
+
+	var entries []Entry
+	next := cursor
+	for _, line := range strings.Split(output, "\n") {
+		if c, found := strings.CutPrefix(line, "-- cursor: "); found {
+			next = strings.TrimSpace(c)
+			continue
+		}
+		if entry, ok := Parse(line, time.Now()); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, next, nil
+}
+
+func readLines(limit int) ([]string, string, error) {
+	file, err := os.Open(logPath)
+	if os.IsNotExist(err) {
+		output := oscmd.RunCommand(fmt.Sprintf("journalctl -k --no-pager -o short-iso -g 'UFW ' -n %d", limit))
+		if strings.HasPrefix(output, "Error:") {
+			return nil, JournalSource, fmt.Errorf("reading the kernel journal: %s", output)
+		}
+		return strings.Split(output, "\n"), JournalSource, nil
+	}
+	if err != nil {
+		return nil, logPath, fmt.Errorf("opening %s: %w", logPath, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, logPath, fmt.Errorf("reading %s: %w", logPath, err)
+	}
+	offset := max(info.Size()-int64(limit*bytesPerEntry), 0)
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, logPath, fmt.Errorf("reading %s: %w", logPath, err)
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, logPath, fmt.Errorf("reading %s: %w", logPath, err)
+	}
+
+	lines := strings.Split(string(content), "\n")
+	if offset > 0 {
+		// the first line was cut in half by the seek
+		lines = lines[1:]
+	}
+	return lines, logPath, nil
+}
+
+// Parse reads a kernel log line written by ufw, e.g.
+// "Oct 18 10:01:02 host kernel: [UFW BLOCK] IN=eth0 OUT= SRC=203.0.113.9 DST=10.0.0.2 PROTO=TCP SPT=51000 DPT=22".
+// now supplies the year for syslog timestamps, which don't have one.
+func Parse(line string, now time.Time) (Entry, bool) {
+	start := strings.Index(line, "[UFW ")
+	if start < 0 {
+		return Entry{}, false
+	}
+	end := strings.Index(line[start:], "]")
+	if end < 0 {
+		return Entry{}, false
+	}
+
+	entry := Entry{
+		Time:   parseTime(line[:start], now),
+		Action: line[start+len("[UFW ") : start+end],
+		Raw:    line,
+	}
+
+	for _, field := range strings.Fields(line[start+end+1:]) {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "IN":
+			entry.In = value
+		case "OUT":
+			entry.Out = value
+		case "SRC":
+			entry.Src = value
+		case "DST":
+			entry.Dst = value
+		case "PROTO":
+			entry.Proto = value
+		case "SPT":
+			entry.SrcPort, _ = strconv.Atoi(value)
+		case "DPT":
+			entry.DstPort, _ = strconv.Atoi(value)
+		}
+	}
+	return entry, true
+}
+
+func parseTime(prefix string, now time.Time) time.Time {
+	fields := strings.Fields(prefix)
+	if len(fields) == 0 {
+		return time.Time{}
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05-0700"} {
+		if t, err := time.Parse(layout, fields[0]); err == nil {
+			return t
+		}
+	}
+	if len(fields) >= 3 {
+		t, err := time.ParseInLocation("Jan 2 15:04:05", strings.Join(fields[:3], " "), time.Local)
+		if err == nil {
+			t = t.AddDate(now.Year(), 0, 0)
+			// a December entry read in January belongs to the previous year
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,100 @@
+package ufwlog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		line   string
+		want   Entry
+		routed bool
+	}{
+		{
+			name: "kernel log",
+			line: "Oct 18 10:01:02 host kernel: [12345.678901] [UFW BLOCK] IN=eth0 OUT= MAC=00:16:3e:00:00:01 SRC=203.0.113.9 DST=10.0.0.2 LEN=60 TOS=0x00 PREC=0x00 TTL=52 ID=4242 DF PROTO=TCP SPT=51000 DPT=22 WINDOW=64240 RES=0x00 SYN URGP=0",
+			want: Entry{Time: time.Date(2026, 10, 18, 10, 1, 2, 0, time.Local), Action: "BLOCK", In: "eth0", Src: "203.0.113.9", Dst: "10.0.0.2", Proto: "TCP", SrcPort: 51000, DstPort: 22},
+		},
+		{
+			name: "journal short-iso",
+			line: "2026-10-18T10:01:02+0000 host kernel: [UFW ALLOW] IN= OUT=eth0 SRC=10.0.0.2 DST=198.51.100.53 LEN=73 TOS=0x00 PREC=0x00 TTL=64 ID=0 DF PROTO=UDP SPT=40000 DPT=53 LEN=53",
+			want: Entry{Time: time.Date(2026, 10, 18, 10, 1, 2, 0, time.UTC), Action: "ALLOW", Out: "eth0", Src: "10.0.0.2", Dst: "198.51.100.53", Proto: "UDP", SrcPort: 40000, DstPort: 53},
+		},
+		{
+			name: "rsyslog RFC 3339",
+			line: "2026-10-18T10:01:02.123456+02:00 host kernel: [UFW LIMIT BLOCK] IN=eth0 OUT= SRC=203.0.113.9 DST=10.0.0.2 PROTO=TCP SPT=51000 DPT=22",
+			want: Entry{Time: time.Date(2026, 10, 18, 8, 1, 2, 123456000, time.UTC), Action: "LIMIT BLOCK", In: "eth0", Src: "203.0.113.9", Dst: "10.0.0.2", Proto: "TCP", SrcPort: 51000, DstPort: 22},
+		},
+		{
+			name: "IPv6",
+			line: "Oct 18 10:01:02 host kernel: [UFW BLOCK] IN=eth0 OUT= MAC=33:33:00:00:00:01 SRC=2001:0db8:0000:0000:0000:0000:0000:0007 DST=2001:0db8:0000:0000:0000:0000:0000:0001 LEN=72 TC=0 HOPLIMIT=64 FLOWLBL=0 PROTO=TCP SPT=51000 DPT=443 WINDOW=64800 RES=0x00 SYN URGP=0",
+			want: Entry{Time: time.Date(2026, 10, 18, 10, 1, 2, 0, time.Local), Action: "BLOCK", In: "eth0", Src: "2001:0db8:0000:0000:0000:0000:0000:0007", Dst: "2001:0db8:0000:0000:0000:0000:0000:0001", Proto: "TCP", SrcPort: 51000, DstPort: 443},
+		},
+		{
+			name:   "routed",
+			line:   "Oct 18 10:01:02 host kernel: [UFW BLOCK] IN=wg0 OUT=eth0 MAC= SRC=10.8.0.2 DST=192.0.2.80 LEN=60 TOS=0x00 PREC=0x00 TTL=63 ID=0 DF PROTO=TCP SPT=41000 DPT=80 WINDOW=64240 RES=0x00 SYN URGP=0",
+			want:   Entry{Time: time.Date(2026, 10, 18, 10, 1, 2, 0, time.Local), Action: "BLOCK", In: "wg0", Out: "eth0", Src: "10.8.0.2", Dst: "192.0.2.80", Proto: "TCP", SrcPort: 41000, DstPort: 80},
+			routed: true,
+		},
+		{
+			name: "ICMP has no ports",
+			line: "Oct 18 10:01:02 host kernel: [UFW AUDIT] IN=eth0 OUT= SRC=203.0.113.9 DST=10.0.0.2 PROTO=ICMP TYPE=8 CODE=0 ID=1 SEQ=1",
+			want: Entry{Time: time.Date(2026, 10, 18, 10, 1, 2, 0, time.Local), Action: "AUDIT", In: "eth0", Src: "203.0.113.9", Dst: "10.0.0.2", Proto: "ICMP"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := Parse(tc.line, testNow)
+			if !ok {
+				t.Fatal("Parse didn't recognize the line")
+			}
+			tc.want.Raw = tc.line
+			if !got.Time.Equal(tc.want.Time) {
+				t.Errorf("time = %v, want %v", got.Time, tc.want.Time)
+			}
+			got.Time, tc.want.Time = time.Time{}, time.Time{}
+			if got != tc.want {
+				t.Errorf("entry = %+v, want %+v", got, tc.want)
+			}
+			if got.IsRouted() != tc.routed {
+				t.Errorf("IsRouted = %v, want %v", got.IsRouted(), tc.routed)
+			}
+		})
+	}
+}
+
+func TestParseSkipsOtherLines(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"Oct 18 10:01:02 host kernel: [12345.678901] eth0: link up",
+		"Oct 18 10:01:02 host kernel: [UFW BLOCK IN=eth0",
+	} {
+		if entry, ok := Parse(line, testNow); ok {
+			t.Errorf("Parse(%q) = %+v, want no entry", line, entry)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	january := time.Date(2027, 1, 2, 0, 30, 0, 0, time.Local)
+	for _, tc := range []struct {
+		prefix string
+		now    time.Time
+		want   time.Time
+	}{
+		{"Oct 18 10:01:02 host kernel: ", testNow, time.Date(2026, 10, 18, 10, 1, 2, 0, time.Local)},
+		{"Oct  8 10:01:02 host kernel: ", testNow, time.Date(2026, 10, 8, 10, 1, 2, 0, time.Local)},
+		// syslog timestamps have no year, a December entry read in January is from the year before
+		{"Dec 31 23:59:59 host kernel: ", january, time.Date(2026, 12, 31, 23, 59, 59, 0, time.Local)},
+		{"Jan  2 00:29:00 host kernel: ", january, time.Date(2027, 1, 2, 0, 29, 0, 0, time.Local)},
+		{"2026-10-18T10:01:02+0200 host kernel: ", testNow, time.Date(2026, 10, 18, 8, 1, 2, 0, time.UTC)},
+		{"2026-10-18T10:01:02Z host kernel: ", testNow, time.Date(2026, 10, 18, 10, 1, 2, 0, time.UTC)},
+		{"", testNow, time.Time{}},
+		{"host kernel: ", testNow, time.Time{}},
+	} {
+		if got := parseTime(tc.prefix, tc.now); !got.Equal(tc.want) {
+			t.Errorf("parseTime(%q) = %v, want %v", tc.prefix, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// Package exporter serves the firewall state as Prometheus metrics. Every scrape reads the status and
+// the rule counters afresh, the ufw log is followed between scrapes so that a scrape only reads what
+// was logged since the one before.
+package exporter
+
+import (
+	"fmt"
+	"fwtui/domain/ufw"
+	"fwtui/domain/ufwlog"
+	"fwtui/modules/defaultpolicies"
+	"fwtui/modules/shared/state"
+	"fwtui/utils/result"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how many log entries a scrape reads at most, like the log analytics view
+const maxLogEntries = 50000
+
+var policies = []string{"allow", "deny", "reject", "disabled"}
+
+var ruleActions = []string{"allow", "deny", "reject", "limit"}
+
+var ruleDirections = []string{"in", "out", "routed"}
+
+// Handler answers scrapes with the metrics, the blocked log rate is averaged over window. The log
+// entries of the window are kept between scrapes.
+func Handler(window time.Duration) http.Handler {
+	var mu sync.Mutex
+	var follower ufwlog.Follower
+	var recent []ufwlog.Entry
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		now := time.Now()
+		entries, logErr := follower.Read(now.Add(-window), maxLogEntries)
+		recent = keepSince(append(recent, entries...), now.Add(-window))
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		fmt.Fprint(w, Metrics(load(), recent, logErr, window, now))
+	})
+}
+
+// load reads the part of the state the metrics need: the status, the rules and their counters. The
+// forwarding, IPv6 and profile settings a full state.Load reads are left out.
+func load() state.State {
+	status := ufw.StatusVerbose()
+	enabled, loggingLevel := ufw.ParseStatusFlags(status)
+	counters := result.Ok(map[int]ufw.Counter{})
+	if enabled {
+		if c, err := ufw.ReadRuleCounters(); err != nil {
+			counters = result.Err[map[int]ufw.Counter](err)
+		} else {
+			counters = result.Ok(c)
+		}
+	}
+	return state.State{
+		Status:       status,
+		Enabled:      enabled,
+		LoggingLevel: loggingLevel,
+		Defaults:     defaultpolicies.ParseUfwDefaults(status),
+		Rules:        ufw.LoadRules(status),
+		Counters:     counters,
+	}
+}
+
+// keepSince drops the entries logged before since, the entries are in the order they were logged.
+func keepSince(entries []ufwlog.Entry, since time.Time) []ufwlog.Entry {
+	first := sort.Search(len(entries), func(i int) bool { return !entries[i].Time.Before(since) })
+	return append([]ufwlog.Entry(nil), entries[first:]...)
+}
+
+// Metrics writes the state in the Prometheus text format.
+func Metrics(s state.State, entries []ufwlog.Entry, logErr error, window time.Duration, now time.Time) string {
+	var m metrics
+	statusErr := strings.HasPrefix(s.Status, "Error:")
+
+	m.family("fwtui_scrape_error", "gauge", "Whether reading a source of the metrics failed.")
+	m.sample("fwtui_scrape_error", []string{"source", "status"}, boolValue(statusErr))
+	m.sample("fwtui_scrape_error", []string{"source", "counters"}, boolValue(s.Counters.IsErr()))
+	m.sample("fwtui_scrape_error", []string{"source", "log"}, boolValue(logErr != nil))
+	if statusErr {
+		// without the status every other metric would report a disabled, empty firewall
+		return m.String()
+	}
+
+	m.family("fwtui_ufw_enabled", "gauge", "Whether ufw is active.")
+	m.sample("fwtui_ufw_enabled", nil, boolValue(s.Enabled))
+
+	m.family("fwtui_ufw_logging_level", "gauge", "The ufw logging level, 1 for the current one.")
+	for _, level := range ufw.LoggingLevels {
+		m.sample("fwtui_ufw_logging_level", []string{"level", level}, boolValue(level == s.LoggingLevel))
+	}
+
+	if s.Defaults.IsOk() {
+		defaults := s.Defaults.Value()
+		m.family("fwtui_ufw_default_policy", "gauge", "The default policy per direction, 1 for the current one.")
+		for _, direction := range []struct{ name, policy string }{
+			{"incoming", defaults.Incoming},
+			{"outgoing", defaults.Outgoing},
+			{"routed", defaults.Routed},
+		} {
+			for _, policy := range policies {
+				m.sample("fwtui_ufw_default_policy", []string{"direction", direction.name, "policy", policy}, boolValue(policy == direction.policy))
+			}
+		}
+	}
+
+	m.family("fwtui_ufw_rules", "gauge", "Number of rules by action and direction, routed rules count as routed.")
+	counts := map[[2]string]int{}
+	for _, rule := range s.Rules {
+		counts[[2]string{ruleAction(rule), ruleDirection(rule)}]++
+	}
+	for _, action := range ruleActions {
+		for _, direction := range ruleDirections {
+			m.sample("fwtui_ufw_rules", []string{"action", action, "direction", direction}, float64(counts[[2]string{action, direction}]))
+			delete(counts, [2]string{action, direction})
+		}
+	}
+	// rules read from the status lines alone have no action
+	keys := make([][2]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i][0]+keys[i][1] < keys[j][0]+keys[j][1] })
+	for _, key := range keys {
+		m.sample("fwtui_ufw_rules", []string{"action", key[0], "direction", key[1]}, float64(counts[key]))
+	}
+
+	if s.Counters.IsOk() && len(s.Counters.Value()) > 0 {
+		series := ruleSeries(s.Rules, s.Counters.Value())
+		m.family("fwtui_ufw_rule_packets_total", "counter", "Packets matched by a rule since boot or the last counter reset.")
+		for _, rs := range series {
+			m.sample("fwtui_ufw_rule_packets_total", rs.labels, float64(rs.counter.Packets))
+		}
+		m.family("fwtui_ufw_rule_bytes_total", "counter", "Bytes matched by a rule since boot or the last counter reset.")
+		for _, rs := range series {
+			m.sample("fwtui_ufw_rule_bytes_total", rs.labels, float64(rs.counter.Bytes))
+		}
+	}
+
+	if logErr == nil {
+		stats := ufwlog.Aggregate(entries, window, now, 0, 1)
+		m.family("fwtui_ufw_log_blocked_per_minute", "gauge", "Blocked packets logged by ufw per minute, averaged over the window.")
+		m.sample("fwtui_ufw_log_blocked_per_minute", []string{"window", window.String()}, float64(stats.Blocked)/window.Minutes())
+	}
+	return m.String()
+}
+
+func ruleAction(rule ufw.Rule) string {
+	if rule.Action == "" {
+		return "unknown"
+	}
+	return rule.Action
+}
+
+func ruleDirection(rule ufw.Rule) string {
+	switch {
+	case rule.Route:
+		return "routed"
+	case rule.Direction == "":
+		return "unknown"
+	default:
+		return rule.Direction
+	}
+}
+
+type ruleCounter struct {
+	labels  []string
+	counter ufw.Counter
+}
+
+// ruleSeries adds up the counters of the rules by their labels, in the order of the rules. Rules
+// that only differ in what the labels leave out would otherwise be reported twice.
+func ruleSeries(rules []ufw.Rule, counters map[int]ufw.Counter) []ruleCounter {
+	var series []ruleCounter
+	index := map[string]int{}
+	for _, rule := range rules {
+		labels := ruleLabels(rule)
+		key := strings.Join(labels, "\x00")
+		i, ok := index[key]
+		if !ok {
+			i = len(series)
+			index[key] = i
+			series = append(series, ruleCounter{labels: labels})
+		}
+		series[i].counter.Packets += counters[rule.Number].Packets
+		series[i].counter.Bytes += counters[rule.Number].Bytes
+	}
+	return series
+}
+
+// ruleLabels name a rule by what it matches rather than by its number, which shifts when rules are
+// inserted or deleted and would start a new series for every rule behind the change.
+func ruleLabels(rule ufw.Rule) []string {
+	labels := []string{
+		"rule", rule.Summary(),
+		"action", ruleAction(rule),
+		"direction", ruleDirection(rule),
+		"family", "v4",
+	}
+	if rule.V6 {
+		labels[len(labels)-1] = "v6"
+	}
+	if rule.Comment != "" {
+		labels = append(labels, "comment", rule.Comment)
+	}
+	return labels
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// metrics writes the Prometheus text format.
+type metrics struct {
+	strings.Builder
+}
+
+func (m *metrics) family(name, kind, help string) {
+	fmt.Fprintf(m, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes one value, labels are name and value pairs.
+func (m *metrics) sample(name string, labels []string, value float64) {
+	m.WriteString(name)
+	if len(labels) > 0 {
+		pairs := make([]string, 0, len(labels)/2)
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], escapeLabel(labels[i+1])))
+		}
+		m.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	m.WriteString(" " + strconv.FormatFloat(value, 'g', -1, 64) + "\n")
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,46 @@
+package exporter
+
+import (
+	"fwtui/domain/ufw"
+	"fwtui/modules/defaultpolicies"
+	"fwtui/modules/shared/state"
+	"fwtui/utils/result"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRuleCountersAreLabeledByWhatTheyMatch(t *testing.T) {
+	ssh := ufw.Rule{Number: 1, Action: "allow", Direction: "in", Protocol: "tcp", Source: "any", Dest: "any", SrcPort: "any", DestPort: "22", Comment: "ssh"}
+	logged := ssh
+	logged.Number, logged.Log = 2, "log"
+	web := ufw.Rule{Number: 3, Action: "allow", Direction: "in", Protocol: "tcp", Source: "any", Dest: "any", SrcPort: "any", DestPort: "443", V6: true}
+	status := "Status: active\nLogging: on (low)\nDefault: deny (incoming), allow (outgoing), disabled (routed)\n"
+	s := state.State{
+		Status:   status,
+		Defaults: defaultpolicies.ParseUfwDefaults(status),
+		Rules:    []ufw.Rule{ssh, logged, web},
+		Counters: result.Ok(map[int]ufw.Counter{
+			1: {Packets: 10, Bytes: 600},
+			2: {Packets: 5, Bytes: 300},
+			3: {Packets: 7, Bytes: 420},
+		}),
+	}
+
+	out := Metrics(s, nil, nil, 5*time.Minute, time.Now())
+	if strings.Contains(out, "number=") {
+		t.Errorf("counters still carry the rule number:\n%s", out)
+	}
+	for _, want := range []string{
+		// rules only told apart by logging share a series
+		`fwtui_ufw_rule_packets_total{rule="allow in from any to any port 22 proto tcp",action="allow",direction="in",family="v4",comment="ssh"} 15`,
+		`fwtui_ufw_rule_bytes_total{rule="allow in from any to any port 443 proto tcp (v6)",action="allow",direction="in",family="v6"} 420`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "fwtui_ufw_rule_packets_total{"); n != 2 {
+		t.Errorf("%d packet series, want 2", n)
+	}
+}
@@ -2,23 +2,33 @@ package main
 
 import (
 	"fmt"
+	"fwtui/cli"
+	"fwtui/domain/hostnames"
 	"fwtui/domain/notification"
 	"fwtui/domain/ufw"
+	"fwtui/modules/audit"
 	"fwtui/modules/createrule"
 	"fwtui/modules/defaultpolicies"
+	"fwtui/modules/groups"
+	"fwtui/modules/lint"
+	"fwtui/modules/listening"
+	"fwtui/modules/logging"
+	"fwtui/modules/logs"
+	"fwtui/modules/logstats"
+	"fwtui/modules/nat"
+	"fwtui/modules/playbooks"
 	"fwtui/modules/profiles"
+	"fwtui/modules/reresolve"
 	"fwtui/modules/shared/confirmation"
+	"fwtui/modules/shared/pager"
+	"fwtui/modules/shared/state"
+	"fwtui/modules/simulate"
+	"fwtui/modules/transfer"
 	"fwtui/utils/focusablelist"
 	"fwtui/utils/multiselect"
 	"fwtui/utils/teacmd"
-	"io/fs"
-	"log"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"sort"
 	"strings"
-	"syscall"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -26,29 +36,25 @@ import (
 )
 
 func main() {
-	if os.Geteuid() != 0 {
-		fmt.Println("This action requires root. Please run with sudo.")
-		os.Exit(1)
+	// the subcommands check for root and ufw themselves, some work on files alone
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[1:]))
 	}
-	cmd := exec.Command("sudo", "ufw", "status")
-	err := cmd.Run()
-	if err != nil {
-		log.Fatalf("ufw is not available or sudo failed: %v", err)
+	if err := cli.CheckHost(); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
 	}
 
 	backup()
 
-	profilesModule, _ := profiles.Init()
 	m := model{
-		menuList:       focusablelist.FromList(buildMenu()),
-		showOptions:    focusablelist.FromList([]string{showRaw, showAdded, showListening, showBuiltins}),
-		view:           viewStateHome,
-		profilesModule: profilesModule,
+		menuList:    focusablelist.FromList(buildMenu(state.State{})),
+		showOptions: focusablelist.FromList([]string{showRaw, showAdded, showListening, showBuiltins}),
+		view:        viewStateHome,
+		loading:     true,
 	}
-	m = m.reloadRules()
-	m = m.reloadStatus()
 	p := tea.NewProgram(m)
-	_, err = p.Run()
+	_, err := p.Run()
 	if err != nil {
 		fmt.Println("Error running program:", err)
 		os.Exit(1)
@@ -88,12 +94,77 @@ func (v viewHomeState) isShow() bool {
 	return v == viewShow
 }
 
+func (v viewHomeState) isShowReport() bool {
+	return v == viewShowReport
+}
+
+func (v viewHomeState) isListening() bool {
+	return v == viewListening
+}
+
+func (v viewHomeState) isAudit() bool {
+	return v == viewAudit
+}
+
+func (v viewHomeState) isLint() bool {
+	return v == viewLint
+}
+
+func (v viewHomeState) isSimulate() bool {
+	return v == viewSimulate
+}
+
+func (v viewHomeState) isLogs() bool {
+	return v == viewLogs
+}
+
+func (v viewHomeState) isLogStats() bool {
+	return v == viewLogStats
+}
+
+func (v viewHomeState) isLogging() bool {
+	return v == viewLogging
+}
+
+func (v viewHomeState) isNat() bool {
+	return v == viewNat
+}
+
+func (v viewHomeState) isReresolve() bool {
+	return v == viewReresolve
+}
+
+func (v viewHomeState) isGroups() bool {
+	return v == viewGroups
+}
+
+func (v viewHomeState) isPlaybooks() bool {
+	return v == viewPlaybooks
+}
+
+func (v viewHomeState) isTransfer() bool {
+	return v == viewTransfer
+}
+
 const viewStateHome = "view_state_home"
 const viewStateProfiles = "profiles"
 const viewStateCreateRule = "create_rule"
 const viewStateDeleteRule = "delete_rule"
 const viewSetDefault = "set_default"
 const viewShow = "show_menu"
+const viewShowReport = "show_report"
+const viewListening = "listening"
+const viewAudit = "audit"
+const viewLint = "lint"
+const viewSimulate = "simulate"
+const viewLogs = "logs"
+const viewLogStats = "log_stats"
+const viewLogging = "logging"
+const viewNat = "nat"
+const viewReresolve = "reresolve"
+const viewGroups = "groups"
+const viewPlaybooks = "playbooks"
+const viewTransfer = "transfer"
 
 // HOME MENU
 const menuResetUFW = "RESET_UFW"
@@ -102,11 +173,22 @@ const menuDisableUFW = "DISABLE"
 const menuEnableUFW = "ENABLE"
 const menuCreateRule = "CREATE_RULE"
 const menuDeleteRule = "DELETE_RULE"
-const menuDisableLogging = "DISABLE_LOGGING"
-const menuEnableLogging = "ENABLE_LOGGING"
+const menuLogging = "LOGGING"
+const menuNat = "NAT"
+const menuToggleIPv6 = "TOGGLE_IPV6"
+const menuReresolve = "RERESOLVE"
+const menuGroups = "GROUPS"
+const menuPlaybooks = "PLAYBOOKS"
+const menuTransfer = "TRANSFER"
 const menuSetDefault = "SET_DEFAULT"
 const menuProfiles = "PROFILES"
 const menuShow = "SHOW"
+const menuListening = "LISTENING"
+const menuAudit = "AUDIT"
+const menuLint = "LINT"
+const menuSimulate = "SIMULATE"
+const menuLogs = "LOGS"
+const menuLogStats = "LOG_STATS"
 
 // show menu
 const showRaw = "Raw"
@@ -114,37 +196,58 @@ const showAdded = "Added"
 const showListening = "Listening"
 const showBuiltins = "Builtins"
 
-type rule struct {
-	number int
-	line   string
-}
-
 type model struct {
 	menuList             *focusablelist.SelectableList[menuItem]
 	showOptions          *focusablelist.SelectableList[string]
-	resetDialog          *confirmation.ConfirmDialog
+	firewallDialog       *confirmation.ConfirmDialog // asks before a firewall-wide change
+	firewallCommand      func() string               // runs the change once confirmed
 	view                 viewHomeState
-	status               string
+	state                state.State
+	loaded               bool
+	loading              bool
 	notification         string
 	runningNotifications int
 	cmdIsRunning         bool
 
-	rules        multiselect.MultiSelectableList[rule]
+	rules        multiselect.MultiSelectableList[ufw.Rule]
+	findings     map[int][]ufw.Finding
+	twins        map[int]int
+	zeroHitsOnly bool
 	deleteDialog *confirmation.ConfirmDialog
+	twinDialog   *confirmation.ConfirmDialog
+	toDelete     []int
+
+	width  int
+	height int
 
 	ruleForm          createrule.RuleForm
 	profilesModule    profiles.ProfilesModule
 	setDefaultsModule defaultpolicies.DefaultModule
+	reportPager       pager.Pager
+	listeningModule   listening.ListeningModule
+	auditModule       audit.AuditModule
+	lintModule        lint.LintModule
+	simulateModule    simulate.SimulateModule
+	logsModule        logs.LogsModule
+	logStatsModule    logstats.LogStatsModule
+	loggingModule     logging.LoggingModule
+	natModule         nat.NatModule
+	reresolveModule   reresolve.ReresolveModule
+	groupsModule      groups.GroupsModule
+	playbooksModule   playbooks.PlaybooksModule
+	transferModule    transfer.TransferModule
 }
 
 func (m model) Init() tea.Cmd {
-	return nil
+	return state.LoadCmd()
 }
 
 // UPDATE
 
 type lastActionTimeUpMsg struct{}
 type rulesDeletedMsg struct{ Output string }
+type ufwCommandFinishedMsg struct{ Output string }
+type reportLoadedMsg struct{ Output string }
 
 func (mod model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	m := mod
@@ -178,23 +281,44 @@ func (mod model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case notification.NotificationReceivedMsg:
 		return m.setNotification(msg.Text)
 
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.reportPager = m.reportPager.SetSize(m.width, m.height)
+		return m, nil
+
+	case state.StateLoadedMsg:
+		m.state = msg.State
+		m.loaded = true
+		m.loading = false
+		m.menuList.SetItems(buildMenu(m.state))
+		m.twins = ufw.IPv6Twins(m.state.Rules)
+		m.rules.SetItems(m.visibleRules())
+		m.findings = ufw.FindingsByRule(ufw.Analyze(m.state.Rules, m.state.IPv6.WithDefault(true)))
+		if m.view.isProfiles() {
+			m.profilesModule = m.profilesModule.SetInstalled(m.state.Profiles.WithDefault(nil))
+		}
+		return m, nil
+
+	case ufwCommandFinishedMsg:
+		m, cmd := m.refresh()
+		return m, tea.Batch(cmd, teacmd.OsCmdExecutionFinishedCmd(msg.Output))
+
 	default:
 		switch true {
 		case m.view.isHome():
-			if m.resetDialog != nil {
-				newDeleteDialog, _, outMsg := m.resetDialog.UpdateDialog(msg)
-				m.resetDialog = newDeleteDialog
+			if m.firewallDialog != nil {
+				newDialog, _, outMsg := m.firewallDialog.UpdateDialog(msg)
+				m.firewallDialog = newDialog
 				switch outMsg {
 				case confirmation.ConfirmationDialogYes:
-					output := ufw.Reset()
-					m = m.resetMenu()
-					m = m.reloadStatus()
-					m = m.reloadRules()
-					return m.setNotification(output)
+					command := m.firewallCommand
+					m.firewallDialog, m.firewallCommand = nil, nil
+					return m, runUfwCommand(command)
 				case confirmation.ConfirmationDialogNo:
-					m.resetDialog = nil
+					m.firewallDialog, m.firewallCommand = nil, nil
 				case confirmation.ConfirmationDialogEsc:
-					m.resetDialog = nil
+					m.firewallDialog, m.firewallCommand = nil, nil
 				}
 
 				return m, nil
@@ -212,51 +336,98 @@ func (mod model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					selected := m.menuList.Focused().action
 					switch selected {
 					case menuResetUFW:
-						m.resetDialog = confirmation.NewConfirmDialog("Are you sure you want to reset UFW?")
+						m.firewallDialog = confirmation.NewConfirmDialog("Are you sure you want to reset UFW?")
+						m.firewallCommand = ufw.Reset
 					case menuDisableUFW:
-						ufw.Disable()
-						m = m.resetMenu()
 						m.menuList.FocusFirst()
+						return m, runUfwCommand(ufw.Disable)
 					case menuEnableUFW:
-						ufw.Enable()
-						m = m.resetMenu()
-					case menuEnableLogging:
-						ufw.EnableLogging()
-						m = m.resetMenu()
-					case menuDisableLogging:
-						ufw.DisableLogging()
-						m = m.resetMenu()
+						return m, runUfwCommand(ufw.Enable)
+					case menuToggleIPv6:
+						enable := !m.state.IPv6.Value()
+						m.firewallDialog = confirmation.NewConfirmDialog(fmt.Sprintf(
+							"%s IPv6? This rewrites IPV6= in /etc/default/ufw and reloads ufw.",
+							lo.Ternary(enable, "Enable", "Disable")))
+						m.firewallCommand = func() string {
+							return ufw.SetIPv6(enable)
+						}
+					case menuPlaybooks:
+						m.view = viewPlaybooks
+						m.playbooksModule = playbooks.Init(m.state)
+					case menuTransfer:
+						m.view = viewTransfer
+						m.transferModule = transfer.Init(m.state)
+					case menuGroups:
+						m.view = viewGroups
+						m.groupsModule = groups.Init(m.state.Rules)
+					case menuReresolve:
+						m.view = viewReresolve
+						newModule, cmd := reresolve.Init(m.state, hostnames.DefaultResolver)
+						m.reresolveModule = newModule
+						return m, cmd
+					case menuNat:
+						m.view = viewNat
+						m.natModule = nat.Init()
+					case menuLogging:
+						m.view = viewLogging
+						m.loggingModule = logging.Init(m.state.LoggingLevel)
 					case menuCreateRule:
 						m.ruleForm = createrule.NewRuleForm()
 						m.view = viewStateCreateRule
 					case menuDeleteRule:
 						m.view = viewStateDeleteRule
 					case menuSetDefault:
-						m.view = viewSetDefault
-						result := defaultpolicies.ParseUfwDefaults(m.status)
-
+						result := m.state.Defaults
 						if result.IsErr() {
 							return m.setNotification(result.Err().Error())
 						}
 
-						m.setDefaultsModule = defaultpolicies.Init(result.Value())
+						m.view = viewSetDefault
+						m.setDefaultsModule = defaultpolicies.Init(result.Value(), m.state.Forwarding)
 						return m, nil
 					case menuProfiles:
 						m.view = viewStateProfiles
+						m.profilesModule, _ = profiles.Init(m.state.Profiles.WithDefault(nil))
 					case menuShow:
 						m.view = viewShow
+					case menuListening:
+						m.view = viewListening
+						newModule, cmd := listening.Init(m.state)
+						m.listeningModule = newModule
+						return m, cmd
+					case menuLogs:
+						m.view = viewLogs
+						newModule, cmd := logs.Init(m.logsModule)
+						m.logsModule = newModule
+						return m, cmd
+					case menuLogStats:
+						m.view = viewLogStats
+						newModule, cmd := logstats.Init(m.state.Rules)
+						m.logStatsModule = newModule
+						return m, cmd
+					case menuSimulate:
+						m.view = viewSimulate
+						m.simulateModule = simulate.Init(m.state)
+					case menuLint:
+						m.view = viewLint
+						m.lintModule = lint.Init(m.state.Rules, m.state.IPv6.WithDefault(true))
+					case menuAudit:
+						m.view = viewAudit
+						newModule, cmd := audit.Init(m.state)
+						m.auditModule = newModule
+						return m, cmd
 					case menuQuit:
 						return m, tea.Quit
 					}
 				}
 			}
 		case m.view.isCreateRule():
-			switch msg.(type) {
+			switch msg := msg.(type) {
 			case createrule.CreateRuleCreatedMsg:
-				m = m.reloadStatus()
-				m = m.reloadRules()
 				m.view = viewStateHome
-				return m, nil
+				return m, func() tea.Msg {
+					return ufwCommandFinishedMsg{Output: msg.Output}
+				}
 			case createrule.CreateRuleEscMsg:
 				m.view = viewStateHome
 				return m, nil
@@ -267,6 +438,22 @@ func (mod model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, cmd
 
 		case m.view.isDeleteRule():
+			if m.twinDialog != nil {
+				newTwinDialog, _, outMsg := m.twinDialog.UpdateDialog(msg)
+				m.twinDialog = newTwinDialog
+				switch outMsg {
+				case confirmation.ConfirmationDialogYes:
+					m.twinDialog = nil
+					return m, deleteRulesCmd(append(m.toDelete, m.missingTwins(m.toDelete)...))
+				case confirmation.ConfirmationDialogNo:
+					m.twinDialog = nil
+					return m, deleteRulesCmd(m.toDelete)
+				case confirmation.ConfirmationDialogEsc:
+					m.twinDialog = nil
+				}
+				return m, nil
+			}
+
 			if m.deleteDialog != nil {
 				newDeleteDialog, _, outMsg := m.deleteDialog.UpdateDialog(msg)
 				m.deleteDialog = newDeleteDialog
@@ -274,26 +461,20 @@ func (mod model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				case confirmation.ConfirmationDialogYes:
 					m.deleteDialog = nil
 
-					return m, teacmd.RunOsCmdAndAfter(func() string {
-						if m.rules.NoneSelected() {
-							return ufw.DeleteRuleByNumber(m.rules.FocusedIndex() + 1)
-						} else {
-							var output string
-							// we have to reverse otherwise the position of the next element for deletion changes
-							selectedSlice := m.rules.GetSelectedIndexes()
-							sort.Slice(selectedSlice, func(i, j int) bool {
-								return selectedSlice[i] > selectedSlice[j]
-							})
-
-							lo.ForEach(selectedSlice, func(i int, _ int) {
-								ufw.DeleteRuleByNumber(i + 1)
-							})
-							return output
-						}
-					}, func(s string) tea.Msg {
-						return rulesDeletedMsg{Output: s}
-					},
-					)
+					numbers := []int{m.rules.FocusedItem().Number}
+					if !m.rules.NoneSelected() {
+						numbers = lo.Map(m.rules.GetSelectedItems(), func(r ufw.Rule, _ int) int {
+							return r.Number
+						})
+					}
+					// ufw added an IPv6 twin for rules without an IPv4 address, offer to delete the pair
+					if twins := m.missingTwins(numbers); len(twins) > 0 {
+						m.toDelete = numbers
+						m.twinDialog = confirmation.NewConfirmDialog(fmt.Sprintf("Also delete the IPv4/IPv6 twin rules %s?",
+							strings.Join(lo.Map(twins, func(n int, _ int) string { return fmt.Sprint(n) }), ", ")))
+						return m, nil
+					}
+					return m, deleteRulesCmd(numbers)
 
 				case confirmation.ConfirmationDialogNo:
 					m.deleteDialog = nil
@@ -307,8 +488,9 @@ func (mod model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch msg := msg.(type) {
 			case rulesDeletedMsg:
 				m.rules.FocusFirst()
-				m = m.reloadRules()
-				return m, teacmd.OsCmdExecutionFinishedCmd(msg.Output)
+				return m, func() tea.Msg {
+					return ufwCommandFinishedMsg{Output: msg.Output}
+				}
 
 			case tea.KeyMsg:
 				key := msg.String()
@@ -317,7 +499,16 @@ func (mod model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.rules.Prev()
 				case "down", "j":
 					m.rules.Next()
+				case "z":
+					m.zeroHitsOnly = !m.zeroHitsOnly
+					m.rules.SetItems(m.visibleRules())
+					m.rules.FocusFirst()
+				case "r":
+					return m, runUfwCommand(ufw.ResetRuleCounters)
 				case "d":
+					if len(m.rules.Items) == 0 {
+						return m, nil
+					}
 					if m.rules.NoneSelected() {
 						m.deleteDialog = confirmation.NewConfirmDialog("Are you sure you want to delete this rule?")
 					} else {
@@ -326,7 +517,6 @@ func (mod model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				case "esc":
 					m.view = viewStateHome
-					m = m.reloadStatus()
 				case " ":
 					m.rules.Toggle()
 				}
@@ -335,9 +525,7 @@ func (mod model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch msg.(type) {
 			case profiles.ProfilesEscMsg:
 				m.view = viewStateHome
-				m = m.reloadStatus()
-				m = m.reloadRules()
-				return m, nil
+				return m.refresh()
 			}
 			newModule, cmd := m.profilesModule.UpdateProfilesModule(msg)
 			m.profilesModule = newModule
@@ -349,8 +537,10 @@ func (mod model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.view = viewStateHome
 				return m, nil
 			case defaultpolicies.DefaultPoliciesUpdatedMsg:
-				m = m.reloadStatus()
-				return m, teacmd.OsCmdExecutionFinishedCmd(msg.Output)
+				m.view = viewStateHome
+				return m, func() tea.Msg {
+					return ufwCommandFinishedMsg{Output: msg.Output}
+				}
 			}
 
 			newModule, cmd := m.setDefaultsModule.UpdateDefaultsModule(msg)
@@ -379,13 +569,206 @@ func (mod model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					case showBuiltins:
 						toShow = "builtins"
 					}
-					cmd := exec.Command("bash", "-c", "sudo ufw show "+toShow+" | less")
-					cmd.Stdout = os.Stdout
-					cmd.Stderr = os.Stderr
-					cmd.Stdin = os.Stdin
-					_ = cmd.Run()
+					var isHeader func(string) bool
+					if toShow == "raw" {
+						isHeader = isRawSectionHeader
+					}
+					savePath := fmt.Sprintf("ufw-show-%s-%s.txt", toShow, time.Now().Format("2006-01-02_15-04-05"))
+					m.reportPager = pager.New("ufw show "+toShow, savePath, isHeader).SetSize(m.width, m.height)
+					m.view = viewShowReport
+					return m, func() tea.Msg {
+						return reportLoadedMsg{Output: ufw.Show(toShow)}
+					}
 				}
 			}
+		case m.view.isListening():
+			switch msg := msg.(type) {
+			case listening.ListeningEscMsg:
+				m.view = viewStateHome
+				return m, nil
+			case listening.ListeningCreateRuleMsg:
+				m.ruleForm = createrule.NewPrefilledRuleForm(msg.Prefill)
+				m.view = viewStateCreateRule
+				return m, nil
+			}
+
+			newModule, cmd := m.listeningModule.UpdateListeningModule(msg)
+			m.listeningModule = newModule
+			return m, cmd
+		case m.view.isAudit():
+			switch msg := msg.(type) {
+			case audit.AuditEscMsg:
+				m.view = viewStateHome
+				return m, nil
+			case audit.AuditRulesRemovedMsg:
+				m.view = viewStateHome
+				return m, func() tea.Msg {
+					return ufwCommandFinishedMsg{Output: msg.Output}
+				}
+			}
+
+			newModule, cmd := m.auditModule.UpdateAuditModule(msg)
+			m.auditModule = newModule
+			return m, cmd
+		case m.view.isLint():
+			switch msg := msg.(type) {
+			case lint.LintEscMsg:
+				m.view = viewStateHome
+				return m, nil
+			case lint.LintShowRuleMsg:
+				m.view = viewStateDeleteRule
+				m.zeroHitsOnly = false
+				m.rules.SetItems(m.visibleRules())
+				m.rules.Focused = lo.IndexOf(lo.Map(m.rules.Items, func(r ufw.Rule, _ int) int {
+					return r.Number
+				}), msg.Number)
+				return m, nil
+			}
+
+			newModule, cmd := m.lintModule.UpdateLintModule(msg)
+			m.lintModule = newModule
+			return m, cmd
+		case m.view.isSimulate():
+			switch msg.(type) {
+			case simulate.SimulateEscMsg:
+				m.view = viewStateHome
+				return m, nil
+			}
+
+			newModule, cmd := m.simulateModule.UpdateSimulateModule(msg)
+			m.simulateModule = newModule
+			return m, cmd
+		case m.view.isLogs():
+			switch msg := msg.(type) {
+			case logs.LogsEscMsg:
+				m.view = viewStateHome
+				return m, nil
+			case logs.LogsCreateRuleMsg:
+				m.ruleForm = createrule.NewPrefilledRuleForm(msg.Prefill)
+				m.view = viewStateCreateRule
+				return m, nil
+			}
+
+			newModule, cmd := m.logsModule.UpdateLogsModule(msg)
+			m.logsModule = newModule
+			return m, cmd
+		case m.view.isLogStats():
+			switch msg := msg.(type) {
+			case logstats.LogStatsEscMsg:
+				m.view = viewStateHome
+				return m, nil
+			case logstats.LogStatsCreateRuleMsg:
+				m.ruleForm = createrule.NewPrefilledRuleForm(msg.Prefill)
+				m.view = viewStateCreateRule
+				return m, nil
+			}
+
+			newModule, cmd := m.logStatsModule.UpdateLogStatsModule(msg)
+			m.logStatsModule = newModule
+			return m, cmd
+		case m.view.isPlaybooks():
+			switch msg := msg.(type) {
+			case playbooks.PlaybooksEscMsg:
+				m.view = viewStateHome
+				return m, nil
+			case playbooks.PlaybookAppliedMsg:
+				m.view = viewStateHome
+				return m, func() tea.Msg {
+					return ufwCommandFinishedMsg{Output: msg.Output}
+				}
+			}
+
+			newModule, cmd := m.playbooksModule.UpdatePlaybooksModule(msg)
+			m.playbooksModule = newModule
+			return m, cmd
+		case m.view.isTransfer():
+			switch msg := msg.(type) {
+			case transfer.TransferEscMsg:
+				m.view = viewStateHome
+				return m, nil
+			case transfer.TransferAppliedMsg:
+				m.view = viewStateHome
+				return m, func() tea.Msg {
+					return ufwCommandFinishedMsg{Output: msg.Output}
+				}
+			}
+
+			newModule, cmd := m.transferModule.UpdateTransferModule(msg)
+			m.transferModule = newModule
+			return m, cmd
+		case m.view.isGroups():
+			switch msg := msg.(type) {
+			case groups.GroupsEscMsg:
+				m.view = viewStateHome
+				return m, nil
+			case groups.GroupsSyncedMsg:
+				m.view = viewStateHome
+				return m, func() tea.Msg {
+					return ufwCommandFinishedMsg{Output: msg.Output}
+				}
+			}
+
+			newModule, cmd := m.groupsModule.UpdateGroupsModule(msg)
+			m.groupsModule = newModule
+			return m, cmd
+		case m.view.isReresolve():
+			switch msg := msg.(type) {
+			case reresolve.ReresolveEscMsg:
+				m.view = viewStateHome
+				return m, nil
+			case reresolve.ReresolveAppliedMsg:
+				m.view = viewStateHome
+				return m, func() tea.Msg {
+					return ufwCommandFinishedMsg{Output: msg.Output}
+				}
+			}
+
+			newModule, cmd := m.reresolveModule.UpdateReresolveModule(msg)
+			m.reresolveModule = newModule
+			return m, cmd
+		case m.view.isNat():
+			switch msg := msg.(type) {
+			case nat.NatEscMsg:
+				m.view = viewStateHome
+				return m, nil
+			case nat.NatUpdatedMsg:
+				m.view = viewStateHome
+				return m, func() tea.Msg {
+					return ufwCommandFinishedMsg{Output: msg.Output}
+				}
+			}
+
+			newModule, cmd := m.natModule.UpdateNatModule(msg)
+			m.natModule = newModule
+			return m, cmd
+		case m.view.isLogging():
+			switch msg := msg.(type) {
+			case logging.LoggingEscMsg:
+				m.view = viewStateHome
+				return m, nil
+			case logging.LoggingUpdatedMsg:
+				m.view = viewStateHome
+				return m, func() tea.Msg {
+					return ufwCommandFinishedMsg{Output: msg.Output}
+				}
+			}
+
+			newModule, cmd := m.loggingModule.UpdateLoggingModule(msg)
+			m.loggingModule = newModule
+			return m, cmd
+		case m.view.isShowReport():
+			switch msg := msg.(type) {
+			case reportLoadedMsg:
+				m.reportPager = m.reportPager.SetContent(msg.Output)
+				return m, nil
+			case pager.PagerEscMsg:
+				m.view = viewShow
+				return m, nil
+			}
+
+			newPager, cmd := m.reportPager.UpdatePager(msg)
+			m.reportPager = newPager
+			return m, cmd
 		}
 	}
 	return m, nil
@@ -399,53 +782,80 @@ func (m model) setNotification(msg string) (model, tea.Cmd) {
 	})
 }
 
-func (m model) resetMenu() model {
-	m.menuList.SetItems(buildMenu())
-	m = m.reloadStatus()
-	return m
-}
-
-func (m model) reloadStatus() model {
-	m.status = ufw.StatusVerbose()
-	return m
+// visibleRules applies the zero hits filter of the rule list.
+func (m model) visibleRules() []ufw.Rule {
+	if !m.zeroHitsOnly || m.state.Counters.IsErr() {
+		return ufw.GroupTwins(m.state.Rules, m.twins)
+	}
+	counters := m.state.Counters.Value()
+	return ufw.GroupTwins(lo.Filter(m.state.Rules, func(r ufw.Rule, _ int) bool {
+		return counters[r.Number].Packets == 0
+	}), m.twins)
 }
 
-func (m model) reloadRules() model {
-	lines := strings.Split(ufw.StatusNumbered(), "\n")
-	if len(lines) < 6 {
-		m.rules.SetItems([]rule{})
-		return m
+// missingTwins lists the twins of the given rules that are not about to be deleted with them.
+func (m model) missingTwins(numbers []int) []int {
+	var twins []int
+	for _, n := range numbers {
+		if twin, ok := m.twins[n]; ok && !lo.Contains(numbers, twin) && !lo.Contains(twins, twin) {
+			twins = append(twins, twin)
+		}
 	}
+	return twins
+}
 
-	lines = lines[4:(len(lines) - 2)]
-	rules := lo.Map(lines, func(line string, index int) rule {
-		return rule{
-			number: index,
-			line:   line,
-		}
+func deleteRulesCmd(numbers []int) tea.Cmd {
+	return teacmd.RunOsCmdAndAfter(func() string {
+		return ufw.DeleteRulesByNumber(numbers)
+	}, func(s string) tea.Msg {
+		return rulesDeletedMsg{Output: s}
 	})
-	m.rules.SetItems(rules)
-	return m
 }
 
-func buildMenu() []menuItem {
-	enabled, loggingOn := getStatus()
+// refresh reloads the cached firewall state in the background.
+func (m model) refresh() (model, tea.Cmd) {
+	m.loading = true
+	return m, state.LoadCmd()
+}
+
+// runUfwCommand runs a state changing ufw command off the UI thread and refreshes the state afterwards.
+func runUfwCommand(command func() string) tea.Cmd {
+	return teacmd.RunOsCmdAndAfter(command, func(s string) tea.Msg {
+		return ufwCommandFinishedMsg{Output: s}
+	})
+}
 
+func buildMenu(st state.State) []menuItem {
 	items := []menuItem{}
 
-	if enabled {
+	if st.Enabled {
 		items = append(items, menuItem{"Disable", menuDisableUFW})
 		items = append(items, menuItem{"Set defaults", menuSetDefault})
+		items = append(items, menuItem{"NAT / port forwarding", menuNat})
 		items = append(items,
 			menuItem{"Profiles", menuProfiles},
 			menuItem{"Create rule", menuCreateRule},
+			menuItem{"Playbooks", menuPlaybooks},
 			menuItem{"Delete rule", menuDeleteRule},
+			menuItem{"Address groups", menuGroups},
 			menuItem{"Show", menuShow},
+			menuItem{"Listening ports", menuListening},
+			menuItem{"Stale rules", menuAudit},
+			menuItem{"Lint rules", menuLint},
+			menuItem{"Test traffic", menuSimulate},
+			menuItem{"Export / import", menuTransfer},
 		)
-		if loggingOn {
-			items = append(items, menuItem{"Disable logging", menuDisableLogging})
-		} else {
-			items = append(items, menuItem{"Enable logging", menuEnableLogging})
+		hasHostRules := lo.ContainsBy(st.Rules, func(r ufw.Rule) bool {
+			_, ok := hostnames.FromComment(r.Comment)
+			return ok
+		})
+		if hasHostRules {
+			items = append(items, menuItem{"Re-resolve hostnames", menuReresolve})
+		}
+		items = append(items, menuItem{"Logs", menuLogs}, menuItem{"Log analytics", menuLogStats})
+		items = append(items, menuItem{fmt.Sprintf("Logging (%s)", st.LoggingLevel), menuLogging})
+		if st.IPv6.IsOk() {
+			items = append(items, menuItem{lo.Ternary(st.IPv6.Value(), "Disable IPv6", "Enable IPv6"), menuToggleIPv6})
 		}
 	} else {
 		items = append(items, menuItem{"Enable", menuEnableUFW})
@@ -460,19 +870,6 @@ func buildMenu() []menuItem {
 	return items
 }
 
-func getStatus() (enabled bool, loggingOn bool) {
-	lines := strings.Split(ufw.StatusVerbose(), "\n")
-	for _, line := range lines {
-		if strings.HasPrefix(line, "Status: active") {
-			enabled = true
-		}
-		if strings.HasPrefix(line, "Logging:") && strings.Contains(line, "on") {
-			loggingOn = true
-		}
-	}
-	return
-}
-
 // VIEW
 
 func (m model) View() string {
@@ -484,27 +881,66 @@ func (m model) View() string {
 
 	switch true {
 	case m.view.isHome():
-		if m.resetDialog != nil {
-			return m.resetDialog.ViewDialog()
+		if m.firewallDialog != nil {
+			return m.firewallDialog.ViewDialog()
+		}
+		if !m.loaded {
+			return "Loading firewall state..."
 		}
 		left := renderMenu(m.menuList)
-		right := strings.Split(m.status, "\n")
+		right := strings.Split(m.state.Status, "\n")
+		if m.state.IPv6.IsOk() {
+			right = append([]string{"IPv6: " + lo.Ternary(m.state.IPv6.Value(), "yes", "no")}, right...)
+		}
+		if m.loading {
+			right = append([]string{"Refreshing..."}, right...)
+		}
 		output = renderTwoColumns(left, right)
 	case m.view.isCreateRule():
 		output = m.ruleForm.ViewCreateRule()
 	case m.view.isDeleteRule():
+		if m.twinDialog != nil {
+			return m.twinDialog.ViewDialog()
+		}
 		if m.deleteDialog != nil {
 			return m.deleteDialog.ViewDialog()
 		}
-		lines := []string{"Focus rule to delete:"}
-		m.rules.ForEach(func(rule rule, index int, isFocused, isSelected bool) {
+		lines := []string{
+			"Focus rule to delete:" + lo.Ternary(m.zeroHitsOnly, " (zero hits since boot only)", ""),
+			fmt.Sprintf("   %-70s %10s %12s", "", "Packets", "Bytes"),
+		}
+		m.rules.ForEach(func(rule ufw.Rule, index int, isFocused, isSelected bool) {
 			focusedPrefix := lo.Ternary(isFocused, ">", " ")
 			selectedPrefix := lo.Ternary(isSelected, "*", " ")
 			prefix := focusedPrefix + selectedPrefix
-			lines = append(lines, fmt.Sprintf("%s %s", prefix, rule.line))
+			packets, bytes := "-", "-"
+			if m.state.Counters.IsOk() {
+				counter := m.state.Counters.Value()[rule.Number]
+				packets, bytes = fmt.Sprint(counter.Packets), fmt.Sprint(counter.Bytes)
+			}
+			ruleLine := rule.Line
+			if _, isTwin := m.twins[rule.Number]; isTwin && rule.V6 && index > 0 && m.rules.Items[index-1].Number == m.twins[rule.Number] {
+				ruleLine = "  ↳ " + ruleLine
+			}
+			line := fmt.Sprintf("%s %-70s %10s %12s", prefix, ruleLine, packets, bytes)
+			if findings := m.findings[rule.Number]; len(findings) > 0 {
+				kinds := lo.Uniq(lo.Map(findings, func(f ufw.Finding, _ int) string {
+					return string(f.Kind)
+				}))
+				line += "  ! " + strings.Join(kinds, ", ")
+			}
+			lines = append(lines, line)
 		})
+		if len(m.rules.Items) > 0 {
+			for _, f := range m.findings[m.rules.FocusedItem().Number] {
+				lines = append(lines, "", fmt.Sprintf("! [%s] %s", f.Kind, f.Message))
+			}
+		}
+		if m.state.Counters.IsErr() {
+			lines = append(lines, "", "Counters unavailable: "+m.state.Counters.Err().Error())
+		}
 		output = strings.Join(lines, "\n")
-		output += "\n\n↑↓ to navigate, d to delete, Space to select, Esc to cancel"
+		output += "\n\n↑↓ to navigate, d to delete, Space to select, z to toggle zero hits filter, r to reset counters, Esc to cancel"
 	case m.view.isProfiles():
 		output = m.profilesModule.ViewProfiles()
 	case m.view.isSetDefault():
@@ -516,13 +952,44 @@ func (m model) View() string {
 			lines = append(lines, fmt.Sprintf("%s %s", focusedPrefix, item))
 		})
 		output = strings.Join(lines, "\n")
-		output += "\n\n↑↓ to navigate, Enter to confirm, q to exit selection, Esc to cancel"
+		output += "\n\n↑↓ to navigate, Enter to confirm, Esc to cancel"
+	case m.view.isShowReport():
+		output = m.reportPager.ViewPager()
+	case m.view.isListening():
+		output = m.listeningModule.ViewListening()
+	case m.view.isAudit():
+		output = m.auditModule.ViewAudit()
+	case m.view.isLint():
+		output = m.lintModule.ViewLint()
+	case m.view.isSimulate():
+		output = m.simulateModule.ViewSimulate()
+	case m.view.isLogs():
+		output = m.logsModule.ViewLogs()
+	case m.view.isLogStats():
+		output = m.logStatsModule.ViewLogStats()
+	case m.view.isLogging():
+		output = m.loggingModule.ViewLogging()
+	case m.view.isNat():
+		output = m.natModule.ViewNat()
+	case m.view.isReresolve():
+		output = m.reresolveModule.ViewReresolve()
+	case m.view.isGroups():
+		output = m.groupsModule.ViewGroups()
+	case m.view.isPlaybooks():
+		output = m.playbooksModule.ViewPlaybooks()
+	case m.view.isTransfer():
+		output = m.transferModule.ViewTransfer()
 	}
 
 	output += "\n\n" + m.notification
 	return output
 }
 
+// isRawSectionHeader folds the `ufw show raw` output into one section per iptables chain.
+func isRawSectionHeader(line string) bool {
+	return strings.HasPrefix(line, "Chain ") || strings.HasPrefix(line, "IPV4") || strings.HasPrefix(line, "IPV6")
+}
+
 func renderMenu(menu *focusablelist.SelectableList[menuItem]) []string {
 	var lines []string
 	lines = append(lines, "", "UFW Firewall Menu:", "")
@@ -550,60 +1017,7 @@ func renderTwoColumns(left []string, right []string) string {
 }
 
 func backup() {
-	backupDir := "/etc/ufw/backup"
-	err := os.MkdirAll(backupDir, 0755)
-	if err != nil {
-		fmt.Println("Failed to create backup directory", err)
-	}
-
-	entries, err := os.ReadDir(backupDir)
-	if err != nil {
-		log.Fatalf("Failed to read directory: %v", err)
-	}
-
-	var latestFile string
-	var latestTime int64
-
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-		path := filepath.Join(backupDir, entry.Name())
-		info, err := entry.Info()
-		if err != nil {
-			log.Printf("Skipping %s: %v", path, err)
-			continue
-		}
-		ctime := getCreationTime(info)
-		if ctime > latestTime {
-			latestTime = ctime
-			latestFile = path
-		}
-	}
-
-	currentUFWStatus, err := ufw.GetStateFromFiles()
-	if err != nil {
-		fmt.Println("Failed to export the state for backup", err)
-	}
-
-	if latestFile != "" {
-		data, err := os.ReadFile(latestFile)
-		if err != nil {
-			log.Fatalf("Failed to read file: %v", err)
-		}
-
-		if string(data) == currentUFWStatus {
-			return
-		}
-	}
-
-	err = os.WriteFile(fmt.Sprintf("%s/%s.sh", backupDir, time.Now().Format("2006-01-02_15-04-05")), []byte(currentUFWStatus), 0755)
-	if err != nil {
+	if _, err := ufw.Backup(); err != nil {
 		fmt.Println("Failed to backup the firewall settings", err)
 	}
 }
-
-func getCreationTime(info fs.FileInfo) int64 {
-	stat := info.Sys().(*syscall.Stat_t)
-	return stat.Ctim.Sec
-}
@@ -0,0 +1,181 @@
+package audit
+
+import (
+	"fmt"
+	"fwtui/domain/entity"
+	"fwtui/domain/sockets"
+	"fwtui/domain/ufw"
+	"fwtui/modules/shared/confirmation"
+	"fwtui/modules/shared/state"
+	"fwtui/utils/multiselect"
+	"fwtui/utils/result"
+	"fwtui/utils/teacmd"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/samber/lo"
+)
+
+// MODEL
+
+type StaleRule struct {
+	Rule   ufw.Rule
+	Reason string
+}
+
+type AuditModule struct {
+	state        state.State
+	stale        multiselect.MultiSelectableList[StaleRule]
+	loading      bool
+	err          error
+	hint         string
+	deleteDialog *confirmation.ConfirmDialog
+}
+
+func Init(st state.State) (AuditModule, tea.Cmd) {
+	return AuditModule{state: st, loading: true}, func() tea.Msg {
+		list, err := sockets.ListListening()
+		return socketsLoadedMsg{sockets: list, err: err}
+	}
+}
+
+// FindStaleRules lists the allow rules nothing listens behind and the app rules whose profile is gone.
+// While the profiles can't be read no app rule counts as stale.
+func FindStaleRules(rules []ufw.Rule, listening []sockets.Socket, profiles result.Result[[]entity.UFWProfile]) []StaleRule {
+	profileNames := lo.Map(profiles.WithDefault(nil), func(p entity.UFWProfile, _ int) string {
+		return p.Name
+	})
+
+	var stale []StaleRule
+	for _, rule := range rules {
+		if rule.Route || rule.Direction != "in" || !rule.IsAllowing() {
+			continue
+		}
+
+		if rule.DestApp != "" {
+			if profiles.IsOk() && !lo.Contains(profileNames, rule.DestApp) {
+				stale = append(stale, StaleRule{Rule: rule, Reason: fmt.Sprintf("profile %s no longer exists", rule.DestApp)})
+			}
+			continue
+		}
+
+		if rule.DestPort == "any" {
+			continue
+		}
+		hasListener := lo.ContainsBy(listening, func(s sockets.Socket) bool {
+			return !s.IsLocalOnly() && rule.MatchesPort(s.Port, s.Protocol)
+		})
+		if !hasListener {
+			stale = append(stale, StaleRule{Rule: rule, Reason: fmt.Sprintf("nothing listens on %s", rule.DestPort)})
+		}
+	}
+	return stale
+}
+
+// UPDATE
+
+type socketsLoadedMsg struct {
+	sockets []sockets.Socket
+	err     error
+}
+
+type AuditEscMsg struct{}
+
+// AuditRulesRemovedMsg reports the removal so that the firewall state gets reloaded.
+type AuditRulesRemovedMsg struct{ Output string }
+
+func (module AuditModule) UpdateAuditModule(msg tea.Msg) (AuditModule, tea.Cmd) {
+	m := module
+
+	if m.deleteDialog != nil {
+		newDialog, _, outMsg := m.deleteDialog.UpdateDialog(msg)
+		m.deleteDialog = newDialog
+		switch outMsg {
+		case confirmation.ConfirmationDialogYes:
+			m.deleteDialog = nil
+			numbers := lo.Map(m.stale.GetSelectedItems(), func(s StaleRule, _ int) int {
+				return s.Rule.Number
+			})
+			return m, teacmd.RunOsCmdAndAfter(func() string {
+				return ufw.DeleteRulesByNumber(numbers)
+			}, func(s string) tea.Msg {
+				return AuditRulesRemovedMsg{Output: s}
+			})
+		case confirmation.ConfirmationDialogNo, confirmation.ConfirmationDialogEsc:
+			m.deleteDialog = nil
+		}
+		return m, nil
+	}
+
+	switch msg := msg.(type) {
+	case socketsLoadedMsg:
+		m.loading = false
+		m.err = msg.err
+		m.stale = multiselect.FromList(FindStaleRules(m.state.Rules, msg.sockets, m.state.Profiles))
+	case tea.KeyMsg:
+		key := msg.String()
+		m.hint = ""
+		switch key {
+		case "up", "k":
+			m.stale.Prev()
+		case "down", "j":
+			m.stale.Next()
+		case " ":
+			m.stale.Toggle()
+		case "a":
+			for i := range m.stale.Items {
+				m.stale.Selected.Add(i)
+			}
+		case "x":
+			if len(m.stale.Items) == 0 {
+				return m, nil
+			}
+			// a rule is only removed when it was picked, an empty selection doesn't stand for all
+			if m.stale.NoneSelected() {
+				m.hint = "Select the rules to remove with Space, or all of them with a"
+				return m, nil
+			}
+			m.deleteDialog = confirmation.NewConfirmDialog(fmt.Sprintf("Remove %d selected stale rules?", len(m.stale.GetSelectedItems())))
+		case "esc":
+			return m, func() tea.Msg {
+				return AuditEscMsg{}
+			}
+		}
+	}
+	return m, nil
+}
+
+// VIEW
+
+func (module AuditModule) ViewAudit() string {
+	if module.deleteDialog != nil {
+		return module.deleteDialog.ViewDialog()
+	}
+	if module.loading {
+		return "Reading listening sockets..."
+	}
+	if module.err != nil {
+		return fmt.Sprintf("Failed to read listening sockets: %s\n\nEsc to go back", module.err)
+	}
+
+	lines := []string{"Stale rules:"}
+	module.stale.ForEach(func(s StaleRule, _ int, isFocused, isSelected bool) {
+		focusedPrefix := lo.Ternary(isFocused, ">", " ")
+		selectedPrefix := lo.Ternary(isSelected, "*", " ")
+		lines = append(lines, fmt.Sprintf("%s%s %-60s | %s", focusedPrefix, selectedPrefix, s.Rule.Line, s.Reason))
+	})
+	if len(module.stale.Items) == 0 {
+		lines = append(lines, "  No stale rules found")
+	}
+
+	if module.state.Profiles.IsErr() {
+		lines = append(lines, "", fmt.Sprintf("App rules were not checked, the profiles could not be read: %s", module.state.Profiles.Err()))
+	}
+	if module.hint != "" {
+		lines = append(lines, "", module.hint)
+	}
+
+	output := strings.Join(lines, "\n")
+	output += "\n\n↑↓ to navigate, Space to select, a to select all, x to remove the selected rules, Esc to cancel"
+	return output
+}
@@ -2,12 +2,18 @@ package createrule
 
 import (
 	"fmt"
+	"fwtui/domain/addrgroups"
+	"fwtui/domain/hostnames"
 	"fwtui/domain/notification"
+	"fwtui/domain/services"
+	"fwtui/modules/shared/confirmation"
 	"fwtui/utils/focusablelist"
 	"fwtui/utils/oscmd"
 	"fwtui/utils/result"
 	stringsext "fwtui/utils/strings"
+	"fwtui/utils/teacmd"
 	"net"
+	"net/netip"
 	"strconv"
 	"strings"
 
@@ -27,6 +33,7 @@ const (
 	RuleSourceIP      = "SourceIP"
 	RuleDestinationIP = "DestinationIP"
 	RuleInterface     = "Interface"
+	RuleInterfaceOut  = "InterfaceOut"
 	RuleFormComment   = "Comment"
 )
 
@@ -39,7 +46,14 @@ type RuleForm struct {
 	sourceIP      string
 	destinationIP string
 	interface_    *focusablelist.SelectableList[string]
+	interfaceOut  *focusablelist.SelectableList[string] // outgoing interface of a route rule
 	selectedField *focusablelist.SelectableList[Field]
+	position      int // inserts the rule at this number instead of appending it, 0 appends
+
+	resolver      hostnames.Resolver
+	resolving     string // host being looked up
+	expandDialog  *confirmation.ConfirmDialog
+	expandedRules []string
 }
 
 func NewRuleForm() RuleForm {
@@ -50,18 +64,95 @@ func NewRuleForm() RuleForm {
 		action:        focusablelist.FromList(actions),
 		dir:           focusablelist.FromList(directions),
 		interface_:    focusablelist.FromList(availableInterfaces),
+		interfaceOut:  focusablelist.FromList(availableInterfaces),
 		selectedField: focusablelist.FromList(fieldsForDirection(DirectionIn)),
+		resolver:      hostnames.DefaultResolver,
 	}
 }
 
+// WithResolver replaces the resolver used for host names typed into the address fields.
+func (f RuleForm) WithResolver(resolver hostnames.Resolver) RuleForm {
+	f.resolver = resolver
+	return f
+}
+
+// Prefill holds the values a rule form opens with, empty values keep the form defaults.
+type Prefill struct {
+	Port          string
+	Protocol      Protocol
+	Action        Action
+	Direction     Direction
+	SourceIP      string
+	DestinationIP string
+	Interface     string
+	InterfaceOut  string // outgoing interface of a route rule
+	Comment       string
+	Position      int // inserts the rule at this number instead of appending it
+}
+
+func NewPrefilledRuleForm(prefill Prefill) RuleForm {
+	form := NewRuleForm()
+	form.port = prefill.Port
+	form.sourceIP = prefill.SourceIP
+	form.destinationIP = prefill.DestinationIP
+	form.comment = prefill.Comment
+	form.position = prefill.Position
+	form.protocol.Focus(prefill.Protocol)
+	form.action.Focus(prefill.Action)
+	form.interface_.Focus(prefill.Interface)
+	form.interfaceOut.Focus(prefill.InterfaceOut)
+	form.dir.Focus(prefill.Direction)
+	form.selectedField.SetItems(fieldsForDirection(form.dir.Focused()))
+	return form
+}
+
 // UPDATE
 
 type CreateRuleEscMsg struct{}
-type CreateRuleCreatedMsg struct{}
+type CreateRuleCreatedMsg struct{ Output string }
+
+type hostResolvedMsg struct {
+	host  string
+	addrs []netip.Addr
+	err   error
+}
 
 func (f RuleForm) UpdateRuleForm(msg tea.Msg) (RuleForm, tea.Cmd) {
 	form := f
+
+	if form.expandDialog != nil {
+		newDialog, _, outMsg := form.expandDialog.UpdateDialog(msg)
+		form.expandDialog = newDialog
+		switch outMsg {
+		case confirmation.ConfirmationDialogYes:
+			commands := form.expandedRules
+			form.expandDialog, form.expandedRules = nil, nil
+			return form, teacmd.RunOsCmdAndAfter(func() string {
+				return strings.Join(lo.Map(commands, func(command string, _ int) string {
+					return oscmd.RunCommand(command)
+				}), "")
+			}, func(s string) tea.Msg {
+				return CreateRuleCreatedMsg{Output: s}
+			})
+		case confirmation.ConfirmationDialogNo, confirmation.ConfirmationDialogEsc:
+			form.expandDialog, form.expandedRules = nil, nil
+		}
+		return form, nil
+	}
+
 	switch msg := msg.(type) {
+	case hostResolvedMsg:
+		if msg.host != form.resolving {
+			return form, nil
+		}
+		form.resolving = ""
+		if msg.err != nil {
+			return form, notification.CreateCmd(msg.err.Error())
+		}
+		addrs := lo.Map(msg.addrs, func(addr netip.Addr, _ int) string {
+			return addr.String()
+		})
+		return form.confirmExpanded(fmt.Sprintf("%s resolves to:", msg.host), hostnames.Comment(msg.host, form.comment), addrs)
 	case tea.KeyMsg:
 		key := msg.String()
 		switch key {
@@ -80,6 +171,8 @@ func (f RuleForm) UpdateRuleForm(msg tea.Msg) (RuleForm, tea.Cmd) {
 				form.selectedField.SetItems(fieldsForDirection(form.dir.Focused()))
 			case RuleInterface:
 				form.interface_.Prev()
+			case RuleInterfaceOut:
+				form.interfaceOut.Prev()
 			}
 			return form, nil
 		case "right":
@@ -93,6 +186,8 @@ func (f RuleForm) UpdateRuleForm(msg tea.Msg) (RuleForm, tea.Cmd) {
 				form.selectedField.SetItems(fieldsForDirection(form.dir.Focused()))
 			case RuleInterface:
 				form.interface_.Next()
+			case RuleInterfaceOut:
+				form.interfaceOut.Next()
 			}
 			return form, nil
 
@@ -107,14 +202,39 @@ func (f RuleForm) UpdateRuleForm(msg tea.Msg) (RuleForm, tea.Cmd) {
 			case RuleDestinationIP:
 				form.destinationIP = stringsext.TrimLastChar(form.destinationIP)
 			}
+		case "tab":
+			if suggestions := form.suggestions(); form.selectedField.Focused() == RuleFormPort && len(suggestions) > 0 {
+				form.port = suggestions[0]
+			}
+			return form, nil
 		case "enter":
+			if name := f.addressField(); strings.HasPrefix(name, addrgroups.Prefix) {
+				groups, err := addrgroups.Load()
+				if err != nil {
+					return form, notification.CreateCmd(err.Error())
+				}
+				group, found := addrgroups.Find(groups, name)
+				if !found {
+					return form, notification.CreateCmd(fmt.Sprintf("unknown address group: %s", name))
+				}
+				return form.confirmExpanded(fmt.Sprintf("Group %s has these members:", group.Name), addrgroups.Comment(group.Name, form.comment), group.Members)
+			}
+			if host := f.host(); host != "" {
+				form.resolving = host
+				resolver := f.resolver
+				return form, func() tea.Msg {
+					addrs, err := hostnames.Resolve(resolver, host)
+					return hostResolvedMsg{host: host, addrs: addrs, err: err}
+				}
+			}
 			res := f.BuildUfwCommand()
 			if res.IsErr() {
 				return f, notification.CreateCmd(res.Err().Error())
 			}
-			output := oscmd.RunCommand(res.Value())
-			return f, tea.Batch(notification.CreateCmd(output), func() tea.Msg {
-				return CreateRuleCreatedMsg{}
+			return f, teacmd.RunOsCmdAndAfter(func() string {
+				return oscmd.RunCommand(res.Value())
+			}, func(s string) tea.Msg {
+				return CreateRuleCreatedMsg{Output: s}
 			})
 		case "esc":
 			return form, func() tea.Msg {
@@ -136,6 +256,123 @@ func (f RuleForm) UpdateRuleForm(msg tea.Msg) (RuleForm, tea.Cmd) {
 	return form, nil
 }
 
+// addressField returns the address field of the rule's direction.
+func (f RuleForm) addressField() string {
+	return strings.TrimSpace(lo.Ternary(f.dir.Focused() == DirectionOut, f.destinationIP, f.sourceIP))
+}
+
+// host returns the host name typed into the address field of the rule's direction.
+func (f RuleForm) host() string {
+	if value := f.addressField(); hostnames.IsHostname(value) {
+		return value
+	}
+	return ""
+}
+
+// confirmExpanded builds one rule per address, each tagged with the comment, and asks before
+// creating them.
+func (f RuleForm) confirmExpanded(title, comment string, addrs []string) (RuleForm, tea.Cmd) {
+	commands, err := f.expand(comment, addrs)
+	if err != nil {
+		return f, notification.CreateCmd(err.Error())
+	}
+
+	lines := []string{title}
+	for _, addr := range addrs {
+		lines = append(lines, "  "+addr)
+	}
+	lines = append(lines, "", fmt.Sprintf("Create %d rules?", len(commands)))
+	for _, command := range commands {
+		lines = append(lines, "  "+command)
+	}
+	f.expandedRules = commands
+	f.expandDialog = confirmation.NewConfirmDialog(strings.Join(lines, "\n"))
+	return f, nil
+}
+
+func (f RuleForm) expand(comment string, addrs []string) ([]string, error) {
+	var commands []string
+	for _, addr := range addrs {
+		rule := f
+		if f.dir.Focused() == DirectionOut {
+			rule.destinationIP = addr
+		} else {
+			rule.sourceIP = addr
+		}
+		rule.comment = comment
+		res := rule.BuildUfwCommand()
+		if res.IsErr() {
+			return nil, res.Err()
+		}
+		commands = append(commands, res.Value())
+	}
+	return commands, nil
+}
+
+// Commands builds the ufw commands of a rule given as values instead of typed into the form, for the
+// command line. Values the form doesn't offer are errors rather than ignored, an address group or a
+// host name becomes one rule per address like in the form.
+func Commands(prefill Prefill, resolver hostnames.Resolver) ([]string, error) {
+	interfaces, err := GetActiveInterfaces()
+	if err != nil {
+		return nil, fmt.Errorf("listing interfaces: %w", err)
+	}
+	switch {
+	case prefill.Action != "" && !lo.Contains(actions, prefill.Action):
+		return nil, fmt.Errorf("invalid action: %s", prefill.Action)
+	case prefill.Protocol != "" && !lo.Contains(protocols, prefill.Protocol):
+		return nil, fmt.Errorf("invalid protocol: %s", prefill.Protocol)
+	case prefill.Direction != "" && !lo.Contains(directions, prefill.Direction):
+		return nil, fmt.Errorf("invalid direction: %s", prefill.Direction)
+	case !lo.Contains(interfaces, prefill.Interface):
+		return nil, fmt.Errorf("interface %s is not up", prefill.Interface)
+	case !lo.Contains(interfaces, prefill.InterfaceOut):
+		return nil, fmt.Errorf("interface %s is not up", prefill.InterfaceOut)
+	case prefill.Direction == DirectionOut && (prefill.SourceIP != "" || prefill.Interface != ""):
+		return nil, fmt.Errorf("the source and interface are for incoming or routed rules")
+	case (prefill.Direction == "" || prefill.Direction == DirectionIn) && prefill.DestinationIP != "":
+		return nil, fmt.Errorf("the destination is for outgoing or routed rules")
+	case prefill.Direction != DirectionRoute && prefill.InterfaceOut != "":
+		return nil, fmt.Errorf("the outgoing interface is for routed rules")
+	}
+
+	form := NewPrefilledRuleForm(prefill).WithResolver(resolver)
+	if name := form.addressField(); strings.HasPrefix(name, addrgroups.Prefix) {
+		groups, err := addrgroups.Load()
+		if err != nil {
+			return nil, err
+		}
+		group, found := addrgroups.Find(groups, name)
+		if !found {
+			return nil, fmt.Errorf("unknown address group: %s", name)
+		}
+		return form.expand(addrgroups.Comment(group.Name, form.comment), group.Members)
+	}
+	if host := form.host(); host != "" {
+		addrs, err := hostnames.Resolve(resolver, host)
+		if err != nil {
+			return nil, err
+		}
+		return form.expand(hostnames.Comment(host, form.comment), lo.Map(addrs, func(addr netip.Addr, _ int) string {
+			return addr.String()
+		}))
+	}
+
+	res := form.BuildUfwCommand()
+	if res.IsErr() {
+		return nil, res.Err()
+	}
+	return []string{res.Value()}, nil
+}
+
+// validAddress tells whether an address field holds an IP address or a network.
+func validAddress(value string) bool {
+	if _, _, err := net.ParseCIDR(value); err == nil {
+		return true
+	}
+	return net.ParseIP(value) != nil
+}
+
 func fieldsForDirection(dir Direction) []Field {
 	baseFields := []Field{
 		RuleFormPort,
@@ -150,6 +387,8 @@ func fieldsForDirection(dir Direction) []Field {
 		return append(baseFields, RuleSourceIP, RuleInterface)
 	case DirectionOut:
 		return append(baseFields, RuleDestinationIP)
+	case DirectionRoute:
+		return append(baseFields, RuleSourceIP, RuleDestinationIP, RuleInterface, RuleInterfaceOut)
 	default:
 		return baseFields // fallback in case of invalid input
 	}
@@ -177,6 +416,10 @@ func GetActiveInterfaces() ([]string, error) {
 // VIEW
 
 func (f RuleForm) ViewCreateRule() string {
+	if f.expandDialog != nil {
+		return f.expandDialog.ViewDialog()
+	}
+
 	var lines []string
 
 	for _, field := range f.selectedField.GetItems() {
@@ -186,7 +429,7 @@ func (f RuleForm) ViewCreateRule() string {
 		switch field {
 		case RuleFormPort:
 			value = f.port
-			fieldString = "Port"
+			fieldString = "Port or service"
 		case RuleFormProtocol:
 			value = string(f.protocol.Focused())
 			fieldString = "Protocol"
@@ -201,26 +444,92 @@ func (f RuleForm) ViewCreateRule() string {
 			fieldString = "Comment (Optional)"
 		case RuleSourceIP:
 			value = f.sourceIP
-			fieldString = "Source IP (Optional)"
+			fieldString = "Source IP, host or @group (Optional)"
 		case RuleDestinationIP:
 			value = f.destinationIP
-			fieldString = "Destination IP (Optional)"
+			fieldString = "Destination IP, host or @group (Optional)"
 		case RuleInterface:
 			value = f.interface_.Focused()
-			fieldString = "Interface (Optional)"
+			fieldString = lo.Ternary(f.dir.Focused() == DirectionRoute, "Incoming interface (Optional)", "Interface (Optional)")
+		case RuleInterfaceOut:
+			value = f.interfaceOut.Focused()
+			fieldString = "Outgoing interface (Optional)"
 		}
 
 		prefix := lo.Ternary(f.selectedField.Focused() == field, "> ", "  ")
 		line := fmt.Sprintf("%s%s: %s", prefix, fieldString, value)
 		lines = append(lines, line)
+
+		if field == RuleFormPort && f.selectedField.Focused() == RuleFormPort {
+			if suggestions := f.suggestions(); len(suggestions) > 0 {
+				lines = append(lines, "    "+strings.Join(lo.Map(suggestions, func(name string, _ int) string {
+					return fmt.Sprintf("%s (%s)", name, strings.Join(lo.Map(services.Default().Lookup(name), func(s services.Service, _ int) string {
+						return s.PortProto()
+					}), ", "))
+				}), "  "))
+			}
+		}
+	}
+
+	if f.position > 0 {
+		lines = append(lines, "", fmt.Sprintf("The rule is inserted as rule %d, ahead of the rules that would decide the traffic before it.", f.position))
+	}
+
+	if f.resolving != "" {
+		lines = append(lines, "", fmt.Sprintf("Resolving %s...", f.resolving))
 	}
 
 	output := strings.Join(lines, "\n")
-	output += "\n\n↑↓ to navigate, ←→ to change selection, type to edit, Enter to submit, Esc to cancel"
+	output += "\n\n↑↓ to navigate, ←→ to change selection, type to edit, Tab to complete a service name, Enter to submit, Esc to cancel"
 	return output
 }
 
+// resolveService replaces a service name in the port field by its port number, so that names from
+// the builtin list work even where /etc/services doesn't know them. A service defined for one
+// protocol only narrows tcp/udp down to that protocol.
+func (f RuleForm) resolveService() result.Result[RuleForm] {
+	name := strings.TrimSpace(f.port)
+	if name == "" || strings.ContainsAny(name[:1], "0123456789") {
+		return result.Ok(f)
+	}
+
+	entries := services.Default().Lookup(name)
+	if len(entries) == 0 {
+		return result.Err[RuleForm](fmt.Errorf("unknown service: %s", name))
+	}
+
+	protocol := f.protocol.Focused()
+	entry, found := lo.Find(entries, func(s services.Service) bool {
+		return protocol == ProtocolBoth || s.Protocol == string(protocol)
+	})
+	if !found {
+		return result.Err[RuleForm](fmt.Errorf("service %s is not defined for %s", name, protocol))
+	}
+	if protocol == ProtocolBoth && len(entries) == 1 {
+		protocol = Protocol(entry.Protocol)
+	}
+
+	resolved := f
+	resolved.port = strconv.Itoa(entry.Port)
+	resolved.protocol = focusablelist.FromList(protocols).Focus(protocol)
+	return result.Ok(resolved)
+}
+
+// suggestions completes the service name typed into the port field.
+func (f RuleForm) suggestions() []string {
+	if f.port == "" || strings.ContainsAny(f.port[:1], "0123456789") {
+		return nil
+	}
+	return services.Default().Complete(f.port, 5)
+}
+
 func (f RuleForm) BuildUfwCommand() result.Result[string] {
+	resolved := f.resolveService()
+	if resolved.IsErr() {
+		return result.Err[string](resolved.Err())
+	}
+	f = resolved.Value()
+
 	// Validate port
 	if strings.Contains(f.port, ":") {
 		if f.protocol.Focused() == ProtocolBoth {
@@ -243,6 +552,11 @@ func (f RuleForm) BuildUfwCommand() result.Result[string] {
 			return result.Err[string](fmt.Errorf("invalid port range: %s", f.port))
 		}
 
+	} else if f.port == "" {
+		// a rule for all ports has to be narrowed down by an address
+		if f.sourceIP == "" && f.destinationIP == "" {
+			return result.Err[string](fmt.Errorf("port can only be left empty for a rule with a source or destination IP"))
+		}
 	} else {
 		portNum, err := strconv.Atoi(f.port)
 		if err != nil || portNum < 1 || portNum > 65535 {
@@ -250,47 +564,57 @@ func (f RuleForm) BuildUfwCommand() result.Result[string] {
 		}
 	}
 
-	// Start building the command
-	parts := []string{"sudo", "ufw", string(f.action.Focused())}
+	// Start building the command, ufw takes the insert position after the route keyword
+	parts := []string{"sudo", "ufw"}
+	if f.dir.Focused() == DirectionRoute {
+		parts = append(parts, "route")
+	}
+	if f.position > 0 {
+		parts = append(parts, "insert", strconv.Itoa(f.position))
+	}
+	parts = append(parts, string(f.action.Focused()))
 
 	// Direction-specific parts
+	var source, destination string
 	switch f.dir.Focused() {
 	case DirectionIn:
 		if f.interface_.Focused() != "" {
 			parts = append(parts, "in", "on", f.interface_.Focused())
 		}
-
-		if f.sourceIP != "" {
-			if _, _, err := net.ParseCIDR(f.sourceIP); err != nil {
-				if net.ParseIP(f.sourceIP) == nil {
-					return result.Err[string](fmt.Errorf("invalid source IP: %s", f.sourceIP))
-				}
-			}
-			parts = append(parts, "from", f.sourceIP)
-		} else {
-			parts = append(parts, "from", "any")
-		}
-		parts = append(parts, "to", "any")
+		source = f.sourceIP
 	case DirectionOut:
-		parts = append(parts, "from", "any")
-		if f.destinationIP != "" {
-			if _, _, err := net.ParseCIDR(f.destinationIP); err != nil {
-				if net.ParseIP(f.destinationIP) == nil {
-					return result.Err[string](fmt.Errorf("invalid destination IP: %s", f.destinationIP))
-				}
-			}
-			parts = append(parts, "to", f.destinationIP)
-		} else {
-			parts = append(parts, "to", "any")
+		destination = f.destinationIP
+	case DirectionRoute:
+		if f.interface_.Focused() != "" {
+			parts = append(parts, "in", "on", f.interface_.Focused())
+		}
+		if f.interfaceOut.Focused() != "" {
+			parts = append(parts, "out", "on", f.interfaceOut.Focused())
 		}
+		source, destination = f.sourceIP, f.destinationIP
 	default:
 		return result.Err[string](fmt.Errorf("invalid direction"))
 	}
+	if source == "" {
+		source = "any"
+	} else if !validAddress(source) {
+		return result.Err[string](fmt.Errorf("invalid source IP: %s", source))
+	}
+	if destination == "" {
+		destination = "any"
+	} else if !validAddress(destination) {
+		return result.Err[string](fmt.Errorf("invalid destination IP: %s", destination))
+	}
+	parts = append(parts, "from", source, "to", destination)
 
 	// Port and protocol
-	if f.protocol.Focused() == ProtocolBoth {
+	switch {
+	case f.port == "" && f.protocol.Focused() == ProtocolBoth:
+	case f.port == "":
+		parts = append(parts, "proto", string(f.protocol.Focused()))
+	case f.protocol.Focused() == ProtocolBoth:
 		parts = append(parts, "port", f.port)
-	} else {
+	default:
 		parts = append(parts, "port", f.port, "proto", string(f.protocol.Focused()))
 	}
 
@@ -3,8 +3,9 @@ package createrule
 type Direction string
 
 const (
-	DirectionIn  Direction = "in"
-	DirectionOut Direction = "out"
+	DirectionIn    Direction = "in"
+	DirectionOut   Direction = "out"
+	DirectionRoute Direction = "route" // forwarded through this host
 )
 
-var directions = []Direction{DirectionIn, DirectionOut}
+var directions = []Direction{DirectionIn, DirectionOut, DirectionRoute}
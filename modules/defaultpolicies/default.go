@@ -2,8 +2,10 @@ package defaultpolicies
 
 import (
 	"fmt"
+	"fwtui/domain/notification"
 	"fwtui/domain/ufw"
 	"fwtui/utils/focusablelist"
+	"fwtui/utils/result"
 	"fwtui/utils/teacmd"
 	"strings"
 
@@ -19,32 +21,61 @@ const (
 	DirectionRouted Direction = "routed"
 )
 
-var directions = []Direction{DirectionIn, DirectionOut, DirectionRouted}
-
 type Action string
 
 const (
-	ActionAllow  Action = "allow"
-	ActionDeny   Action = "deny"
-	ActionReject Action = "reject"
+	ActionAllow    Action = "allow"
+	ActionDeny     Action = "deny"
+	ActionReject   Action = "reject"
+	ActionDisabled Action = "disabled"
 )
 
 var actions = []Action{ActionAllow, ActionDeny, ActionReject}
 
+// routedActions has "disabled" on top, ufw reports it for the routed direction while IP forwarding
+// is off.
+var routedActions = []Action{ActionDisabled, ActionAllow, ActionDeny, ActionReject}
+
+type field string
+
+const (
+	fieldIncoming     field = "incoming"
+	fieldOutgoing     field = "outgoing"
+	fieldRouted       field = "routed"
+	fieldForwardingV4 field = "forwarding_v4"
+	fieldForwardingV6 field = "forwarding_v6"
+)
+
 type DefaultModule struct {
-	fields *focusablelist.SelectableList[Direction]
+	fields *focusablelist.SelectableList[field]
+
+	current    DefaultPolicies
+	forwarding result.Result[ufw.Forwarding]
 
 	actionIncoming *focusablelist.SelectableList[Action]
 	actionOutgoing *focusablelist.SelectableList[Action]
 	actionRouted   *focusablelist.SelectableList[Action]
+	forwardingV4   *focusablelist.SelectableList[bool]
+	forwardingV6   *focusablelist.SelectableList[bool]
 }
 
-func Init(policies DefaultPolicies) DefaultModule {
+func Init(policies DefaultPolicies, forwarding result.Result[ufw.Forwarding]) DefaultModule {
+	fields := []field{fieldIncoming, fieldOutgoing, fieldRouted}
+	var current ufw.Forwarding
+	if !forwarding.IsErr() {
+		fields = append(fields, fieldForwardingV4, fieldForwardingV6)
+		current = forwarding.Value()
+	}
+
 	return DefaultModule{
-		fields:         focusablelist.FromList(directions),
+		fields:         focusablelist.FromList(fields),
+		current:        policies,
+		forwarding:     forwarding,
 		actionIncoming: focusablelist.FromList(actions).Focus(Action(policies.Incoming)),
 		actionOutgoing: focusablelist.FromList(actions).Focus(Action(policies.Outgoing)),
-		actionRouted:   focusablelist.FromList(actions).Focus(Action(policies.Routed)),
+		actionRouted:   focusablelist.FromList(routedActions).Focus(Action(policies.Routed)),
+		forwardingV4:   focusablelist.FromList([]bool{false, true}).Focus(current.V4),
+		forwardingV6:   focusablelist.FromList([]bool{false, true}).Focus(current.V6),
 	}
 }
 
@@ -63,32 +94,24 @@ func (module DefaultModule) UpdateDefaultsModule(msg tea.Msg) (DefaultModule, te
 			mod.fields.Prev()
 		case "down":
 			mod.fields.Next()
-		case "left":
-			switch mod.fields.Focused() {
-			case DirectionIn:
-				mod.actionIncoming.Prev()
-			case DirectionOut:
-				mod.actionOutgoing.Prev()
-			case DirectionRouted:
-				mod.actionRouted.Prev()
-			}
-		case "right":
-			switch mod.fields.Focused() {
-			case DirectionIn:
-				mod.actionIncoming.Next()
-			case DirectionOut:
-				mod.actionOutgoing.Next()
-			case DirectionRouted:
-				mod.actionRouted.Next()
-			}
+		case "left", "right":
+			mod.change(key == "right")
 
 		case "enter":
+			commands, err := mod.changedCommands()
+			if err != nil {
+				return mod, notification.CreateCmd(err.Error())
+			}
+			if len(commands) == 0 {
+				return mod, func() tea.Msg {
+					return DefaultPolicyEscMsg{}
+				}
+			}
 			return mod, teacmd.RunOsCmdAndAfter(func() string {
-				return strings.Join([]string{
-					ufw.SetDefaultPolicy("incoming", string(mod.actionIncoming.Focused())),
-					ufw.SetDefaultPolicy("outgoing", string(mod.actionOutgoing.Focused())),
-					ufw.SetDefaultPolicy("routed", string(mod.actionRouted.Focused())),
-				}, "\n")
+				outputs := lo.Map(commands, func(command func() string, _ int) string {
+					return command()
+				})
+				return strings.Join(outputs, "\n")
 			}, func(s string) tea.Msg {
 				return DefaultPoliciesUpdatedMsg{Output: s}
 			})
@@ -102,6 +125,77 @@ func (module DefaultModule) UpdateDefaultsModule(msg tea.Msg) (DefaultModule, te
 	return mod, nil
 }
 
+type cyclic interface {
+	Next()
+	Prev()
+}
+
+// change moves the focused field to its next or previous value. Disabling routing turns both
+// forwarding switches off, otherwise a field only changes itself: forwarding may also be switched on
+// outside of ufw's sysctl.conf, so the switches don't tell whether ufw routes.
+func (module *DefaultModule) change(forward bool) {
+	step := func(list cyclic) {
+		if forward {
+			list.Next()
+		} else {
+			list.Prev()
+		}
+	}
+
+	switch module.fields.Focused() {
+	case fieldIncoming:
+		step(module.actionIncoming)
+	case fieldOutgoing:
+		step(module.actionOutgoing)
+	case fieldRouted:
+		step(module.actionRouted)
+		if module.actionRouted.Focused() == ActionDisabled && !module.forwarding.IsErr() {
+			module.forwardingV4.Focus(false)
+			module.forwardingV6.Focus(false)
+		}
+	case fieldForwardingV4, fieldForwardingV6:
+		step(lo.Ternary(module.fields.Focused() == fieldForwardingV4, module.forwardingV4, module.forwardingV6))
+	}
+}
+
+// changedCommands returns the commands for the values that differ from the current configuration,
+// so that untouched policies are not rewritten. Disabling routing fails when the forwarding switches
+// it turns off couldn't be read.
+func (module DefaultModule) changedCommands() ([]func() string, error) {
+	if module.actionRouted.Focused() == ActionDisabled && module.current.Routed != string(ActionDisabled) && module.forwarding.IsErr() {
+		return nil, fmt.Errorf("routing can't be disabled while IP forwarding is unavailable: %w", module.forwarding.Err())
+	}
+
+	var commands []func() string
+	for _, policy := range []struct {
+		direction Direction
+		current   string
+		selected  Action
+	}{
+		{DirectionIn, module.current.Incoming, module.actionIncoming.Focused()},
+		{DirectionOut, module.current.Outgoing, module.actionOutgoing.Focused()},
+		{DirectionRouted, module.current.Routed, module.actionRouted.Focused()},
+	} {
+		// there is no ufw command for "disabled", it follows from turning forwarding off
+		if string(policy.selected) == policy.current || policy.selected == ActionDisabled {
+			continue
+		}
+		commands = append(commands, func() string {
+			return ufw.SetDefaultPolicy(string(policy.direction), string(policy.selected))
+		})
+	}
+
+	if !module.forwarding.IsErr() {
+		selected := ufw.Forwarding{V4: module.forwardingV4.Focused(), V6: module.forwardingV6.Focused()}
+		if selected != module.forwarding.Value() {
+			commands = append(commands, func() string {
+				return ufw.SetForwarding(selected)
+			})
+		}
+	}
+	return commands, nil
+}
+
 func (module DefaultModule) ViewSetDefaults() string {
 	var lines []string
 	lines = append(lines, "Default Rules:")
@@ -111,15 +205,21 @@ func (module DefaultModule) ViewSetDefaults() string {
 		var fieldString string
 
 		switch field {
-		case DirectionIn:
+		case fieldIncoming:
 			value = string(module.actionIncoming.Focused())
 			fieldString = "Incoming"
-		case DirectionOut:
+		case fieldOutgoing:
 			value = string(module.actionOutgoing.Focused())
 			fieldString = "Outgoing"
-		case DirectionRouted:
+		case fieldRouted:
 			value = string(module.actionRouted.Focused())
 			fieldString = "Routed"
+		case fieldForwardingV4:
+			value = lo.Ternary(module.forwardingV4.Focused(), "on", "off")
+			fieldString = "IPv4 forwarding"
+		case fieldForwardingV6:
+			value = lo.Ternary(module.forwardingV6.Focused(), "on", "off")
+			fieldString = "IPv6 forwarding"
 		}
 
 		prefix := lo.Ternary(module.fields.Focused() == field, "> ", "  ")
@@ -127,7 +227,13 @@ func (module DefaultModule) ViewSetDefaults() string {
 		lines = append(lines, line)
 	}
 
+	if module.forwarding.IsErr() {
+		lines = append(lines, "", fmt.Sprintf("IP forwarding unavailable: %s", module.forwarding.Err()))
+	} else if module.actionRouted.Focused() != ActionDisabled && !module.forwardingV4.Focused() && !module.forwardingV6.Focused() {
+		lines = append(lines, "", "ufw routes only while IP forwarding is on, here or in /etc/sysctl.conf")
+	}
+
 	output := strings.Join(lines, "\n")
-	output += "\n\n↑↓ to navigate, ←→ to change selection, Enter to submit, Esc to cancel"
+	output += "\n\n↑↓ to navigate, ←→ to change selection, Enter to apply changes, Esc to cancel"
 	return output
 }
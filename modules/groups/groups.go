@@ -0,0 +1,297 @@
+package groups
+
+import (
+	"fmt"
+	"fwtui/domain/addrgroups"
+	"fwtui/domain/notification"
+	"fwtui/domain/ufw"
+	"fwtui/modules/shared/confirmation"
+	"fwtui/utils/focusablelist"
+	"fwtui/utils/multiselect"
+	stringsext "fwtui/utils/strings"
+	"fwtui/utils/teacmd"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/samber/lo"
+)
+
+// MODEL
+
+type Field string
+
+const (
+	FieldName    Field = "Name"
+	FieldMembers Field = "Members"
+)
+
+type groupForm struct {
+	existing      bool // the name of an existing group is fixed, its rules are tagged with it
+	name          string
+	members       string
+	selectedField *focusablelist.SelectableList[Field]
+}
+
+// pendingSync holds the rule changes waiting for confirmation after a group was saved or deleted.
+type pendingSync struct {
+	add    []ufw.Rule
+	remove []ufw.Rule
+}
+
+type GroupsModule struct {
+	rules  []ufw.Rule
+	groups multiselect.MultiSelectableList[addrgroups.Group]
+	err    error
+
+	form    *groupForm
+	dialog  *confirmation.ConfirmDialog
+	pending *pendingSync
+	// deleting is the group whose removal waits for confirmation
+	deleting *addrgroups.Group
+}
+
+func Init(rules []ufw.Rule) GroupsModule {
+	groups, err := addrgroups.Load()
+	return GroupsModule{rules: rules, groups: multiselect.FromList(groups), err: err}
+}
+
+// UPDATE
+
+type GroupsEscMsg struct{}
+
+// GroupsSyncedMsg reports the updated rules so that the firewall state gets reloaded.
+type GroupsSyncedMsg struct{ Output string }
+
+func (module GroupsModule) UpdateGroupsModule(msg tea.Msg) (GroupsModule, tea.Cmd) {
+	m := module
+
+	if m.dialog != nil {
+		newDialog, _, outMsg := m.dialog.UpdateDialog(msg)
+		m.dialog = newDialog
+		switch outMsg {
+		case confirmation.ConfirmationDialogYes:
+			m.dialog = nil
+			if m.deleting != nil {
+				return m.delete(*m.deleting)
+			}
+			sync, rules := *m.pending, m.rules
+			m.pending = nil
+			return m, teacmd.RunOsCmdAndAfter(func() string {
+				return ufw.ApplyRuleChanges(rules, sync.remove, sync.add)
+			}, func(s string) tea.Msg {
+				return GroupsSyncedMsg{Output: s}
+			})
+		case confirmation.ConfirmationDialogNo, confirmation.ConfirmationDialogEsc:
+			m.dialog, m.pending, m.deleting = nil, nil, nil
+		}
+		return m, nil
+	}
+
+	if m.form != nil {
+		return m.updateForm(msg)
+	}
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		key := msg.String()
+		switch key {
+		case "up", "k":
+			m.groups.Prev()
+		case "down", "j":
+			m.groups.Next()
+		case "a":
+			if m.err == nil {
+				m.form = &groupForm{selectedField: focusablelist.FromList([]Field{FieldName, FieldMembers})}
+			}
+		case "e", "enter":
+			if m.err != nil || len(m.groups.Items) == 0 {
+				return m, nil
+			}
+			group := m.groups.FocusedItem()
+			m.form = &groupForm{
+				existing:      true,
+				name:          group.Name,
+				members:       strings.Join(group.Members, ", "),
+				selectedField: focusablelist.FromList([]Field{FieldMembers}),
+			}
+		case "x":
+			if m.err != nil || len(m.groups.Items) == 0 {
+				return m, nil
+			}
+			group := m.groups.FocusedItem()
+			_, remove := addrgroups.Sync(m.rules, addrgroups.Group{Name: group.Name})
+			m.deleting = &group
+			m.dialog = confirmation.NewConfirmDialog(lo.Ternary(len(remove) > 0,
+				fmt.Sprintf("Delete group %s and the %d rules created for it?", group.Name, len(remove)),
+				fmt.Sprintf("Delete group %s?", group.Name)))
+		case "esc":
+			return m, func() tea.Msg {
+				return GroupsEscMsg{}
+			}
+		}
+	}
+	return m, nil
+}
+
+func (module GroupsModule) updateForm(msg tea.Msg) (GroupsModule, tea.Cmd) {
+	m := module
+	form := *m.form
+	m.form = &form
+
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	key := keyMsg.String()
+	switch key {
+	case "up":
+		form.selectedField.Prev()
+	case "down":
+		form.selectedField.Next()
+	case "backspace":
+		switch form.selectedField.Focused() {
+		case FieldName:
+			form.name = stringsext.TrimLastChar(form.name)
+		case FieldMembers:
+			form.members = stringsext.TrimLastChar(form.members)
+		}
+	case "enter":
+		group := addrgroups.Group{Name: strings.TrimSpace(form.name), Members: addrgroups.ParseMembers(form.members)}
+		return m.save(group, form.existing)
+	case "esc":
+		m.form = nil
+	default:
+		switch form.selectedField.Focused() {
+		case FieldName:
+			form.name += key
+		case FieldMembers:
+			form.members += key
+		}
+	}
+	return m, nil
+}
+
+// save writes the group to the registry and offers to bring the rules created for it in line with
+// the new members.
+func (module GroupsModule) save(group addrgroups.Group, existing bool) (GroupsModule, tea.Cmd) {
+	m := module
+	if err := group.Validate(); err != nil {
+		return m, notification.CreateCmd(err.Error())
+	}
+	_, taken := addrgroups.Find(m.groups.Items, group.Name)
+	if taken && !existing {
+		return m, notification.CreateCmd(fmt.Sprintf("group %s already exists", group.Name))
+	}
+
+	groups := lo.Reject(m.groups.Items, func(g addrgroups.Group, _ int) bool {
+		return g.Name == group.Name
+	})
+	groups = append(groups, group)
+	if err := addrgroups.Save(groups); err != nil {
+		return m, notification.CreateCmd(err.Error())
+	}
+	m.form = nil
+	m.reload()
+
+	add, remove := addrgroups.Sync(m.rules, group)
+	if len(add) == 0 && len(remove) == 0 {
+		return m, notification.CreateCmd(fmt.Sprintf("Group %s saved", group.Name))
+	}
+	m.pending = &pendingSync{add: add, remove: remove}
+	m.dialog = confirmation.NewConfirmDialog(fmt.Sprintf("Group %s saved. Sync the rules created for it?\n\n%s",
+		group.Name, strings.Join(syncLines(add, remove), "\n")))
+	return m, nil
+}
+
+func (module GroupsModule) delete(group addrgroups.Group) (GroupsModule, tea.Cmd) {
+	m := module
+	m.deleting = nil
+	groups := lo.Reject(m.groups.Items, func(g addrgroups.Group, _ int) bool {
+		return g.Name == group.Name
+	})
+	if err := addrgroups.Save(groups); err != nil {
+		return m, notification.CreateCmd(err.Error())
+	}
+	m.reload()
+
+	_, remove := addrgroups.Sync(m.rules, addrgroups.Group{Name: group.Name})
+	if len(remove) == 0 {
+		return m, notification.CreateCmd(fmt.Sprintf("Group %s deleted", group.Name))
+	}
+	rules := m.rules
+	return m, teacmd.RunOsCmdAndAfter(func() string {
+		return ufw.ApplyRuleChanges(rules, remove, nil)
+	}, func(s string) tea.Msg {
+		return GroupsSyncedMsg{Output: s}
+	})
+}
+
+func (m *GroupsModule) reload() {
+	groups, err := addrgroups.Load()
+	m.err = err
+	m.groups.SetItems(groups)
+	if m.groups.Focused < 0 {
+		m.groups.Focused = 0
+	}
+}
+
+func syncLines(add, remove []ufw.Rule) []string {
+	var lines []string
+	for _, rule := range remove {
+		lines = append(lines, fmt.Sprintf("  - %s", rule.Summary()))
+	}
+	for _, rule := range add {
+		lines = append(lines, fmt.Sprintf("  + %s", rule.Summary()))
+	}
+	return lines
+}
+
+// VIEW
+
+func (module GroupsModule) ViewGroups() string {
+	if module.dialog != nil {
+		return module.dialog.ViewDialog()
+	}
+	if module.form != nil {
+		return module.form.view()
+	}
+	if module.err != nil {
+		return fmt.Sprintf("Failed to read address groups: %s\n\nEsc to go back", module.err)
+	}
+
+	lines := []string{"Address groups (use them as @name in the source or destination of a rule):", ""}
+	module.groups.ForEach(func(group addrgroups.Group, _ int, isFocused, _ bool) {
+		prefix := lo.Ternary(isFocused, ">", " ")
+		rules := lo.CountBy(module.rules, func(r ufw.Rule) bool {
+			name, ok := addrgroups.FromComment(r.Comment)
+			return ok && name == group.Name
+		})
+		lines = append(lines, fmt.Sprintf("%s %-20s | %-60s | %d rules", prefix, group.Name, strings.Join(group.Members, ", "), rules))
+	})
+	if len(module.groups.Items) == 0 {
+		lines = append(lines, "  No address groups")
+	}
+
+	output := strings.Join(lines, "\n")
+	output += "\n\n↑↓ to navigate, a to add, e to edit, x to delete, Esc to cancel"
+	return output
+}
+
+func (form groupForm) view() string {
+	lines := []string{lo.Ternary(form.existing, "Edit address group "+form.name+":", "New address group:"), ""}
+	for _, field := range form.selectedField.GetItems() {
+		var value, label string
+		switch field {
+		case FieldName:
+			value, label = form.name, "Name"
+		case FieldMembers:
+			value, label = form.members, "Members (comma separated addresses and networks)"
+		}
+		prefix := lo.Ternary(form.selectedField.Focused() == field, "> ", "  ")
+		lines = append(lines, fmt.Sprintf("%s%s: %s", prefix, label, value))
+	}
+
+	output := strings.Join(lines, "\n")
+	output += "\n\n↑↓ to navigate, type to edit, Enter to save, Esc to cancel"
+	return output
+}
@@ -0,0 +1,84 @@
+package lint
+
+import (
+	"fmt"
+	"fwtui/domain/ufw"
+	"fwtui/utils/multiselect"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/samber/lo"
+)
+
+// MODEL
+
+type LintModule struct {
+	rules    map[int]ufw.Rule
+	findings multiselect.MultiSelectableList[ufw.Finding]
+}
+
+func Init(rules []ufw.Rule, ipv6 bool) LintModule {
+	return LintModule{
+		rules: lo.KeyBy(rules, func(r ufw.Rule) int {
+			return r.Number
+		}),
+		findings: multiselect.FromList(ufw.Analyze(rules, ipv6)),
+	}
+}
+
+// UPDATE
+
+type LintEscMsg struct{}
+
+// LintShowRuleMsg asks to focus the offending rule of a finding in the rule list.
+type LintShowRuleMsg struct{ Number int }
+
+func (module LintModule) UpdateLintModule(msg tea.Msg) (LintModule, tea.Cmd) {
+	m := module
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		key := msg.String()
+		switch key {
+		case "up", "k":
+			m.findings.Prev()
+		case "down", "j":
+			m.findings.Next()
+		case "enter":
+			if len(m.findings.Items) == 0 {
+				return m, nil
+			}
+			finding := m.findings.FocusedItem()
+			return m, func() tea.Msg {
+				return LintShowRuleMsg{Number: finding.Rules[len(finding.Rules)-1]}
+			}
+		case "esc":
+			return m, func() tea.Msg {
+				return LintEscMsg{}
+			}
+		}
+	}
+	return m, nil
+}
+
+// VIEW
+
+func (module LintModule) ViewLint() string {
+	lines := []string{"Rule findings:"}
+	module.findings.ForEach(func(f ufw.Finding, _ int, isFocused, _ bool) {
+		prefix := lo.Ternary(isFocused, ">", " ")
+		lines = append(lines, fmt.Sprintf("%s [%s] %s", prefix, f.Kind, f.Message))
+	})
+
+	if len(module.findings.Items) == 0 {
+		lines = append(lines, "  No findings, the rules look consistent")
+	} else {
+		lines = append(lines, "", "Rules involved:")
+		for _, n := range module.findings.FocusedItem().Rules {
+			lines = append(lines, "  "+module.rules[n].Line)
+		}
+	}
+
+	output := strings.Join(lines, "\n")
+	output += "\n\n↑↓ to navigate, Enter to show the rule in the rule list, Esc to cancel"
+	return output
+}
@@ -0,0 +1,200 @@
+package listening
+
+import (
+	"fmt"
+	"fwtui/domain/sockets"
+	"fwtui/modules/createrule"
+	"fwtui/modules/shared/state"
+	"fwtui/utils/multiselect"
+	"net"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/samber/lo"
+)
+
+// MODEL
+
+type Exposure string
+
+const (
+	ExposureExposed   Exposure = "exposed"
+	ExposureBlocked   Exposure = "blocked"
+	ExposureLocalOnly Exposure = "local-only"
+)
+
+type entry struct {
+	socket   sockets.Socket
+	exposure Exposure
+	reason   string
+}
+
+type ListeningModule struct {
+	state   state.State
+	entries multiselect.MultiSelectableList[entry]
+	loading bool
+	err     error
+}
+
+func Init(st state.State) (ListeningModule, tea.Cmd) {
+	return ListeningModule{state: st, loading: true}, loadSocketsCmd()
+}
+
+func loadSocketsCmd() tea.Cmd {
+	return func() tea.Msg {
+		list, err := sockets.ListListening()
+		return socketsLoadedMsg{sockets: list, err: err}
+	}
+}
+
+// UPDATE
+
+type socketsLoadedMsg struct {
+	sockets []sockets.Socket
+	err     error
+}
+
+type ListeningEscMsg struct{}
+
+// ListeningCreateRuleMsg asks for the rule form prefilled for the focused socket.
+type ListeningCreateRuleMsg struct {
+	Prefill createrule.Prefill
+}
+
+func (module ListeningModule) UpdateListeningModule(msg tea.Msg) (ListeningModule, tea.Cmd) {
+	m := module
+	switch msg := msg.(type) {
+	case socketsLoadedMsg:
+		m.loading = false
+		m.err = msg.err
+		m.entries = multiselect.FromList(lo.Map(msg.sockets, func(s sockets.Socket, _ int) entry {
+			exposure, reason := Classify(s, m.state)
+			return entry{socket: s, exposure: exposure, reason: reason}
+		}))
+		return m, nil
+	case tea.KeyMsg:
+		key := msg.String()
+		switch key {
+		case "up", "k":
+			m.entries.Prev()
+		case "down", "j":
+			m.entries.Next()
+		case "r":
+			m.loading = true
+			return m, loadSocketsCmd()
+		case "a", "d":
+			if len(m.entries.Items) == 0 {
+				return m, nil
+			}
+			socket := m.entries.FocusedItem().socket
+			action := lo.Ternary(key == "a", createrule.ActionAllow, createrule.ActionDeny)
+			return m, func() tea.Msg {
+				return ListeningCreateRuleMsg{Prefill: createrule.Prefill{
+					Port:     strconv.Itoa(socket.Port),
+					Protocol: createrule.Protocol(socket.Protocol),
+					Action:   action,
+				}}
+			}
+		case "esc":
+			return m, func() tea.Msg {
+				return ListeningEscMsg{}
+			}
+		}
+	}
+	return m, nil
+}
+
+// Classify tells whether traffic from outside reaches the socket, walking the incoming rules in
+// order and falling back to the default incoming policy.
+func Classify(socket sockets.Socket, st state.State) (Exposure, string) {
+	if socket.IsLocalOnly() {
+		return ExposureLocalOnly, "bound to loopback"
+	}
+	if !st.Enabled {
+		return ExposureExposed, "ufw is disabled"
+	}
+
+	// a wildcard IPv6 socket is dual-stack and receives IPv4 traffic as well
+	families := []bool{socket.V6}
+	if socket.V6 && socket.IsWildcard() {
+		families = []bool{false, true}
+	}
+
+	var exposure Exposure
+	var reason string
+	for _, v6 := range families {
+		exposure, reason = classifyFamily(socket, st, v6)
+		if exposure == ExposureExposed {
+			break
+		}
+	}
+	return exposure, reason
+}
+
+func classifyFamily(socket sockets.Socket, st state.State, v6 bool) (Exposure, string) {
+	for _, rule := range st.Rules {
+		if rule.Route || rule.Direction != "in" || rule.V6 != v6 {
+			continue
+		}
+		if !rule.MatchesPort(socket.Port, socket.Protocol) || !destinationCovers(rule.Dest, socket) {
+			continue
+		}
+		if rule.IsAllowing() {
+			if rule.Source != "any" {
+				return ExposureExposed, fmt.Sprintf("rule %d from %s", rule.Number, rule.Source)
+			}
+			return ExposureExposed, fmt.Sprintf("rule %d", rule.Number)
+		}
+		// a deny for a single source still lets everybody else through to the next rules
+		if rule.Source == "any" {
+			return ExposureBlocked, fmt.Sprintf("rule %d", rule.Number)
+		}
+	}
+
+	incoming := "deny"
+	if st.Defaults.IsOk() {
+		incoming = st.Defaults.Value().Incoming
+	}
+	if incoming == "allow" {
+		return ExposureExposed, "default policy allow"
+	}
+	return ExposureBlocked, fmt.Sprintf("default policy %s", incoming)
+}
+
+func destinationCovers(dest string, socket sockets.Socket) bool {
+	if dest == "any" || socket.IsWildcard() {
+		return true
+	}
+	if _, network, err := net.ParseCIDR(dest); err == nil {
+		return network.Contains(socket.Address)
+	}
+	return net.ParseIP(dest).Equal(socket.Address)
+}
+
+// VIEW
+
+func (module ListeningModule) ViewListening() string {
+	if module.loading {
+		return "Reading listening sockets..."
+	}
+	if module.err != nil {
+		return fmt.Sprintf("Failed to read listening sockets: %s\n\nEsc to go back", module.err)
+	}
+
+	lines := []string{"Listening sockets:", ""}
+	lines = append(lines, fmt.Sprintf("   %-5s | %-40s | %-25s | %-10s | %s", "Proto", "Address", "Process", "Status", "Reason"))
+	module.entries.ForEach(func(e entry, _ int, isFocused, _ bool) {
+		prefix := lo.Ternary(isFocused, ">", " ")
+		process := lo.Ternary(e.socket.Process != "", fmt.Sprintf("%s (%d)", e.socket.Process, e.socket.PID), "-")
+		lines = append(lines, fmt.Sprintf("%s  %-5s | %-40s | %-25s | %-10s | %s",
+			prefix, e.socket.Protocol, e.socket.BindAddress(), process, e.exposure, e.reason))
+	})
+	if len(module.entries.Items) == 0 {
+		lines = append(lines, "  No listening sockets")
+	}
+
+	output := strings.Join(lines, "\n")
+	output += "\n\n↑↓ to navigate, a to allow port, d to deny port, r to refresh, Esc to cancel"
+	return output
+}
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"fmt"
+	"fwtui/domain/ufw"
+	"fwtui/utils/focusablelist"
+	"fwtui/utils/teacmd"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/samber/lo"
+)
+
+var descriptions = map[string]string{
+	ufw.LoggingOff:    "No logging",
+	ufw.LoggingLow:    "Blocked packets not matching the default policy, and packets matching logged rules",
+	ufw.LoggingMedium: "Low, plus allowed packets not matching the policy, invalid packets and all new connections",
+	ufw.LoggingHigh:   "Medium without rate limiting, plus all packets with rate limiting",
+	ufw.LoggingFull:   "High without rate limiting",
+}
+
+type LoggingModule struct {
+	current string
+	levels  *focusablelist.SelectableList[string]
+}
+
+func Init(current string) LoggingModule {
+	return LoggingModule{
+		current: current,
+		levels:  focusablelist.FromList(ufw.LoggingLevels).Focus(current),
+	}
+}
+
+// UPDATE
+
+type LoggingUpdatedMsg struct{ Output string }
+type LoggingEscMsg struct{}
+
+func (module LoggingModule) UpdateLoggingModule(msg tea.Msg) (LoggingModule, tea.Cmd) {
+	mod := module
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		key := msg.String()
+		switch key {
+		case "up", "k":
+			mod.levels.Prev()
+		case "down", "j":
+			mod.levels.Next()
+		case "enter":
+			level := mod.levels.Focused()
+			if level == mod.current {
+				return mod, func() tea.Msg {
+					return LoggingEscMsg{}
+				}
+			}
+			return mod, teacmd.RunOsCmdAndAfter(func() string {
+				return ufw.SetLogging(level)
+			}, func(s string) tea.Msg {
+				return LoggingUpdatedMsg{Output: s}
+			})
+		case "esc":
+			return mod, func() tea.Msg {
+				return LoggingEscMsg{}
+			}
+		}
+	}
+	return mod, nil
+}
+
+// VIEW
+
+func (module LoggingModule) ViewLogging() string {
+	lines := []string{fmt.Sprintf("Logging level (current: %s):", module.current)}
+	module.levels.ForEach(func(level string, _ int, isFocused bool) {
+		prefix := lo.Ternary(isFocused, ">", " ")
+		current := lo.Ternary(level == module.current, "*", " ")
+		lines = append(lines, fmt.Sprintf("%s%s %-7s %s", prefix, current, level, descriptions[level]))
+	})
+
+	output := strings.Join(lines, "\n")
+	output += "\n\n↑↓ to navigate, Enter to apply, Esc to cancel"
+	return output
+}
@@ -0,0 +1,253 @@
+package logs
+
+import (
+	"fmt"
+	"fwtui/domain/ufwlog"
+	"fwtui/modules/createrule"
+	"fwtui/utils/multiselect"
+	stringsext "fwtui/utils/strings"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/samber/lo"
+)
+
+// MODEL
+
+const maxEntries = 1000
+const pollInterval = 2 * time.Second
+const visibleEntries = 20
+
+type LogsModule struct {
+	generation int // every opened log view polls with its own generation so that ticks of a closed view die out
+	all        []ufwlog.Entry
+	entries    multiselect.MultiSelectableList[ufwlog.Entry]
+	follower   *ufwlog.Follower // shared by the reads of one view, only one of them runs at a time
+	source     string
+	err        error
+	loading    bool
+
+	filter      string
+	filterInput string
+	editing     bool
+	follow      bool
+}
+
+// Init opens the log view, previous is the module of the view opened before so that its ticks are
+// told apart.
+func Init(previous LogsModule) (LogsModule, tea.Cmd) {
+	m := LogsModule{generation: previous.generation + 1, follower: &ufwlog.Follower{}, loading: true, follow: true}
+	return m, m.readCmd()
+}
+
+// readCmd reads only what was logged since the last poll, reading and parsing the end of the log
+// again every two seconds is costly.
+func (m LogsModule) readCmd() tea.Cmd {
+	gen, follower := m.generation, m.follower
+	return func() tea.Msg {
+		entries, err := follower.Read(time.Time{}, maxEntries)
+		return logsReadMsg{generation: gen, entries: entries, source: follower.Source(), err: err}
+	}
+}
+
+// UPDATE
+
+type logsReadMsg struct {
+	generation int
+	entries    []ufwlog.Entry
+	source     string
+	err        error
+}
+
+type logsTickMsg struct{ generation int }
+
+type LogsEscMsg struct{}
+
+// LogsCreateRuleMsg asks for the rule form prefilled from a log entry.
+type LogsCreateRuleMsg struct {
+	Prefill createrule.Prefill
+}
+
+func (module LogsModule) UpdateLogsModule(msg tea.Msg) (LogsModule, tea.Cmd) {
+	m := module
+	switch msg := msg.(type) {
+	case logsReadMsg:
+		if msg.generation != m.generation {
+			return m, nil
+		}
+		m.loading = false
+		m.err = msg.err
+		m.source = msg.source
+		m.all = append(m.all, msg.entries...)
+		if len(m.all) > maxEntries {
+			m.all = m.all[len(m.all)-maxEntries:]
+		}
+		m = m.applyFilter()
+		return m, m.tick()
+	case logsTickMsg:
+		if msg.generation != m.generation {
+			return m, nil
+		}
+		return m, m.readCmd()
+	case tea.KeyMsg:
+		key := msg.String()
+		if m.editing {
+			switch key {
+			case "enter":
+				m.editing = false
+				m.filter = m.filterInput
+				m = m.applyFilter()
+			case "esc":
+				m.editing = false
+			case "backspace":
+				m.filterInput = stringsext.TrimLastChar(m.filterInput)
+			default:
+				if len(key) == 1 || key == " " {
+					m.filterInput += key
+				}
+			}
+			return m, nil
+		}
+
+		switch key {
+		case "up", "k":
+			m.follow = false
+			m.entries.Prev()
+		case "down", "j":
+			m.entries.Next()
+			m.follow = m.entries.FocusedIndex() == len(m.entries.Items)-1
+		case "G", "end":
+			m.follow = true
+			m.entries.Focused = max(len(m.entries.Items)-1, 0)
+		case "/":
+			m.editing = true
+			m.filterInput = m.filter
+		case "c":
+			m.filter = ""
+			m = m.applyFilter()
+		case "a", "d":
+			if len(m.entries.Items) == 0 {
+				return m, nil
+			}
+			prefill := prefillFromEntry(m.entries.FocusedItem(), lo.Ternary(key == "a", createrule.ActionAllow, createrule.ActionDeny))
+			return m, func() tea.Msg {
+				return LogsCreateRuleMsg{Prefill: prefill}
+			}
+		case "esc":
+			return m, func() tea.Msg {
+				return LogsEscMsg{}
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m LogsModule) tick() tea.Cmd {
+	return tea.Tick(pollInterval, func(time.Time) tea.Msg {
+		return logsTickMsg{generation: m.generation}
+	})
+}
+
+func (m LogsModule) applyFilter() LogsModule {
+	focused := m.entries.FocusedIndex()
+	filtered := lo.Filter(m.all, func(e ufwlog.Entry, _ int) bool {
+		return Matches(e, m.filter)
+	})
+	m.entries = multiselect.FromList(filtered)
+	if m.follow {
+		m.entries.Focused = max(len(filtered)-1, 0)
+	} else {
+		m.entries.Focused = max(min(focused, len(filtered)-1), 0)
+	}
+	return m
+}
+
+// Matches checks an entry against a filter of space separated FIELD=value terms, e.g.
+// "action=BLOCK DPT=22 SRC=203.0.113.". Values match as case-insensitive substrings.
+func Matches(e ufwlog.Entry, filter string) bool {
+	for _, term := range strings.Fields(filter) {
+		field, value, found := strings.Cut(term, "=")
+		if !found {
+			if !strings.Contains(strings.ToLower(e.Raw), strings.ToLower(term)) {
+				return false
+			}
+			continue
+		}
+		if !strings.Contains(strings.ToLower(e.Field(field)), strings.ToLower(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+func prefillFromEntry(e ufwlog.Entry, action createrule.Action) createrule.Prefill {
+	prefill := createrule.Prefill{
+		Action:   action,
+		Protocol: createrule.ProtocolBoth,
+	}
+	switch strings.ToLower(e.Proto) {
+	case "tcp":
+		prefill.Protocol = createrule.ProtocolTcp
+	case "udp":
+		prefill.Protocol = createrule.ProtocolUdp
+	}
+	if e.DstPort != 0 {
+		prefill.Port = strconv.Itoa(e.DstPort)
+	}
+	switch {
+	case e.IsRouted():
+		prefill.Direction = createrule.DirectionRoute
+		prefill.SourceIP = e.Src
+		prefill.DestinationIP = e.Dst
+		prefill.Interface = e.In
+		prefill.InterfaceOut = e.Out
+	case e.IsIncoming():
+		prefill.Direction = createrule.DirectionIn
+		prefill.SourceIP = e.Src
+		prefill.Interface = e.In
+	default:
+		prefill.Direction = createrule.DirectionOut
+		prefill.DestinationIP = e.Dst
+	}
+	return prefill
+}
+
+// VIEW
+
+func (module LogsModule) ViewLogs() string {
+	if module.loading {
+		return "Reading ufw log..."
+	}
+	if module.err != nil {
+		return fmt.Sprintf("Failed to read the ufw log: %s\n\nEsc to go back", module.err)
+	}
+
+	lines := []string{fmt.Sprintf("UFW log (%s), %d of %d entries:", module.source, len(module.entries.Items), len(module.all)), ""}
+	lines = append(lines, fmt.Sprintf("   %-19s %-11s %-8s %-8s %-39s %-39s %-5s %6s %6s", "Time", "Action", "In", "Out", "Source", "Destination", "Proto", "SPT", "DPT"))
+
+	start := max(0, min(module.entries.FocusedIndex()-visibleEntries/2, len(module.entries.Items)-visibleEntries))
+	end := min(start+visibleEntries, len(module.entries.Items))
+	for i := start; i < end; i++ {
+		e := module.entries.Items[i]
+		prefix := lo.Ternary(i == module.entries.FocusedIndex(), ">", " ")
+		lines = append(lines, fmt.Sprintf("%s  %-19s %-11s %-8s %-8s %-39s %-39s %-5s %6s %6s",
+			prefix, e.Time.Format("2006-01-02 15:04:05"), e.Action, e.In, e.Out, e.Src, e.Dst, e.Proto, e.Field("SPT"), e.Field("DPT")))
+	}
+	if len(module.entries.Items) == 0 {
+		lines = append(lines, "  No entries")
+	}
+
+	output := strings.Join(lines, "\n")
+	if module.editing {
+		output += "\n\nFilter: " + module.filterInput
+		output += "\nFIELD=value terms (action, in, out, src, dst, proto, spt, dpt) or plain text, Enter to apply, Esc to cancel"
+		return output
+	}
+	if module.filter != "" {
+		output += "\n\nFilter: " + module.filter
+	}
+	output += "\n\n↑↓ to navigate, G to follow, / to filter, c to clear filter, a to allow, d to deny, Esc to cancel"
+	return output
+}
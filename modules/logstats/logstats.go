@@ -0,0 +1,241 @@
+package logstats
+
+import (
+	"fmt"
+	"fwtui/domain/ufw"
+	"fwtui/domain/ufwlog"
+	"fwtui/modules/createrule"
+	"fwtui/utils/focusablelist"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/samber/lo"
+)
+
+// MODEL
+
+// the log is parsed in full on every reload, the windows are covered by fewer entries on most hosts
+const maxEntries = 20000
+const topN = 10
+const sparklineWidth = 60
+
+type Section string
+
+const (
+	SectionSources   Section = "Sources"
+	SectionPorts     Section = "Ports"
+	SectionProtocols Section = "Protocols"
+)
+
+var windows = []time.Duration{15 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour}
+
+type LogStatsModule struct {
+	rules   []ufw.Rule // where the rules created from the stats are inserted
+	entries []ufwlog.Entry
+	source  string
+	err     error
+	loading bool
+
+	window   *focusablelist.SelectableList[time.Duration]
+	sections *focusablelist.SelectableList[Section]
+	focused  map[Section]int
+	stats    ufwlog.Stats
+}
+
+func Init(rules []ufw.Rule) (LogStatsModule, tea.Cmd) {
+	return LogStatsModule{
+		rules:    rules,
+		loading:  true,
+		window:   focusablelist.FromList(windows).Focus(time.Hour),
+		sections: focusablelist.FromList([]Section{SectionSources, SectionPorts, SectionProtocols}),
+		focused:  map[Section]int{},
+	}, loadCmd()
+}
+
+func loadCmd() tea.Cmd {
+	return func() tea.Msg {
+		entries, source, err := ufwlog.ReadRecent(maxEntries)
+		return entriesLoadedMsg{entries: entries, source: source, err: err}
+	}
+}
+
+// UPDATE
+
+type entriesLoadedMsg struct {
+	entries []ufwlog.Entry
+	source  string
+	err     error
+}
+
+type LogStatsEscMsg struct{}
+
+// LogStatsCreateRuleMsg asks for the rule form prefilled to deny a source or limit a port.
+type LogStatsCreateRuleMsg struct {
+	Prefill createrule.Prefill
+}
+
+func (module LogStatsModule) UpdateLogStatsModule(msg tea.Msg) (LogStatsModule, tea.Cmd) {
+	m := module
+	switch msg := msg.(type) {
+	case entriesLoadedMsg:
+		m.loading = false
+		m.err = msg.err
+		m.source = msg.source
+		m.entries = msg.entries
+		m = m.recompute()
+	case tea.KeyMsg:
+		key := msg.String()
+		switch key {
+		case "tab", "right":
+			m.sections.Next()
+		case "shift+tab", "left":
+			m.sections.Prev()
+		case "up", "k":
+			m.focused[m.sections.Focused()] = max(m.focused[m.sections.Focused()]-1, 0)
+		case "down", "j":
+			m.focused[m.sections.Focused()] = min(m.focused[m.sections.Focused()]+1, max(len(m.focusedCounts())-1, 0))
+		case "w":
+			m.window.Next()
+			m = m.recompute()
+		case "r":
+			m.loading = true
+			return m, loadCmd()
+		case "d":
+			if m.sections.Focused() != SectionSources || len(m.stats.Sources) == 0 {
+				return m, nil
+			}
+			source := m.stats.Sources[m.focused[SectionSources]].Key
+			addr, err := netip.ParseAddr(source)
+			if err != nil {
+				return m, nil
+			}
+			return m, createRuleCmd(createrule.Prefill{
+				Action:    createrule.ActionDeny,
+				Direction: createrule.DirectionIn,
+				Protocol:  createrule.ProtocolBoth,
+				SourceIP:  source,
+				Comment:   "blocked by fwtui log analytics",
+				// appended after an allow rule the deny would never match
+				Position: ufw.FirstOfFamily(m.rules, addr.Is6()),
+			})
+		case "l":
+			if m.sections.Focused() != SectionPorts || len(m.stats.Ports) == 0 {
+				return m, nil
+			}
+			port, protocol, _ := strings.Cut(m.stats.Ports[m.focused[SectionPorts]].Key, "/")
+			protocol = lo.Ternary(protocol == "udp", "udp", "tcp")
+			return m, createRuleCmd(createrule.Prefill{
+				Action:    createrule.ActionLimit,
+				Direction: createrule.DirectionIn,
+				Protocol:  createrule.Protocol(protocol),
+				Port:      port,
+				Position:  m.firstForPort(port, protocol),
+			})
+		case "esc":
+			return m, func() tea.Msg {
+				return LogStatsEscMsg{}
+			}
+		}
+	}
+	return m, nil
+}
+
+func createRuleCmd(prefill createrule.Prefill) tea.Cmd {
+	return func() tea.Msg {
+		return LogStatsCreateRuleMsg{Prefill: prefill}
+	}
+}
+
+// firstForPort returns the number of the first incoming IPv4 rule for the port, which decides the
+// traffic before a limit appended after it could, or 0 to append the limit. A limit without an
+// address is inserted at an IPv4 position, ufw places its IPv6 twin itself.
+func (m LogStatsModule) firstForPort(port, protocol string) int {
+	number, err := strconv.Atoi(port)
+	if err != nil {
+		return 0
+	}
+	rule, found := lo.Find(m.rules, func(r ufw.Rule) bool {
+		return !r.V6 && !r.Route && r.Direction == "in" && r.MatchesPort(number, protocol)
+	})
+	if !found {
+		return 0
+	}
+	return rule.Number
+}
+
+func (m LogStatsModule) recompute() LogStatsModule {
+	m.stats = ufwlog.Aggregate(m.entries, m.window.Focused(), time.Now(), topN, sparklineWidth)
+	m.focused = map[Section]int{}
+	return m
+}
+
+func (m LogStatsModule) focusedCounts() []ufwlog.Count {
+	switch m.sections.Focused() {
+	case SectionSources:
+		return m.stats.Sources
+	case SectionPorts:
+		return m.stats.Ports
+	default:
+		return m.stats.Protocols
+	}
+}
+
+// VIEW
+
+func (module LogStatsModule) ViewLogStats() string {
+	if module.loading {
+		return "Reading ufw log..."
+	}
+	if module.err != nil {
+		return fmt.Sprintf("Failed to read the ufw log: %s\n\nEsc to go back", module.err)
+	}
+
+	lines := []string{
+		fmt.Sprintf("Blocked traffic in the last %s (%s): %d packets", formatWindow(module.window.Focused()), module.source, module.stats.Blocked),
+		"",
+		fmt.Sprintf("Blocks per %s:", formatWindow(module.stats.BucketSize)),
+		"|" + ufwlog.Sparkline(module.stats.Buckets) + "|",
+		"",
+	}
+
+	columns := lo.Map(module.sections.GetItems(), func(section Section, _ int) []string {
+		var counts []ufwlog.Count
+		switch section {
+		case SectionSources:
+			counts = module.stats.Sources
+		case SectionPorts:
+			counts = module.stats.Ports
+		case SectionProtocols:
+			counts = module.stats.Protocols
+		}
+		isActive := module.sections.Focused() == section
+		column := []string{lo.Ternary(isActive, "[Top "+string(section)+"]", " Top "+string(section))}
+		for i, c := range counts {
+			prefix := lo.Ternary(isActive && module.focused[section] == i, ">", " ")
+			column = append(column, fmt.Sprintf("%s %-39s %7d", prefix, c.Key, c.Count))
+		}
+		return column
+	})
+
+	height := lo.Max(lo.Map(columns, func(c []string, _ int) int { return len(c) }))
+	for row := 0; row < height; row++ {
+		cells := lo.Map(columns, func(c []string, _ int) string {
+			return fmt.Sprintf("%-49s", lo.NthOr(c, row, ""))
+		})
+		lines = append(lines, strings.Join(cells, " "))
+	}
+
+	output := strings.Join(lines, "\n")
+	output += "\n\nTab/←→ to switch list, ↑↓ to navigate, d to deny source, l to limit port, w to change window, r to reload, Esc to cancel"
+	return output
+}
+
+func formatWindow(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	}
+	return fmt.Sprintf("%dm", int(d/time.Minute))
+}
@@ -0,0 +1,336 @@
+package nat
+
+import (
+	"fmt"
+	"fwtui/domain/notification"
+	"fwtui/domain/ufw"
+	"fwtui/modules/createrule"
+	"fwtui/modules/shared/confirmation"
+	"fwtui/utils/focusablelist"
+	"fwtui/utils/result"
+	stringsext "fwtui/utils/strings"
+	"fwtui/utils/teacmd"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/samber/lo"
+)
+
+// MODEL
+
+type Field string
+
+const (
+	FieldKind         Field = "Kind"
+	FieldSource       Field = "Source"
+	FieldOutInterface Field = "OutInterface"
+	FieldInInterface  Field = "InInterface"
+	FieldProtocol     Field = "Protocol"
+	FieldPort         Field = "Port"
+	FieldToAddr       Field = "ToAddr"
+	FieldToPort       Field = "ToPort"
+)
+
+var kinds = []ufw.NatKind{ufw.NatMasquerade, ufw.NatForward}
+var protocols = []string{"tcp", "udp"}
+
+type natForm struct {
+	kind         *focusablelist.SelectableList[ufw.NatKind]
+	source       string
+	outInterface *focusablelist.SelectableList[string]
+	inInterface  *focusablelist.SelectableList[string]
+	protocol     *focusablelist.SelectableList[string]
+	port         string
+	toAddr       string
+	toPort       string
+
+	selectedField *focusablelist.SelectableList[Field]
+}
+
+// pendingChange is the change waiting for confirmation.
+type pendingChange struct {
+	entries []ufw.NatEntry
+	added   []ufw.NatEntry
+	removed []ufw.NatEntry
+}
+
+type NatModule struct {
+	entries *focusablelist.SelectableList[ufw.NatEntry]
+	err     error
+
+	form    *natForm
+	pending *pendingChange
+	dialog  *confirmation.ConfirmDialog
+}
+
+func Init() NatModule {
+	entries, err := ufw.ReadNatEntries()
+	return NatModule{entries: focusablelist.FromList(entries), err: err}
+}
+
+func newNatForm() *natForm {
+	interfaces, _ := createrule.GetActiveInterfaces()
+	return &natForm{
+		kind:          focusablelist.FromList(kinds),
+		outInterface:  focusablelist.FromList(interfaces),
+		inInterface:   focusablelist.FromList(interfaces),
+		protocol:      focusablelist.FromList(protocols),
+		selectedField: focusablelist.FromList(fieldsForKind(ufw.NatMasquerade)),
+	}
+}
+
+func fieldsForKind(kind ufw.NatKind) []Field {
+	if kind == ufw.NatMasquerade {
+		return []Field{FieldKind, FieldSource, FieldOutInterface}
+	}
+	return []Field{FieldKind, FieldInInterface, FieldProtocol, FieldPort, FieldToAddr, FieldToPort}
+}
+
+// UPDATE
+
+type NatEscMsg struct{}
+
+// NatUpdatedMsg reports the rewritten before.rules so that the firewall state gets reloaded.
+type NatUpdatedMsg struct{ Output string }
+
+func (module NatModule) UpdateNatModule(msg tea.Msg) (NatModule, tea.Cmd) {
+	m := module
+
+	if m.dialog != nil {
+		newDialog, _, outMsg := m.dialog.UpdateDialog(msg)
+		m.dialog = newDialog
+		switch outMsg {
+		case confirmation.ConfirmationDialogYes:
+			change := *m.pending
+			m.dialog, m.pending = nil, nil
+			return m, teacmd.RunOsCmdAndAfter(func() string {
+				return ufw.ApplyNatEntries(change.entries, change.added, change.removed)
+			}, func(s string) tea.Msg {
+				return NatUpdatedMsg{Output: s}
+			})
+		case confirmation.ConfirmationDialogNo, confirmation.ConfirmationDialogEsc:
+			m.dialog, m.pending = nil, nil
+		}
+		return m, nil
+	}
+
+	if m.form != nil {
+		return m.updateForm(msg)
+	}
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		key := msg.String()
+		switch key {
+		case "up", "k":
+			m.entries.Prev()
+		case "down", "j":
+			m.entries.Next()
+		case "a":
+			if m.err == nil {
+				m.form = newNatForm()
+			}
+		case "x":
+			if m.err != nil || len(m.entries.Items) == 0 {
+				return m, nil
+			}
+			removed := m.entries.Focused()
+			remaining := lo.Filter(m.entries.Items, func(e ufw.NatEntry, _ int) bool {
+				return e != removed
+			})
+			m.confirm(pendingChange{entries: remaining, removed: []ufw.NatEntry{removed}})
+		case "esc":
+			return m, func() tea.Msg {
+				return NatEscMsg{}
+			}
+		}
+	}
+	return m, nil
+}
+
+func (module NatModule) updateForm(msg tea.Msg) (NatModule, tea.Cmd) {
+	m := module
+	form := *m.form
+	m.form = &form
+
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	key := keyMsg.String()
+	switch key {
+	case "up":
+		form.selectedField.Prev()
+	case "down":
+		form.selectedField.Next()
+	case "left", "right":
+		var list interface {
+			Next()
+			Prev()
+		}
+		switch form.selectedField.Focused() {
+		case FieldKind:
+			list = form.kind
+		case FieldOutInterface:
+			list = form.outInterface
+		case FieldInInterface:
+			list = form.inInterface
+		case FieldProtocol:
+			list = form.protocol
+		default:
+			return m, nil
+		}
+		if key == "left" {
+			list.Prev()
+		} else {
+			list.Next()
+		}
+		if form.selectedField.Focused() == FieldKind {
+			form.selectedField.SetItems(fieldsForKind(form.kind.Focused()))
+		}
+	case "backspace":
+		if value := form.textField(); value != nil {
+			*value = stringsext.TrimLastChar(*value)
+		}
+	case "enter":
+		res := form.buildEntry()
+		if res.IsErr() {
+			return m, notification.CreateCmd(res.Err().Error())
+		}
+		entry := res.Value()
+		if conflicting, found := lo.Find(m.entries.Items, entry.ConflictsWith); found {
+			return m, notification.CreateCmd(fmt.Sprintf("conflicts with existing entry: %s", conflicting.Summary()))
+		}
+		m.confirm(pendingChange{
+			entries: append(append([]ufw.NatEntry(nil), m.entries.Items...), entry),
+			added:   []ufw.NatEntry{entry},
+		})
+	case "esc":
+		m.form = nil
+	default:
+		if value := form.textField(); value != nil {
+			*value += key
+		}
+	}
+	return m, nil
+}
+
+// confirm lists what is about to change in before.rules and the route rules before applying it.
+func (m *NatModule) confirm(change pendingChange) {
+	lines := []string{"Apply these changes? before.rules is backed up first and ufw reloaded.", ""}
+	for _, entry := range change.removed {
+		lines = append(lines, fmt.Sprintf("  remove %s", entry.Summary()))
+		lines = append(lines, fmt.Sprintf("    sudo ufw route delete allow %s", entry.RouteRuleArgs()))
+	}
+	for _, entry := range change.added {
+		lines = append(lines, fmt.Sprintf("  add %s", entry.Summary()))
+		lines = append(lines, fmt.Sprintf("    sudo ufw route allow %s", entry.RouteRuleArgs()))
+	}
+	m.pending = &change
+	m.dialog = confirmation.NewConfirmDialog(strings.Join(lines, "\n"))
+}
+
+func (form *natForm) textField() *string {
+	switch form.selectedField.Focused() {
+	case FieldSource:
+		return &form.source
+	case FieldPort:
+		return &form.port
+	case FieldToAddr:
+		return &form.toAddr
+	case FieldToPort:
+		return &form.toPort
+	}
+	return nil
+}
+
+func (form natForm) buildEntry() result.Result[ufw.NatEntry] {
+	entry := ufw.NatEntry{Kind: form.kind.Focused()}
+	switch entry.Kind {
+	case ufw.NatMasquerade:
+		entry.Source = strings.TrimSpace(form.source)
+		entry.OutInterface = form.outInterface.Focused()
+	case ufw.NatForward:
+		port, err := strconv.Atoi(form.port)
+		if err != nil {
+			return result.Err[ufw.NatEntry](fmt.Errorf("invalid port: %s", form.port))
+		}
+		entry.InInterface = form.inInterface.Focused()
+		entry.Protocol = form.protocol.Focused()
+		entry.Port = port
+		entry.ToAddr = strings.TrimSpace(form.toAddr)
+		if form.toPort != "" {
+			toPort, err := strconv.Atoi(form.toPort)
+			if err != nil {
+				return result.Err[ufw.NatEntry](fmt.Errorf("invalid destination port: %s", form.toPort))
+			}
+			entry.ToPort = lo.Ternary(toPort == port, 0, toPort)
+		}
+	}
+	if err := entry.Validate(); err != nil {
+		return result.Err[ufw.NatEntry](err)
+	}
+	return result.Ok(entry)
+}
+
+// VIEW
+
+func (module NatModule) ViewNat() string {
+	if module.dialog != nil {
+		return module.dialog.ViewDialog()
+	}
+	if module.form != nil {
+		return module.form.view()
+	}
+	if module.err != nil {
+		return fmt.Sprintf("Failed to read NAT entries: %s\n\nEsc to go back", module.err)
+	}
+
+	lines := []string{"NAT entries managed by fwtui in /etc/ufw/before.rules:", ""}
+	module.entries.ForEach(func(entry ufw.NatEntry, _ int, isFocused bool) {
+		prefix := lo.Ternary(isFocused, ">", " ")
+		lines = append(lines, fmt.Sprintf("%s %-50s | route allow %s", prefix, entry.Summary(), entry.RouteRuleArgs()))
+	})
+	if len(module.entries.Items) == 0 {
+		lines = append(lines, "  No NAT entries")
+	}
+
+	output := strings.Join(lines, "\n")
+	output += "\n\n↑↓ to navigate, a to add masquerade or port forward, x to remove, Esc to cancel"
+	return output
+}
+
+func (form natForm) view() string {
+	lines := []string{"New NAT entry:", ""}
+	for _, field := range form.selectedField.GetItems() {
+		var value string
+		var label string
+
+		switch field {
+		case FieldKind:
+			value, label = string(form.kind.Focused()), "Kind"
+		case FieldSource:
+			value, label = form.source, "Source subnet"
+		case FieldOutInterface:
+			value, label = form.outInterface.Focused(), "Outgoing interface"
+		case FieldInInterface:
+			value, label = form.inInterface.Focused(), "Public interface"
+		case FieldProtocol:
+			value, label = form.protocol.Focused(), "Protocol"
+		case FieldPort:
+			value, label = form.port, "Public port"
+		case FieldToAddr:
+			value, label = form.toAddr, "Internal host"
+		case FieldToPort:
+			value, label = form.toPort, "Internal port (Optional)"
+		}
+
+		prefix := lo.Ternary(form.selectedField.Focused() == field, "> ", "  ")
+		lines = append(lines, fmt.Sprintf("%s%s: %s", prefix, label, value))
+	}
+
+	output := strings.Join(lines, "\n")
+	output += "\n\n↑↓ to navigate, ←→ to change selection, type to edit, Enter to review, Esc to cancel"
+	return output
+}
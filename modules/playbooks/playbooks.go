@@ -0,0 +1,212 @@
+package playbooks
+
+import (
+	"fmt"
+	"fwtui/domain/entity"
+	"fwtui/domain/notification"
+	"fwtui/domain/playbooks"
+	"fwtui/domain/ufw"
+	"fwtui/modules/shared/confirmation"
+	"fwtui/modules/shared/state"
+	"fwtui/utils/focusablelist"
+	stringsext "fwtui/utils/strings"
+	"fwtui/utils/teacmd"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/samber/lo"
+)
+
+// MODEL
+
+type paramsForm struct {
+	playbook playbooks.Playbook
+	values   map[string]string
+	choices  map[string]*focusablelist.SelectableList[string]
+	fields   *focusablelist.SelectableList[string]
+}
+
+type PlaybooksModule struct {
+	rules    []ufw.Rule // in place, to warn about playbook rules they shadow
+	profiles []entity.UFWProfile
+
+	list   *focusablelist.SelectableList[string]
+	form   *paramsForm
+	steps  []playbooks.Step
+	dialog *confirmation.ConfirmDialog
+}
+
+func Init(st state.State) PlaybooksModule {
+	names := lo.Map(playbooks.All, func(p playbooks.Playbook, _ int) string {
+		return p.Name
+	})
+	return PlaybooksModule{rules: st.Rules, profiles: st.Profiles.WithDefault(nil), list: focusablelist.FromList(names)}
+}
+
+func newParamsForm(playbook playbooks.Playbook) *paramsForm {
+	form := &paramsForm{
+		playbook: playbook,
+		values:   map[string]string{},
+		choices:  map[string]*focusablelist.SelectableList[string]{},
+		fields: focusablelist.FromList(lo.Map(playbook.Params, func(p playbooks.Param, _ int) string {
+			return p.Name
+		})),
+	}
+	for _, param := range playbook.Params {
+		if len(param.Options) > 0 {
+			form.choices[param.Name] = focusablelist.FromList(param.Options).Focus(param.Default)
+		} else {
+			form.values[param.Name] = param.Default
+		}
+	}
+	return form
+}
+
+// UPDATE
+
+type PlaybooksEscMsg struct{}
+
+// PlaybookAppliedMsg reports the applied playbook so that the firewall state gets reloaded.
+type PlaybookAppliedMsg struct{ Output string }
+
+func (module PlaybooksModule) UpdatePlaybooksModule(msg tea.Msg) (PlaybooksModule, tea.Cmd) {
+	m := module
+
+	if m.dialog != nil {
+		newDialog, _, outMsg := m.dialog.UpdateDialog(msg)
+		m.dialog = newDialog
+		switch outMsg {
+		case confirmation.ConfirmationDialogYes:
+			steps := m.steps
+			m.dialog, m.steps = nil, nil
+			return m, teacmd.RunOsCmdAndAfter(func() string {
+				return playbooks.Apply(steps)
+			}, func(s string) tea.Msg {
+				return PlaybookAppliedMsg{Output: s}
+			})
+		case confirmation.ConfirmationDialogNo, confirmation.ConfirmationDialogEsc:
+			m.dialog, m.steps = nil, nil
+		}
+		return m, nil
+	}
+
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	key := keyMsg.String()
+
+	if m.form != nil {
+		return m.updateForm(key)
+	}
+
+	switch key {
+	case "up", "k":
+		m.list.Prev()
+	case "down", "j":
+		m.list.Next()
+	case "enter":
+		playbook, _ := lo.Find(playbooks.All, func(p playbooks.Playbook) bool {
+			return p.Name == m.list.Focused()
+		})
+		m.form = newParamsForm(playbook)
+	case "esc":
+		return m, func() tea.Msg {
+			return PlaybooksEscMsg{}
+		}
+	}
+	return m, nil
+}
+
+func (module PlaybooksModule) updateForm(key string) (PlaybooksModule, tea.Cmd) {
+	m := module
+	form := m.form
+	field := form.fields.Focused()
+
+	switch key {
+	case "up":
+		form.fields.Prev()
+	case "down":
+		form.fields.Next()
+	case "left":
+		if choice, ok := form.choices[field]; ok {
+			choice.Prev()
+		}
+	case "right":
+		if choice, ok := form.choices[field]; ok {
+			choice.Next()
+		}
+	case "backspace":
+		if _, ok := form.choices[field]; !ok {
+			form.values[field] = stringsext.TrimLastChar(form.values[field])
+		}
+	case "enter":
+		values := map[string]string{}
+		for name, value := range form.values {
+			values[name] = value
+		}
+		for name, choice := range form.choices {
+			values[name] = choice.Focused()
+		}
+		steps, err := form.playbook.Build(values)
+		if err != nil {
+			return m, notification.CreateCmd(err.Error())
+		}
+		m.steps = steps
+		lines := lo.Map(steps, func(step playbooks.Step, _ int) string {
+			return "  " + step.String()
+		})
+		text := fmt.Sprintf("%s will run:\n\n%s", form.playbook.Title, strings.Join(lines, "\n"))
+		if warnings := playbooks.Shadowed(steps, m.rules, m.profiles); len(warnings) > 0 {
+			text += "\n\nRules in place decide some of this traffic first, move or delete them for the playbook to take effect:\n\n" +
+				strings.Join(lo.Map(warnings, func(w string, _ int) string { return "  " + w }), "\n")
+		}
+		m.dialog = confirmation.NewConfirmDialog(text + "\n\nApply?")
+	case "esc":
+		m.form = nil
+	default:
+		if _, ok := form.choices[field]; !ok {
+			form.values[field] += key
+		}
+	}
+	return m, nil
+}
+
+// VIEW
+
+func (module PlaybooksModule) ViewPlaybooks() string {
+	if module.dialog != nil {
+		return module.dialog.ViewDialog()
+	}
+	if module.form != nil {
+		return module.form.view()
+	}
+
+	lines := []string{"Playbooks:", ""}
+	module.list.ForEach(func(name string, i int, isFocused bool) {
+		prefix := lo.Ternary(isFocused, ">", " ")
+		playbook := playbooks.All[i]
+		lines = append(lines, fmt.Sprintf("%s %-30s | %s", prefix, playbook.Title, playbook.Description))
+	})
+
+	output := strings.Join(lines, "\n")
+	output += "\n\n↑↓ to navigate, Enter to fill in the parameters, Esc to cancel"
+	return output
+}
+
+func (form paramsForm) view() string {
+	lines := []string{form.playbook.Title + ":", form.playbook.Description, ""}
+	for _, param := range form.playbook.Params {
+		value := form.values[param.Name]
+		if choice, ok := form.choices[param.Name]; ok {
+			value = choice.Focused()
+		}
+		label := param.Label + lo.Ternary(param.Optional, " (Optional)", "")
+		prefix := lo.Ternary(form.fields.Focused() == param.Name, "> ", "  ")
+		lines = append(lines, fmt.Sprintf("%s%s: %s", prefix, label, value))
+	}
+
+	output := strings.Join(lines, "\n")
+	output += "\n\n↑↓ to navigate, ←→ to change selection, type to edit, Enter to review the commands, Esc to cancel"
+	return output
+}
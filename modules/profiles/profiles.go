@@ -6,6 +6,7 @@ import (
 	"fwtui/domain/ufw"
 	"fwtui/modules/profiles/createprofile"
 	"fwtui/modules/shared/confirmation"
+	"fwtui/modules/shared/state"
 	"fwtui/utils/focusablelist"
 	"fwtui/utils/multiselect"
 	"fwtui/utils/teacmd"
@@ -31,14 +32,12 @@ type ProfilesModule struct {
 	createProfileModule createprofile.ProfileForm
 }
 
-func Init() (ProfilesModule, tea.Cmd) {
+func Init(installed []entity.UFWProfile) (ProfilesModule, tea.Cmd) {
 	model := ProfilesModule{
 		menu: focusablelist.FromList([]string{menuListProfiles, menuCreateFromList, menuCreateProfile}),
 		view: viewStateHome,
 	}
-	model = model.reloadInstalledProfiles()
-	model = model.reloadProfilesToInstall()
-	return model, nil
+	return model.SetInstalled(installed), nil
 }
 
 // UPDATE
@@ -128,10 +127,7 @@ func (mod ProfilesModule) UpdateProfilesModule(msg tea.Msg) (ProfilesModule, tea
 			return m, teacmd.OsCmdExecutionFinishedCmd(msg.Output)
 
 		case profilesDeletedMsg:
-			m = m.reloadInstalledProfiles()
-			m = m.reloadProfilesToInstall()
-			m.installedProfiles.ClearSelection()
-			return m, teacmd.OsCmdExecutionFinishedCmd(msg.Output)
+			return m, tea.Batch(state.LoadCmd(), teacmd.OsCmdExecutionFinishedCmd(msg.Output))
 		case tea.KeyMsg:
 			key := msg.String()
 			switch key {
@@ -172,9 +168,7 @@ func (mod ProfilesModule) UpdateProfilesModule(msg tea.Msg) (ProfilesModule, tea
 	case m.view.isViewCreateFromList():
 		switch msg := msg.(type) {
 		case profilesCreatedMsg:
-			m = m.reloadInstalledProfiles()
-			m = m.reloadProfilesToInstall()
-			return m, teacmd.OsCmdExecutionFinishedCmd(msg.Output)
+			return m, tea.Batch(state.LoadCmd(), teacmd.OsCmdExecutionFinishedCmd(msg.Output))
 
 		case tea.KeyMsg:
 			key := msg.String()
@@ -216,9 +210,8 @@ func (mod ProfilesModule) UpdateProfilesModule(msg tea.Msg) (ProfilesModule, tea
 	case m.view.isViewCreate():
 		switch msg.(type) {
 		case createprofile.CreateProfileCreatedMsg:
-			m = m.reloadInstalledProfiles()
 			m.view = viewStateHome
-			return m, nil
+			return m, state.LoadCmd()
 		case createprofile.CreateProfileEscMsg:
 			m.view = viewStateHome
 			return m, nil
@@ -232,14 +225,11 @@ func (mod ProfilesModule) UpdateProfilesModule(msg tea.Msg) (ProfilesModule, tea
 	return m, nil
 }
 
-func (m ProfilesModule) reloadInstalledProfiles() ProfilesModule {
-	profiles, _ := entity.LoadInstalledProfiles()
-	m.installedProfiles = multiselect.FromList(profiles)
-	return m
-}
-
-func (m ProfilesModule) reloadProfilesToInstall() ProfilesModule {
-	m.profilesToInstall = multiselect.FromList(entity.InstallableProfiles())
+// SetInstalled lists the installed profiles of a loaded state snapshot and the predefined ones still
+// missing. Changes reload the snapshot with state.LoadCmd instead of reading the profiles in Update.
+func (m ProfilesModule) SetInstalled(installed []entity.UFWProfile) ProfilesModule {
+	m.installedProfiles = multiselect.FromList(installed)
+	m.profilesToInstall = multiselect.FromList(entity.InstallableProfiles(installed))
 	return m
 }
 
@@ -275,7 +265,7 @@ func (m ProfilesModule) ViewProfiles() string {
 			focusedPrefix := lo.Ternary(isFocused, ">", " ")
 			selectedPrefix := lo.Ternary(isSelected, "*", " ")
 			prefix := focusedPrefix + selectedPrefix
-			lines = append(lines, fmt.Sprintf("%s %-20s | %-45s | %-45s", prefix, profile.Name, profile.Title, strings.Join(profile.Ports, ", ")))
+			lines = append(lines, fmt.Sprintf("%s %-20s | %-45s | %-45s", prefix, profile.Name, profile.Title, profile.PortsWithServices()))
 		})
 
 		output = strings.Join(lines, "\n")
@@ -286,7 +276,7 @@ func (m ProfilesModule) ViewProfiles() string {
 			focusedPrefix := lo.Ternary(isFocused, ">", " ")
 			selectedPrefix := lo.Ternary(isSelected, "*", " ")
 			prefix := focusedPrefix + selectedPrefix
-			lines = append(lines, fmt.Sprintf("%s %-20s | %-45s | %-45s", prefix, profile.Name, profile.Title, strings.Join(profile.Ports, ", ")))
+			lines = append(lines, fmt.Sprintf("%s %-20s | %-45s | %-45s", prefix, profile.Name, profile.Title, profile.PortsWithServices()))
 		})
 
 		output = strings.Join(lines, "\n")
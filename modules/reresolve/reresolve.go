@@ -0,0 +1,136 @@
+package reresolve
+
+import (
+	"fmt"
+	"fwtui/domain/hostnames"
+	"fwtui/domain/ufw"
+	"fwtui/modules/shared/confirmation"
+	"fwtui/modules/shared/state"
+	"fwtui/utils/oscmd"
+	"fwtui/utils/teacmd"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/samber/lo"
+)
+
+// MODEL
+
+type ReresolveModule struct {
+	rules   []ufw.Rule
+	changes []hostnames.Change
+	loading bool
+	dialog  *confirmation.ConfirmDialog
+}
+
+func Init(st state.State, resolver hostnames.Resolver) (ReresolveModule, tea.Cmd) {
+	groups := hostnames.GroupRules(st.Rules)
+	return ReresolveModule{rules: st.Rules, loading: true}, func() tea.Msg {
+		return resolvedMsg{changes: hostnames.Reresolve(resolver, groups)}
+	}
+}
+
+// UPDATE
+
+type resolvedMsg struct {
+	changes []hostnames.Change
+}
+
+type ReresolveEscMsg struct{}
+
+// ReresolveAppliedMsg reports the updated rules so that the firewall state gets reloaded.
+type ReresolveAppliedMsg struct{ Output string }
+
+func (module ReresolveModule) UpdateReresolveModule(msg tea.Msg) (ReresolveModule, tea.Cmd) {
+	m := module
+
+	if m.dialog != nil {
+		newDialog, _, outMsg := m.dialog.UpdateDialog(msg)
+		m.dialog = newDialog
+		switch outMsg {
+		case confirmation.ConfirmationDialogYes:
+			m.dialog = nil
+			commands := commands(m.rules, m.changes)
+			return m, teacmd.RunOsCmdAndAfter(func() string {
+				return strings.Join(lo.Map(commands, func(command string, _ int) string {
+					return oscmd.RunCommand(command)
+				}), "")
+			}, func(s string) tea.Msg {
+				return ReresolveAppliedMsg{Output: s}
+			})
+		case confirmation.ConfirmationDialogNo, confirmation.ConfirmationDialogEsc:
+			m.dialog = nil
+		}
+		return m, nil
+	}
+
+	switch msg := msg.(type) {
+	case resolvedMsg:
+		m.loading = false
+		m.changes = msg.changes
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "enter":
+			if m.loading || !hasUpdates(m.changes) {
+				return m, nil
+			}
+			m.dialog = confirmation.NewConfirmDialog("Update the rules to the current addresses?\n\n" + strings.Join(commands(m.rules, m.changes), "\n"))
+		case "esc":
+			return m, func() tea.Msg {
+				return ReresolveEscMsg{}
+			}
+		}
+	}
+	return m, nil
+}
+
+func hasUpdates(changes []hostnames.Change) bool {
+	return lo.ContainsBy(changes, func(c hostnames.Change) bool {
+		return len(c.Add) > 0 || len(c.Delete) > 0
+	})
+}
+
+// commands replaces the rules of the changed addresses in place, see ufw.RuleChangeCommands.
+func commands(rules []ufw.Rule, changes []hostnames.Change) []string {
+	var remove, add []ufw.Rule
+	for _, change := range changes {
+		remove = append(remove, change.Delete...)
+		add = append(add, change.Add...)
+	}
+	return ufw.RuleChangeCommands(rules, remove, add)
+}
+
+// VIEW
+
+func (module ReresolveModule) ViewReresolve() string {
+	if module.dialog != nil {
+		return module.dialog.ViewDialog()
+	}
+	if module.loading {
+		return "Resolving hostnames..."
+	}
+
+	lines := []string{"Rules created for hostnames:", ""}
+	for _, change := range module.changes {
+		lines = append(lines, fmt.Sprintf("%s (%d rules): %s", change.Set.Tag, len(change.Set.Rules), change.Set.Template.Summary()))
+		switch {
+		case change.Err != nil:
+			lines = append(lines, "  ! "+change.Err.Error())
+		case len(change.Add) == 0 && len(change.Delete) == 0:
+			lines = append(lines, "  up to date")
+		}
+		for _, rule := range change.Add {
+			lines = append(lines, "  + "+rule.SetAddr())
+		}
+		for _, rule := range change.Delete {
+			lines = append(lines, "  - "+rule.SetAddr())
+		}
+	}
+	if len(module.changes) == 0 {
+		lines = append(lines, "  No rules created for hostnames")
+	}
+
+	output := strings.Join(lines, "\n")
+	output += "\n\n" + lo.Ternary(hasUpdates(module.changes), "Enter to update the rules, ", "") + "Esc to cancel"
+	return output
+}
@@ -0,0 +1,405 @@
+package pager
+
+import (
+	"fmt"
+	"fwtui/domain/notification"
+	stringsext "fwtui/utils/strings"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-runewidth"
+	"github.com/samber/lo"
+)
+
+// MODEL
+
+const defaultHeight = 20
+const defaultWidth = 120
+
+// reserved lines for the title, search/save prompt and the key help
+const chromeHeight = 6
+
+type mode string
+
+const (
+	modeBrowse mode = "browse"
+	modeSearch mode = "search"
+	modeSave   mode = "save"
+)
+
+type section struct {
+	header int // index of the header line, -1 for lines before the first header
+	body   []int
+	folded bool
+}
+
+type row struct {
+	line    int
+	text    string
+	section int
+	header  bool
+}
+
+type Pager struct {
+	title    string
+	content  string
+	lines    []string
+	sections []section
+	rows     []row
+	loading  bool
+
+	isHeader func(line string) bool
+
+	cursor int
+	offset int
+	width  int
+	height int
+	wrap   bool
+
+	mode     mode
+	query    string
+	input    string
+	savePath string
+}
+
+// New creates a pager waiting for its content. Lines for which isHeader returns true start a
+// foldable section; pass nil to show the content flat.
+func New(title string, savePath string, isHeader func(line string) bool) Pager {
+	return Pager{
+		title:    title,
+		savePath: savePath,
+		isHeader: isHeader,
+		loading:  true,
+		width:    defaultWidth,
+		height:   defaultHeight,
+		mode:     modeBrowse,
+	}
+}
+
+func (p Pager) SetContent(content string) Pager {
+	p.content = content
+	p.lines = strings.Split(strings.TrimRight(content, "\n"), "\n")
+	p.loading = false
+	p.cursor = 0
+	p.offset = 0
+	p.sections = p.buildSections()
+	p.rows = p.buildRows()
+	return p
+}
+
+func (p Pager) SetSize(width, height int) Pager {
+	if width > 0 {
+		p.width = width
+	}
+	if height > 0 {
+		p.height = height
+	}
+	p.rows = p.buildRows()
+	p = p.clampCursor()
+	return p
+}
+
+func (p Pager) buildSections() []section {
+	sections := []section{{header: -1}}
+	for i, line := range p.lines {
+		if p.isHeader != nil && p.isHeader(line) {
+			sections = append(sections, section{header: i, folded: true})
+			continue
+		}
+		last := &sections[len(sections)-1]
+		last.body = append(last.body, i)
+	}
+	return sections
+}
+
+func (p Pager) buildRows() []row {
+	var rows []row
+	for s, sec := range p.sections {
+		if sec.header >= 0 {
+			marker := lo.Ternary(sec.folded, "▸", "▾")
+			if len(sec.body) == 0 {
+				marker = " "
+			}
+			text := fmt.Sprintf("%s %s", marker, p.lines[sec.header])
+			if sec.folded && len(sec.body) > 0 {
+				text += fmt.Sprintf(" (%d lines)", len(sec.body))
+			}
+			rows = append(rows, p.wrapRow(row{line: sec.header, text: text, section: s, header: true})...)
+		}
+		if sec.folded {
+			continue
+		}
+		for _, i := range sec.body {
+			rows = append(rows, p.wrapRow(row{line: i, text: p.lines[i], section: s})...)
+		}
+	}
+	return rows
+}
+
+// wrapRow splits a row into rows of at most the pager width in terminal cells, so that wide
+// characters count twice and multi-byte ones are never cut in half.
+func (p Pager) wrapRow(r row) []row {
+	limit := p.width - 3
+	if !p.wrap || limit <= 0 || runewidth.StringWidth(r.text) <= limit {
+		return []row{r}
+	}
+	var rows []row
+	var chunk strings.Builder
+	width := 0
+	for _, c := range r.text {
+		w := runewidth.RuneWidth(c)
+		if width+w > limit && width > 0 {
+			part := r
+			part.text = chunk.String()
+			rows = append(rows, part)
+			r.header = false
+			chunk.Reset()
+			width = 0
+		}
+		chunk.WriteRune(c)
+		width += w
+	}
+	r.text = chunk.String()
+	return append(rows, r)
+}
+
+func (p Pager) pageSize() int {
+	return max(p.height-chromeHeight, 1)
+}
+
+func (p Pager) clampCursor() Pager {
+	p.cursor = max(min(p.cursor, len(p.rows)-1), 0)
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+p.pageSize() {
+		p.offset = p.cursor - p.pageSize() + 1
+	}
+	return p
+}
+
+// UPDATE
+
+type PagerEscMsg struct{}
+
+func (pager Pager) UpdatePager(msg tea.Msg) (Pager, tea.Cmd) {
+	p := pager
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		return p.SetSize(msg.Width, msg.Height), nil
+	case tea.KeyMsg:
+		key := msg.String()
+		switch p.mode {
+		case modeSearch, modeSave:
+			return p.updatePrompt(key)
+		}
+
+		switch key {
+		case "esc", "q":
+			return p, func() tea.Msg {
+				return PagerEscMsg{}
+			}
+		case "up", "k":
+			p.cursor--
+		case "down", "j":
+			p.cursor++
+		case "pgup", "b":
+			p.cursor -= p.pageSize()
+		case "pgdown", "f", " ":
+			p.cursor += p.pageSize()
+		case "home", "g":
+			p.cursor = 0
+		case "end", "G":
+			p.cursor = len(p.rows) - 1
+		case "enter", "tab":
+			p = p.toggleFocusedSection()
+		case "z":
+			p = p.setAllFolded(!p.allFolded())
+		case "w":
+			p.wrap = !p.wrap
+			focusedLine := p.focusedLine()
+			p.rows = p.buildRows()
+			p = p.focusLine(focusedLine)
+		case "/":
+			p.mode = modeSearch
+			p.input = ""
+		case "n":
+			p = p.jumpToMatch(1)
+		case "N":
+			p = p.jumpToMatch(-1)
+		case "s":
+			p.mode = modeSave
+			p.input = p.savePath
+		}
+		p = p.clampCursor()
+	}
+	return p, nil
+}
+
+func (p Pager) updatePrompt(key string) (Pager, tea.Cmd) {
+	switch key {
+	case "esc":
+		p.mode = modeBrowse
+	case "backspace":
+		p.input = stringsext.TrimLastChar(p.input)
+	case "enter":
+		switch p.mode {
+		case modeSearch:
+			p.mode = modeBrowse
+			p.query = p.input
+			p = p.jumpToMatch(0)
+		case modeSave:
+			p.mode = modeBrowse
+			p.savePath = p.input
+			err := os.WriteFile(p.savePath, []byte(p.content), 0644)
+			if err != nil {
+				return p, notification.CreateCmd(fmt.Sprintf("Failed to save %s: %s", p.savePath, err))
+			}
+			return p, notification.CreateCmd(fmt.Sprintf("Saved to %s", p.savePath))
+		}
+	default:
+		if len(key) == 1 {
+			p.input += key
+		}
+	}
+	return p, nil
+}
+
+func (p Pager) focusedLine() int {
+	if len(p.rows) == 0 {
+		return 0
+	}
+	return p.rows[p.cursor].line
+}
+
+func (p Pager) focusLine(line int) Pager {
+	for i, r := range p.rows {
+		if r.line == line {
+			p.cursor = i
+			break
+		}
+	}
+	return p.clampCursor()
+}
+
+func (p Pager) toggleFocusedSection() Pager {
+	if len(p.rows) == 0 {
+		return p
+	}
+	s := p.rows[p.cursor].section
+	if p.sections[s].header < 0 {
+		return p
+	}
+	p.sections = cloneSections(p.sections)
+	p.sections[s].folded = !p.sections[s].folded
+	p.rows = p.buildRows()
+	return p.focusLine(p.sections[s].header)
+}
+
+func (p Pager) allFolded() bool {
+	return lo.EveryBy(p.sections, func(s section) bool {
+		return s.header < 0 || s.folded
+	})
+}
+
+func (p Pager) setAllFolded(folded bool) Pager {
+	focusedLine := p.focusedLine()
+	p.sections = cloneSections(p.sections)
+	for i := range p.sections {
+		p.sections[i].folded = folded && p.sections[i].header >= 0
+	}
+	p.rows = p.buildRows()
+	if folded {
+		return p.focusLine(p.sections[p.sectionOfLine(focusedLine)].header)
+	}
+	return p.focusLine(focusedLine)
+}
+
+func (p Pager) sectionOfLine(line int) int {
+	for s, sec := range p.sections {
+		if sec.header == line || lo.Contains(sec.body, line) {
+			return s
+		}
+	}
+	return 0
+}
+
+// jumpToMatch moves to the next (1), previous (-1) or first from the cursor (0) line matching the
+// query, unfolding its section when needed.
+func (p Pager) jumpToMatch(direction int) Pager {
+	if p.query == "" || len(p.lines) == 0 {
+		return p
+	}
+	query := strings.ToLower(p.query)
+	start := p.focusedLine()
+	step := lo.Ternary(direction < 0, -1, 1)
+	if direction == 0 {
+		start -= step
+	}
+
+	for i := 1; i <= len(p.lines); i++ {
+		line := ((start+step*i)%len(p.lines) + len(p.lines)) % len(p.lines)
+		if !strings.Contains(strings.ToLower(p.lines[line]), query) {
+			continue
+		}
+		s := p.sectionOfLine(line)
+		if p.sections[s].folded && p.sections[s].header != line {
+			p.sections = cloneSections(p.sections)
+			p.sections[s].folded = false
+			p.rows = p.buildRows()
+		}
+		return p.focusLine(line)
+	}
+	return p
+}
+
+func (p Pager) matches(r row) bool {
+	return p.query != "" && strings.Contains(strings.ToLower(p.lines[r.line]), strings.ToLower(p.query))
+}
+
+func cloneSections(sections []section) []section {
+	return append([]section(nil), sections...)
+}
+
+// VIEW
+
+func (p Pager) ViewPager() string {
+	lines := []string{p.title, ""}
+
+	if p.loading {
+		lines = append(lines, "Loading...")
+		return strings.Join(lines, "\n")
+	}
+
+	end := min(p.offset+p.pageSize(), len(p.rows))
+	for i := p.offset; i < end; i++ {
+		r := p.rows[i]
+		focusedPrefix := lo.Ternary(i == p.cursor, ">", " ")
+		matchPrefix := lo.Ternary(p.matches(r), "*", " ")
+		lines = append(lines, fmt.Sprintf("%s%s %s", focusedPrefix, matchPrefix, r.text))
+	}
+
+	output := strings.Join(lines, "\n")
+	output += fmt.Sprintf("\n\n%d-%d of %d", min(p.offset+1, len(p.rows)), end, len(p.rows))
+	if p.query != "" {
+		output += fmt.Sprintf(" | search: %s", p.query)
+	}
+	output += lo.Ternary(p.wrap, " | wrap", "")
+
+	switch p.mode {
+	case modeSearch:
+		output += "\n/" + p.input
+		output += "\nType to search, Enter to find, Esc to cancel"
+	case modeSave:
+		output += "\nSave to: " + p.input
+		output += "\nType the path, Enter to save, Esc to cancel"
+	default:
+		help := "\n↑↓ PgUp/PgDn to scroll, / to search, n/N next/prev match, w to wrap, s to save"
+		if p.isHeader != nil {
+			help += ", Enter to fold, z to fold all"
+		}
+		output += help + ", Esc to close"
+	}
+	return output
+}
@@ -0,0 +1,118 @@
+package state
+
+import (
+	"fmt"
+	"fwtui/domain/declarative"
+	"fwtui/domain/entity"
+	"fwtui/domain/ufw"
+	"fwtui/modules/defaultpolicies"
+	"fwtui/utils/result"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// State is a snapshot of everything the views show about the firewall, loaded in one go. A refresh
+// runs `sudo ufw status verbose` and, while ufw is active, `sudo iptables -L` and `sudo ip6tables -L`
+// for the counters. It reads user.rules, user6.rules, /etc/default/ufw, /etc/ufw/sysctl.conf and
+// the profile files as well, so it is too heavy for tight polling loops.
+type State struct {
+	Status       string
+	Enabled      bool
+	LoggingLevel string
+	Defaults     result.Result[defaultpolicies.DefaultPolicies]
+	Rules        []ufw.Rule
+	Counters     result.Result[map[int]ufw.Counter]
+	Forwarding   result.Result[ufw.Forwarding]
+	IPv6         result.Result[bool]
+	Profiles     result.Result[[]entity.UFWProfile]
+}
+
+type StateLoadedMsg struct {
+	State State
+}
+
+func Load() State {
+	status := ufw.StatusVerbose()
+	enabled, loggingLevel := ufw.ParseStatusFlags(status)
+	var profiles result.Result[[]entity.UFWProfile]
+	if p, err := entity.LoadInstalledProfiles(); err != nil {
+		profiles = result.Err[[]entity.UFWProfile](err)
+	} else {
+		profiles = result.Ok(p)
+	}
+
+	counters := result.Ok(map[int]ufw.Counter{})
+	if enabled {
+		if c, err := ufw.ReadRuleCounters(); err != nil {
+			counters = result.Err[map[int]ufw.Counter](err)
+		} else {
+			counters = result.Ok(c)
+		}
+	}
+
+	var forwarding result.Result[ufw.Forwarding]
+	if f, err := ufw.ReadForwarding(); err != nil {
+		forwarding = result.Err[ufw.Forwarding](err)
+	} else {
+		forwarding = result.Ok(f)
+	}
+
+	var ipv6 result.Result[bool]
+	if enabled, err := ufw.ReadIPv6Setting(); err != nil {
+		ipv6 = result.Err[bool](err)
+	} else {
+		ipv6 = result.Ok(enabled)
+	}
+
+	return State{
+		Status:       status,
+		Enabled:      enabled,
+		LoggingLevel: loggingLevel,
+		Defaults:     defaultpolicies.ParseUfwDefaults(status),
+		Rules:        ufw.LoadRules(status),
+		Counters:     counters,
+		Forwarding:   forwarding,
+		IPv6:         ipv6,
+		Profiles:     profiles,
+	}
+}
+
+// Live is the state as the declarative config sees it. The rules come straight from the rule tuples,
+// a plan against the `ufw status` lines alone would be guesswork.
+func (s State) Live() (declarative.Live, error) {
+	if s.Defaults.IsErr() {
+		return declarative.Live{}, fmt.Errorf("reading the default policies (is ufw enabled?): %w", s.Defaults.Err())
+	}
+	rules, err := ufw.ReadRuleTuples()
+	if err != nil {
+		return declarative.Live{}, err
+	}
+	if s.Profiles.IsErr() {
+		return declarative.Live{}, s.Profiles.Err()
+	}
+
+	defaults := s.Defaults.Value()
+	live := declarative.Live{
+		Enabled:  s.Enabled,
+		Rules:    rules,
+		Incoming: defaults.Incoming,
+		Outgoing: defaults.Outgoing,
+		Routed:   defaults.Routed,
+		Logging:  s.LoggingLevel,
+		IPv6:     s.IPv6.IsOk() && s.IPv6.Value(),
+		Profiles: s.Profiles.Value(),
+	}
+	if live.Routed == "disabled" {
+		if live.ForwardPolicy, err = ufw.ReadForwardPolicy(); err != nil {
+			return declarative.Live{}, err
+		}
+	}
+	return live, nil
+}
+
+// LoadCmd loads the state in the background and delivers it as StateLoadedMsg.
+func LoadCmd() tea.Cmd {
+	return func() tea.Msg {
+		return StateLoadedMsg{State: Load()}
+	}
+}
@@ -0,0 +1,252 @@
+package simulate
+
+import (
+	"fmt"
+	"fwtui/domain/ufw"
+	"fwtui/modules/createrule"
+	"fwtui/modules/shared/state"
+	"fwtui/utils/focusablelist"
+	"fwtui/utils/result"
+	stringsext "fwtui/utils/strings"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/samber/lo"
+)
+
+// MODEL
+
+type Field string
+
+const (
+	FieldDirection    Field = "Direction"
+	FieldProtocol     Field = "Protocol"
+	FieldSourceIP     Field = "SourceIP"
+	FieldDestIP       Field = "DestinationIP"
+	FieldPort         Field = "Port"
+	FieldSourcePort   Field = "SourcePort"
+	FieldInterface    Field = "Interface"
+	FieldInterfaceOut Field = "InterfaceOut"
+)
+
+var directions = []string{ufw.PacketIn, ufw.PacketOut, ufw.PacketRouted}
+var protocols = []string{"tcp", "udp"}
+
+type SimulateModule struct {
+	state state.State
+
+	direction    *focusablelist.SelectableList[string]
+	protocol     *focusablelist.SelectableList[string]
+	sourceIP     string
+	destIP       string
+	port         string
+	sourcePort   string
+	interface_   *focusablelist.SelectableList[string]
+	interfaceOut *focusablelist.SelectableList[string]
+
+	selectedField *focusablelist.SelectableList[Field]
+	verdict       *ufw.Verdict
+}
+
+func Init(st state.State) SimulateModule {
+	interfaces, _ := createrule.GetActiveInterfaces()
+	return SimulateModule{
+		state:         st,
+		direction:     focusablelist.FromList(directions),
+		protocol:      focusablelist.FromList(protocols),
+		interface_:    focusablelist.FromList(interfaces),
+		interfaceOut:  focusablelist.FromList(interfaces),
+		selectedField: focusablelist.FromList(fieldsForDirection(ufw.PacketIn)),
+	}
+}
+
+func fieldsForDirection(direction string) []Field {
+	fields := []Field{FieldDirection, FieldProtocol, FieldSourceIP, FieldDestIP, FieldPort, FieldSourcePort, FieldInterface}
+	if direction == ufw.PacketRouted {
+		fields = append(fields, FieldInterfaceOut)
+	}
+	return fields
+}
+
+// UPDATE
+
+type SimulateEscMsg struct{}
+
+func (module SimulateModule) UpdateSimulateModule(msg tea.Msg) (SimulateModule, tea.Cmd) {
+	m := module
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		key := msg.String()
+		switch key {
+		case "up":
+			m.selectedField.Prev()
+		case "down":
+			m.selectedField.Next()
+		case "left":
+			switch m.selectedField.Focused() {
+			case FieldDirection:
+				m.direction.Prev()
+				m.selectedField.SetItems(fieldsForDirection(m.direction.Focused()))
+			case FieldProtocol:
+				m.protocol.Prev()
+			case FieldInterface:
+				m.interface_.Prev()
+			case FieldInterfaceOut:
+				m.interfaceOut.Prev()
+			}
+		case "right":
+			switch m.selectedField.Focused() {
+			case FieldDirection:
+				m.direction.Next()
+				m.selectedField.SetItems(fieldsForDirection(m.direction.Focused()))
+			case FieldProtocol:
+				m.protocol.Next()
+			case FieldInterface:
+				m.interface_.Next()
+			case FieldInterfaceOut:
+				m.interfaceOut.Next()
+			}
+		case "backspace":
+			switch m.selectedField.Focused() {
+			case FieldSourceIP:
+				m.sourceIP = stringsext.TrimLastChar(m.sourceIP)
+			case FieldDestIP:
+				m.destIP = stringsext.TrimLastChar(m.destIP)
+			case FieldPort:
+				m.port = stringsext.TrimLastChar(m.port)
+			case FieldSourcePort:
+				m.sourcePort = stringsext.TrimLastChar(m.sourcePort)
+			}
+		case "enter":
+			res := m.buildPacket()
+			if res.IsErr() {
+				m.verdict = &ufw.Verdict{Action: "invalid", Reason: res.Err().Error()}
+				return m, nil
+			}
+			verdict := m.simulate(res.Value())
+			m.verdict = &verdict
+		case "esc":
+			return m, func() tea.Msg {
+				return SimulateEscMsg{}
+			}
+		default:
+			switch m.selectedField.Focused() {
+			case FieldSourceIP:
+				m.sourceIP += key
+			case FieldDestIP:
+				m.destIP += key
+			case FieldPort:
+				m.port += key
+			case FieldSourcePort:
+				m.sourcePort += key
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m SimulateModule) simulate(packet ufw.Packet) ufw.Verdict {
+	if !m.state.Enabled {
+		return ufw.Verdict{Action: "allow", Reason: "ufw is disabled"}
+	}
+
+	defaultPolicy := ""
+	if m.state.Defaults.IsOk() {
+		defaults := m.state.Defaults.Value()
+		switch packet.Direction {
+		case ufw.PacketIn:
+			defaultPolicy = defaults.Incoming
+		case ufw.PacketOut:
+			defaultPolicy = defaults.Outgoing
+		case ufw.PacketRouted:
+			defaultPolicy = defaults.Routed
+		}
+	}
+	return ufw.Simulate(m.state.Rules, defaultPolicy, packet)
+}
+
+func (m SimulateModule) buildPacket() result.Result[ufw.Packet] {
+	source, err := netip.ParseAddr(strings.TrimSpace(m.sourceIP))
+	if err != nil {
+		return result.Err[ufw.Packet](fmt.Errorf("invalid source IP: %s", m.sourceIP))
+	}
+	dest, err := netip.ParseAddr(strings.TrimSpace(m.destIP))
+	if err != nil {
+		return result.Err[ufw.Packet](fmt.Errorf("invalid destination IP: %s", m.destIP))
+	}
+	source, dest = source.Unmap(), dest.Unmap()
+	if source.Is4() != dest.Is4() {
+		return result.Err[ufw.Packet](fmt.Errorf("source and destination must both be IPv4 or both IPv6"))
+	}
+
+	port, err := strconv.Atoi(m.port)
+	if err != nil || port < 1 || port > 65535 {
+		return result.Err[ufw.Packet](fmt.Errorf("invalid port: %s", m.port))
+	}
+
+	sourcePort := 0
+	if strings.TrimSpace(m.sourcePort) != "" {
+		sourcePort, err = strconv.Atoi(strings.TrimSpace(m.sourcePort))
+		if err != nil || sourcePort < 1 || sourcePort > 65535 {
+			return result.Err[ufw.Packet](fmt.Errorf("invalid source port: %s", m.sourcePort))
+		}
+	}
+
+	packet := ufw.Packet{
+		Direction:  m.direction.Focused(),
+		Protocol:   m.protocol.Focused(),
+		Source:     source,
+		Dest:       dest,
+		Port:       port,
+		SourcePort: sourcePort,
+		Interface:  m.interface_.Focused(),
+	}
+	if packet.Direction == ufw.PacketRouted {
+		packet.InterfaceOut = m.interfaceOut.Focused()
+	}
+	return result.Ok(packet)
+}
+
+// VIEW
+
+func (module SimulateModule) ViewSimulate() string {
+	lines := []string{"Test traffic:", ""}
+
+	for _, field := range module.selectedField.GetItems() {
+		var value string
+		var label string
+
+		switch field {
+		case FieldDirection:
+			value, label = module.direction.Focused(), "Direction"
+		case FieldProtocol:
+			value, label = module.protocol.Focused(), "Protocol"
+		case FieldSourceIP:
+			value, label = module.sourceIP, "Source IP"
+		case FieldDestIP:
+			value, label = module.destIP, "Destination IP"
+		case FieldPort:
+			value, label = module.port, "Destination port"
+		case FieldSourcePort:
+			value, label = module.sourcePort, "Source port (optional)"
+		case FieldInterface:
+			value, label = module.interface_.Focused(), lo.Ternary(module.direction.Focused() == ufw.PacketOut, "Interface", "Incoming interface")
+		case FieldInterfaceOut:
+			value, label = module.interfaceOut.Focused(), "Outgoing interface"
+		}
+
+		prefix := lo.Ternary(module.selectedField.Focused() == field, "> ", "  ")
+		lines = append(lines, fmt.Sprintf("%s%s: %s", prefix, label, value))
+	}
+
+	if module.verdict != nil {
+		lines = append(lines, "", fmt.Sprintf("Verdict: %s", strings.ToUpper(module.verdict.Action)))
+		lines = append(lines, fmt.Sprintf("Decided by: %s", module.verdict.Reason))
+	}
+
+	output := strings.Join(lines, "\n")
+	output += "\n\n↑↓ to navigate, ←→ to change selection, type to edit, Enter to test, Esc to cancel"
+	return output
+}
@@ -0,0 +1,383 @@
+package transfer
+
+import (
+	"fmt"
+	"fwtui/domain/declarative"
+	"fwtui/domain/iptimport"
+	"fwtui/domain/notification"
+	"fwtui/modules/shared/state"
+	"fwtui/utils/focusablelist"
+	"fwtui/utils/multiselect"
+	stringsext "fwtui/utils/strings"
+	"fwtui/utils/teacmd"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/samber/lo"
+)
+
+// MODEL
+
+type step string
+
+const (
+	stepMenu    step = "menu"
+	stepPath    step = "path"
+	stepMapping step = "mapping"
+	stepReview  step = "review"
+	stepStage   step = "stage"
+)
+
+const (
+	actionExportYAML      = "Export to YAML"
+	actionExportJSON      = "Export to JSON"
+	actionExportUFW       = "Export as ufw commands"
+	actionExportNft       = "Export to an nftables ruleset"
+	actionExportIptables  = "Export to iptables-save (IPv4)"
+	actionExportIp6tables = "Export to ip6tables-save (IPv6)"
+	actionImport          = "Import from a JSON or YAML file"
+	actionImportIptables  = "Import rules from iptables-save output"
+)
+
+// exports maps the export actions to their format and the extension of the default file name.
+var exports = map[string]struct{ format, ext string }{
+	actionExportYAML:      {declarative.FormatYAML, "yaml"},
+	actionExportJSON:      {declarative.FormatJSON, "json"},
+	actionExportUFW:       {declarative.FormatScript, "sh"},
+	actionExportNft:       {declarative.FormatNft, "nft"},
+	actionExportIptables:  {declarative.FormatIptables, "v4"},
+	actionExportIp6tables: {declarative.FormatIp6tables, "v6"},
+}
+
+type TransferModule struct {
+	state   state.State
+	actions *focusablelist.SelectableList[string]
+	step    step
+	path    string
+	err     string
+
+	config     declarative.Config
+	interfaces *focusablelist.SelectableList[string]
+	mapping    map[string]*focusablelist.SelectableList[string]
+	local      []string
+	plan       declarative.Plan
+
+	staged  multiselect.MultiSelectableList[iptimport.Staged]
+	skipped []iptimport.Skipped
+}
+
+func Init(st state.State) TransferModule {
+	return TransferModule{
+		state: st,
+		actions: focusablelist.FromList([]string{
+			actionExportYAML, actionExportJSON, actionExportUFW,
+			actionExportNft, actionExportIptables, actionExportIp6tables,
+			actionImport, actionImportIptables,
+		}),
+		step: stepMenu,
+	}
+}
+
+// UPDATE
+
+type TransferEscMsg struct{}
+
+// TransferAppliedMsg reports the applied import so that the firewall state gets reloaded.
+type TransferAppliedMsg struct{ Output string }
+
+func (module TransferModule) UpdateTransferModule(msg tea.Msg) (TransferModule, tea.Cmd) {
+	m := module
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	key := keyMsg.String()
+
+	switch m.step {
+	case stepPath:
+		return m.updatePath(key)
+	case stepMapping:
+		return m.updateMapping(key)
+	case stepReview:
+		return m.updateReview(key)
+	case stepStage:
+		return m.updateStage(key)
+	}
+
+	switch key {
+	case "up", "k":
+		m.actions.Prev()
+	case "down", "j":
+		m.actions.Next()
+	case "enter":
+		m.step, m.err, m.path = stepPath, "", ""
+		if export, ok := exports[m.actions.Focused()]; ok {
+			m.path = fmt.Sprintf("fwtui-export-%s.%s", time.Now().Format("2006-01-02_15-04-05"), export.ext)
+		}
+	case "esc":
+		return m, func() tea.Msg {
+			return TransferEscMsg{}
+		}
+	}
+	return m, nil
+}
+
+func (module TransferModule) updatePath(key string) (TransferModule, tea.Cmd) {
+	m := module
+	switch key {
+	case "backspace":
+		m.path = stringsext.TrimLastChar(m.path)
+	case "esc":
+		m.step, m.err = stepMenu, ""
+	case "enter":
+		switch m.actions.Focused() {
+		case actionImport:
+			return m.load()
+		case actionImportIptables:
+			return m.stage()
+		}
+		return m.export()
+	default:
+		if len(key) == 1 {
+			m.path += key
+		}
+	}
+	return m, nil
+}
+
+func (module TransferModule) export() (TransferModule, tea.Cmd) {
+	m := module
+	live, err := m.state.Live()
+	if err != nil {
+		m.err = err.Error()
+		return m, nil
+	}
+	format := exports[m.actions.Focused()].format
+	data, err := declarative.ExportAs(live, format)
+	if err == nil {
+		err = os.WriteFile(m.path, data, lo.Ternary(format == declarative.FormatScript, os.FileMode(0700), 0600))
+	}
+	if err != nil {
+		m.err = err.Error()
+		return m, nil
+	}
+	m.step = stepMenu
+	return m, notification.CreateCmd(fmt.Sprintf("Exported to %s", m.path))
+}
+
+// load reads and validates the file to import, then asks for the interface mapping when the rules
+// name interfaces.
+func (module TransferModule) load() (TransferModule, tea.Cmd) {
+	m := module
+	config, err := declarative.Load(m.path)
+	if err != nil {
+		m.err = err.Error()
+		return m, nil
+	}
+	m.config, m.err, m.mapping = config, "", nil
+
+	names := config.Interfaces()
+	if len(names) == 0 {
+		return m.review()
+	}
+
+	m.local = localInterfaces()
+	m.interfaces = focusablelist.FromList(names)
+	m.mapping = map[string]*focusablelist.SelectableList[string]{}
+	for _, name := range names {
+		choices := append([]string{name}, lo.Without(m.local, name)...)
+		m.mapping[name] = focusablelist.FromList(choices)
+	}
+	m.step = stepMapping
+	return m, nil
+}
+
+func localInterfaces() []string {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	return lo.Map(interfaces, func(i net.Interface, _ int) string {
+		return i.Name
+	})
+}
+
+func (module TransferModule) updateMapping(key string) (TransferModule, tea.Cmd) {
+	m := module
+	switch key {
+	case "up", "k":
+		m.interfaces.Prev()
+	case "down", "j":
+		m.interfaces.Next()
+	case "left", "h":
+		m.mapping[m.interfaces.Focused()].Prev()
+	case "right", "l":
+		m.mapping[m.interfaces.Focused()].Next()
+	case "enter":
+		return m.review()
+	case "esc":
+		m.step = stepPath
+	}
+	return m, nil
+}
+
+func (module TransferModule) review() (TransferModule, tea.Cmd) {
+	m := module
+	config := m.config
+	if len(m.mapping) > 0 {
+		config = config.MapInterfaces(lo.MapValues(m.mapping, func(choice *focusablelist.SelectableList[string], _ string) string {
+			return choice.Focused()
+		}))
+	}
+
+	live, err := m.state.Live()
+	if err == nil {
+		m.plan, err = declarative.Compute(config, live)
+	}
+	if err != nil {
+		m.err = err.Error()
+		m.step = stepPath
+		return m, nil
+	}
+	m.step = stepReview
+	return m, nil
+}
+
+func (module TransferModule) updateReview(key string) (TransferModule, tea.Cmd) {
+	m := module
+	switch key {
+	case "enter":
+		if m.plan.Empty() {
+			m.step = stepMenu
+			return m, notification.CreateCmd("Nothing to import, the firewall matches the file")
+		}
+		plan := m.plan
+		return m, teacmd.RunOsCmdAndAfter(func() string {
+			output, err := declarative.Apply(plan)
+			if err != nil {
+				output += "\nError: " + err.Error()
+			}
+			return output
+		}, func(s string) tea.Msg {
+			return TransferAppliedMsg{Output: s}
+		})
+	case "esc":
+		m.step = lo.Ternary(len(m.mapping) > 0, stepMapping, stepPath)
+	}
+	return m, nil
+}
+
+// stage converts the iptables-save file and offers its rules and policies for selection. Changes
+// ufw has already are not selected.
+func (module TransferModule) stage() (TransferModule, tea.Cmd) {
+	m := module
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		m.err = err.Error()
+		return m, nil
+	}
+	live, err := m.state.Live()
+	if err != nil {
+		m.err = err.Error()
+		return m, nil
+	}
+
+	result := iptimport.Parse(string(data))
+	if len(result.Rules) == 0 && len(result.Defaults) == 0 {
+		m.err = fmt.Sprintf("No INPUT or OUTPUT rules ufw can express, %d lines left out", len(result.Skipped))
+		return m, nil
+	}
+	staged, tooManyPorts := iptimport.Stage(result, live.Rules, map[string]string{"incoming": live.Incoming, "outgoing": live.Outgoing})
+	m.staged = multiselect.FromList(staged)
+	for i, s := range staged {
+		if !s.Exists {
+			m.staged.Selected.Add(i)
+		}
+	}
+	m.skipped = append(result.Skipped, tooManyPorts...)
+	sort.Slice(m.skipped, func(i, j int) bool { return m.skipped[i].Line < m.skipped[j].Line })
+	m.step, m.err = stepStage, ""
+	return m, nil
+}
+
+func (module TransferModule) updateStage(key string) (TransferModule, tea.Cmd) {
+	m := module
+	switch key {
+	case "up", "k":
+		m.staged.Prev()
+	case "down", "j":
+		m.staged.Next()
+	case " ":
+		m.staged.Toggle()
+	case "enter":
+		if m.staged.NoneSelected() {
+			return m, notification.CreateCmd("Nothing selected to import")
+		}
+		staged := m.staged.GetSelectedItems()
+		return m, teacmd.RunOsCmdAndAfter(func() string {
+			output, err := iptimport.Apply(staged)
+			if err != nil {
+				output += "\nError: " + err.Error()
+			}
+			return output
+		}, func(s string) tea.Msg {
+			return TransferAppliedMsg{Output: s}
+		})
+	case "esc":
+		m.step = stepPath
+	}
+	return m, nil
+}
+
+// VIEW
+
+func (module TransferModule) ViewTransfer() string {
+	var lines []string
+	var help string
+
+	switch module.step {
+	case stepMenu:
+		lines = append(lines, "Export / import:", "")
+		module.actions.ForEach(func(action string, _ int, isFocused bool) {
+			lines = append(lines, fmt.Sprintf("%s %s", lo.Ternary(isFocused, ">", " "), action))
+		})
+		help = "↑↓ to navigate, Enter to select, Esc to cancel"
+	case stepPath:
+		lines = append(lines, module.actions.Focused()+":", "", "> File: "+module.path)
+		if module.err != "" {
+			lines = append(lines, "", module.err)
+		}
+		_, export := exports[module.actions.Focused()]
+		help = "type to edit, Enter to " + lo.Ternary(export, "export", "read the file") + ", Esc to go back"
+	case stepMapping:
+		lines = append(lines, "Map the interfaces of the file to the interfaces of this host:", "")
+		module.interfaces.ForEach(func(name string, _ int, isFocused bool) {
+			target := module.mapping[name].Focused()
+			missing := lo.Ternary(lo.Contains(module.local, target), "", " (not on this host)")
+			lines = append(lines, fmt.Sprintf("%s %-15s -> %s%s", lo.Ternary(isFocused, ">", " "), name, target, missing))
+		})
+		help = "↑↓ to navigate, ←→ to change the interface, Enter to review the changes, Esc to go back"
+	case stepReview:
+		lines = append(lines, fmt.Sprintf("Changes to import %s:", module.path), "", module.plan.String())
+		help = "Enter to apply (rules are backed up first), Esc to go back"
+	case stepStage:
+		lines = append(lines, fmt.Sprintf("Changes staged from %s:", module.path), "")
+		module.staged.ForEach(func(s iptimport.Staged, _ int, isFocused, isSelected bool) {
+			exists := lo.Ternary(s.Exists, " (ufw has it already)", "")
+			lines = append(lines, fmt.Sprintf("%s%s %s%s", lo.Ternary(isFocused, ">", " "), lo.Ternary(isSelected, "*", " "), s.Text, exists))
+		})
+		if len(module.skipped) > 0 {
+			lines = append(lines, "", fmt.Sprintf("Left out, ufw can't express these (%d):", len(module.skipped)))
+			for _, skipped := range module.skipped {
+				lines = append(lines, "  "+skipped.String())
+			}
+		}
+		help = "↑↓ to navigate, Space to select, Enter to apply the selected changes (rules are backed up first), Esc to go back"
+	}
+
+	return strings.Join(lines, "\n") + "\n\n" + help
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fmt"
+	"fwtui/domain/entity"
+	"fwtui/domain/fakeufw"
+	"fwtui/domain/sockets"
+	"fwtui/domain/ufw"
+	"fwtui/utils/oscmd"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Backend is the firewall the server reads and changes. Changes are ufw command lines, so they can be
+// replayed on the fake firewall by the anti-lockout check before they run.
+type Backend interface {
+	StatusVerbose() string
+	Rules() ([]ufw.Rule, error)
+	Profiles() ([]entity.UFWProfile, error)
+	InstallProfile(profile entity.UFWProfile) error
+	Listening() ([]sockets.Socket, error)
+	Backup() (string, error)
+	Run(command string) string
+}
+
+// Host is the firewall of this machine.
+type Host struct{}
+
+func (Host) StatusVerbose() string {
+	return ufw.StatusVerbose()
+}
+
+func (Host) Rules() ([]ufw.Rule, error) {
+	return ufw.ReadRules()
+}
+
+func (Host) Profiles() ([]entity.UFWProfile, error) {
+	return entity.LoadInstalledProfiles()
+}
+
+func (Host) InstallProfile(profile entity.UFWProfile) error {
+	res := entity.CreateProfile(profile)
+	if res.IsErr() {
+		return res.Err()
+	}
+	return nil
+}
+
+func (Host) Listening() ([]sockets.Socket, error) {
+	return sockets.ListListening()
+}
+
+func (Host) Backup() (string, error) {
+	return ufw.Backup()
+}
+
+func (Host) Run(command string) string {
+	return oscmd.RunCommand(command)
+}
+
+// Fake serves the in-memory firewall of the fakeufw package, to try the API or test its clients
+// without touching the host. Backups are kept in memory.
+type Fake struct {
+	Firewall *fakeufw.Firewall
+	Sockets  []sockets.Socket
+	Backups  []string
+}
+
+// NewFake returns an active firewall denying incoming traffic, with sshd listening on port 22 and a
+// rule letting it in.
+func NewFake() *Fake {
+	fw := fakeufw.New()
+	fw.Enabled = true
+	fw.Run("sudo ufw limit 22/tcp comment 'ssh'")
+	return &Fake{
+		Firewall: fw,
+		Sockets: []sockets.Socket{
+			{Protocol: "tcp", Address: net.IPv4zero, Port: 22, Process: "sshd"},
+			{Protocol: "tcp", V6: true, Address: net.IPv6zero, Port: 22, Process: "sshd"},
+		},
+	}
+}
+
+func (f *Fake) StatusVerbose() string {
+	return f.Firewall.StatusVerbose()
+}
+
+// Rules numbers the rules and writes their lines like `ufw status` does.
+func (f *Fake) Rules() ([]ufw.Rule, error) {
+	rules := f.Firewall.Rules()
+	for i := range rules {
+		rules[i].Line = fmt.Sprintf("[%2d] %s", rules[i].Number, rules[i].Summary())
+	}
+	return rules, nil
+}
+
+func (f *Fake) Profiles() ([]entity.UFWProfile, error) {
+	var profiles []entity.UFWProfile
+	for name, ports := range f.Firewall.Apps {
+		profiles = append(profiles, entity.UFWProfile{Name: name, Ports: strings.Split(ports, "|"), Installed: true})
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		return strings.ToLower(profiles[i].Name) < strings.ToLower(profiles[j].Name)
+	})
+	return profiles, nil
+}
+
+func (f *Fake) InstallProfile(profile entity.UFWProfile) error {
+	if _, ok := f.Firewall.Apps[profile.Name]; ok {
+		return fmt.Errorf("profile %s already exists", profile.Name)
+	}
+	f.Firewall.Apps[profile.Name] = strings.Join(profile.Ports, "|")
+	return nil
+}
+
+func (f *Fake) Listening() ([]sockets.Socket, error) {
+	return f.Sockets, nil
+}
+
+// Backup keeps the rule files as they are now.
+func (f *Fake) Backup() (string, error) {
+	v4, v6 := f.Firewall.UserRules()
+	f.Backups = append(f.Backups, v4+v6)
+	return fmt.Sprintf("memory:%d", len(f.Backups)), nil
+}
+
+func (f *Fake) Run(command string) string {
+	return f.Firewall.Run(command)
+}
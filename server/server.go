@@ -0,0 +1,428 @@
+// Package server exposes the firewall as a JSON API for dashboards and bots. Reading is open to
+// whoever can reach the socket, changes need the token and go through the same validation, backup
+// and anti-lockout check as the command line.
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fwtui/domain/entity"
+	"fwtui/domain/hostnames"
+	"fwtui/domain/lockout"
+	"fwtui/domain/sockets"
+	"fwtui/domain/ufw"
+	"fwtui/modules/createrule"
+	"fwtui/modules/defaultpolicies"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/samber/lo"
+)
+
+type Server struct {
+	backend Backend
+	token   string // changes are refused without one
+	mu      sync.Mutex
+}
+
+func New(backend Backend, token string) *Server {
+	return &Server{backend: backend, token: token}
+}
+
+// Handler routes the API. Requests are served one at a time, a change numbers rules by the state it
+// was checked against.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/status", s.status)
+	mux.HandleFunc("GET /v1/rules", s.rules)
+	mux.HandleFunc("GET /v1/defaults", s.defaults)
+	mux.HandleFunc("GET /v1/profiles", s.profiles)
+	mux.HandleFunc("GET /v1/listening", s.listening)
+	mux.HandleFunc("POST /v1/rules", s.authorized(s.addRule))
+	mux.HandleFunc("DELETE /v1/rules/{number}", s.authorized(s.deleteRule))
+	mux.HandleFunc("PUT /v1/defaults", s.authorized(s.setDefaults))
+	mux.HandleFunc("POST /v1/profiles/{name}", s.authorized(s.installProfile))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// Listen opens "unix:/path/to.sock" or a TCP address like "127.0.0.1:8080". The socket is only
+// accessible to its owner, a socket left behind by a server that is gone is replaced.
+func Listen(address string) (net.Listener, error) {
+	path, isUnix := strings.CutPrefix(address, "unix:")
+	if !isUnix {
+		return net.Listen("tcp", address)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by a running server", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// httpError is an error with the status code it is answered with.
+type httpError struct {
+	code int
+	err  error
+}
+
+func (e httpError) Error() string {
+	return e.err.Error()
+}
+
+func fail(code int, format string, args ...any) error {
+	return httpError{code, fmt.Errorf(format, args...)}
+}
+
+func writeJSON(w http.ResponseWriter, code int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	var httpErr httpError
+	if errors.As(err, &httpErr) {
+		code = httpErr.code
+	}
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+func (s *Server) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.token == "" {
+			writeError(w, fail(http.StatusForbidden, "changes are off, start serve with a token to allow them"))
+			return
+		}
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, fail(http.StatusUnauthorized, "missing or wrong bearer token"))
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// READ
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	status := s.backend.StatusVerbose()
+	if strings.HasPrefix(status, "Error:") {
+		writeError(w, errors.New(strings.TrimSpace(strings.TrimPrefix(status, "Error:"))))
+		return
+	}
+	enabled, logging := ufw.ParseStatusFlags(status)
+	rules, err := s.backend.Rules()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"enabled": enabled, "logging": logging, "rules": len(rules)})
+}
+
+func (s *Server) rules(w http.ResponseWriter, r *http.Request) {
+	rules, err := s.backend.Rules()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, lo.Map(rules, func(r ufw.Rule, _ int) ufw.RuleJSON { return r.JSON() }))
+}
+
+type defaultsJSON struct {
+	Incoming string `json:"incoming,omitempty"`
+	Outgoing string `json:"outgoing,omitempty"`
+	Routed   string `json:"routed,omitempty"`
+}
+
+func (s *Server) readDefaults() (defaultpolicies.DefaultPolicies, error) {
+	res := defaultpolicies.ParseUfwDefaults(s.backend.StatusVerbose())
+	if res.IsErr() {
+		return defaultpolicies.DefaultPolicies{}, fmt.Errorf("reading the default policies (is ufw enabled?): %w", res.Err())
+	}
+	return res.Value(), nil
+}
+
+func (s *Server) defaults(w http.ResponseWriter, r *http.Request) {
+	defaults, err := s.readDefaults()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, defaultsJSON{defaults.Incoming, defaults.Outgoing, defaults.Routed})
+}
+
+type profileJSON struct {
+	Name      string   `json:"name"`
+	Title     string   `json:"title,omitempty"`
+	Ports     []string `json:"ports"`
+	Installed bool     `json:"installed"`
+}
+
+// profiles lists the installed profiles followed by the built-in ones that can be installed.
+func (s *Server) profiles(w http.ResponseWriter, r *http.Request) {
+	installed, err := s.backend.Profiles()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	list := lo.Map(installed, func(p entity.UFWProfile, _ int) profileJSON {
+		return profileJSON{p.Name, p.Title, p.Ports, true}
+	})
+	for _, p := range builtinProfiles(installed) {
+		list = append(list, profileJSON{p.Name, p.Title, p.Ports, false})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// builtinProfiles are the predefined profiles the backend doesn't have yet.
+func builtinProfiles(installed []entity.UFWProfile) []entity.UFWProfile {
+	return entity.InstallableProfiles(installed)
+}
+
+type socketJSON struct {
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+	Port     int    `json:"port"`
+	V6       bool   `json:"v6"`
+	PID      int    `json:"pid,omitempty"`
+	Process  string `json:"process,omitempty"`
+}
+
+func (s *Server) listening(w http.ResponseWriter, r *http.Request) {
+	list, err := s.backend.Listening()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, lo.Map(list, func(s sockets.Socket, _ int) socketJSON {
+		return socketJSON{s.Protocol, s.Address.String(), s.Port, s.V6, s.PID, s.Process}
+	}))
+}
+
+// CHANGE
+
+type changeJSON struct {
+	Backup   string   `json:"backup,omitempty"`
+	Commands []string `json:"commands"`
+	Output   string   `json:"output"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// apply runs ufw commands after the anti-lockout check and a backup, stopping at the first failing
+// one like the command line does.
+func (s *Server) apply(w http.ResponseWriter, commands []string) {
+	if len(commands) == 0 {
+		writeJSON(w, http.StatusOK, changeJSON{Commands: []string{}, Output: "Nothing to change."})
+		return
+	}
+	if err := s.checkLockout(commands); err != nil {
+		writeError(w, err)
+		return
+	}
+	path, err := s.backend.Backup()
+	if err != nil {
+		writeError(w, fmt.Errorf("backup failed, nothing changed: %w", err))
+		return
+	}
+
+	change := changeJSON{Backup: path, Commands: commands}
+	var outputs []string
+	for _, command := range commands {
+		output := strings.TrimSpace(s.backend.Run(command))
+		outputs = append(outputs, output)
+		if strings.HasPrefix(output, "Error:") {
+			change.Output = strings.Join(outputs, "\n")
+			change.Error = fmt.Sprintf("%s failed, restore %s to roll back", command, path)
+			writeJSON(w, http.StatusInternalServerError, change)
+			return
+		}
+	}
+	change.Output = strings.Join(outputs, "\n")
+	writeJSON(w, http.StatusOK, change)
+}
+
+func (s *Server) checkLockout(commands []string) error {
+	rules, err := s.backend.Rules()
+	if err != nil {
+		return err
+	}
+	defaults, err := s.readDefaults()
+	if err != nil {
+		return err
+	}
+	profiles, err := s.backend.Profiles()
+	if err != nil {
+		return err
+	}
+	listening, err := s.backend.Listening()
+	if err != nil {
+		return err
+	}
+	enabled, _ := ufw.ParseStatusFlags(s.backend.StatusVerbose())
+
+	fw := lockout.Firewall{Enabled: enabled, Rules: rules, Incoming: defaults.Incoming, Profiles: profiles}
+	if err := lockout.Check(fw, commands, lockout.SSHPorts(listening)); err != nil {
+		return httpError{http.StatusConflict, err}
+	}
+	return nil
+}
+
+type ruleRequest struct {
+	Action    string `json:"action"`
+	Direction string `json:"direction"`
+	Proto     string `json:"proto"`
+	Port      string `json:"port"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Interface string `json:"interface"`
+	Comment   string `json:"comment"`
+}
+
+func decode(r *http.Request, value any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		return fail(http.StatusBadRequest, "reading the request: %v", err)
+	}
+	return nil
+}
+
+func (s *Server) addRule(w http.ResponseWriter, r *http.Request) {
+	req := ruleRequest{Action: "allow", Direction: "in", Proto: "tcp/udp"}
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	commands, err := createrule.Commands(createrule.Prefill{
+		Port:          req.Port,
+		Protocol:      createrule.Protocol(req.Proto),
+		Action:        createrule.Action(req.Action),
+		Direction:     createrule.Direction(req.Direction),
+		SourceIP:      req.From,
+		DestinationIP: req.To,
+		Interface:     req.Interface,
+		Comment:       req.Comment,
+	}, hostnames.DefaultResolver)
+	if err != nil {
+		writeError(w, httpError{http.StatusBadRequest, err})
+		return
+	}
+	s.apply(w, commands)
+}
+
+// deleteRule deletes a rule by the number `GET /v1/rules` lists, ?twins=true deletes its IPv4/IPv6
+// twin as well.
+func (s *Server) deleteRule(w http.ResponseWriter, r *http.Request) {
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil {
+		writeError(w, fail(http.StatusBadRequest, "invalid rule number %q", r.PathValue("number")))
+		return
+	}
+	rules, err := s.backend.Rules()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !lo.ContainsBy(rules, func(rule ufw.Rule) bool { return rule.Number == number }) {
+		writeError(w, fail(http.StatusNotFound, "no rule %d", number))
+		return
+	}
+
+	numbers := []int{number}
+	if r.URL.Query().Get("twins") == "true" {
+		if twin, ok := ufw.IPv6Twins(rules)[number]; ok {
+			numbers = append(numbers, twin)
+		}
+	}
+	s.apply(w, ufw.DeleteCommands(numbers))
+}
+
+// setDefaults changes the policies given in the request, like the default policies view only the
+// changed ones are written. IP forwarding is left alone.
+func (s *Server) setDefaults(w http.ResponseWriter, r *http.Request) {
+	var req defaultsJSON
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	current, err := s.readDefaults()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var commands []string
+	for _, policy := range []struct{ direction, current, selected string }{
+		{"incoming", current.Incoming, req.Incoming},
+		{"outgoing", current.Outgoing, req.Outgoing},
+		{"routed", current.Routed, req.Routed},
+	} {
+		switch {
+		case policy.selected == "" || policy.selected == policy.current:
+		case !lo.Contains([]string{"allow", "deny", "reject"}, policy.selected):
+			writeError(w, fail(http.StatusBadRequest, "invalid %s policy %q, use allow, deny or reject", policy.direction, policy.selected))
+			return
+		default:
+			commands = append(commands, ufw.DefaultPolicyCommand(policy.direction, policy.selected))
+		}
+	}
+	s.apply(w, commands)
+}
+
+// installProfile installs a built-in profile by name, ?allow=true allows it as well.
+func (s *Server) installProfile(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	installed, err := s.backend.Profiles()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	profile, found := lo.Find(installed, func(p entity.UFWProfile) bool { return strings.EqualFold(p.Name, name) })
+	if !found {
+		builtin, known := lo.Find(builtinProfiles(installed), func(p entity.UFWProfile) bool {
+			return strings.EqualFold(p.Name, name)
+		})
+		if !known {
+			writeError(w, fail(http.StatusNotFound, "unknown profile %q", name))
+			return
+		}
+		if err := s.backend.InstallProfile(builtin); err != nil {
+			writeError(w, err)
+			return
+		}
+		profile = builtin
+	}
+
+	if r.URL.Query().Get("allow") != "true" {
+		writeJSON(w, http.StatusOK, profileJSON{profile.Name, profile.Title, profile.Ports, true})
+		return
+	}
+	s.apply(w, []string{ufw.AllowProfileCommand(profile.Name)})
+}
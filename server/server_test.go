@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/json"
+	"fwtui/domain/ufw"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testToken = "secret"
+
+// request sends a request to a server on the fake firewall and returns the status code and body.
+func request(t *testing.T, handler http.Handler, method, path, token, body string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+func listRules(t *testing.T, handler http.Handler) []ufw.RuleJSON {
+	t.Helper()
+	code, body := request(t, handler, http.MethodGet, "/v1/rules", "", "")
+	if code != http.StatusOK {
+		t.Fatalf("GET /v1/rules: %d %s", code, body)
+	}
+	var rules []ufw.RuleJSON
+	if err := json.Unmarshal([]byte(body), &rules); err != nil {
+		t.Fatalf("GET /v1/rules: %v", err)
+	}
+	return rules
+}
+
+func TestChangesNeedTheToken(t *testing.T) {
+	fake := NewFake()
+	handler := New(fake, testToken).Handler()
+
+	for _, token := range []string{"", "wrong"} {
+		code, body := request(t, handler, http.MethodPost, "/v1/rules", token, `{"port": "443", "proto": "tcp"}`)
+		if code != http.StatusUnauthorized {
+			t.Errorf("token %q: %d %s, want 401", token, code, body)
+		}
+	}
+	if code, _ := request(t, handler, http.MethodGet, "/v1/rules", "", ""); code != http.StatusOK {
+		t.Errorf("reading without a token: %d, want 200", code)
+	}
+
+	code, _ := request(t, New(fake, "").Handler(), http.MethodPost, "/v1/rules", testToken, `{"port": "443", "proto": "tcp"}`)
+	if code != http.StatusForbidden {
+		t.Errorf("server without a token: %d, want 403", code)
+	}
+	if len(fake.Backups) != 0 || len(listRules(t, handler)) != 2 {
+		t.Error("a refused change touched the firewall")
+	}
+}
+
+func TestLockoutIsRefused(t *testing.T) {
+	fake := NewFake()
+	handler := New(fake, testToken).Handler()
+
+	// rule 1 is the only IPv4 rule letting SSH in
+	code, body := request(t, handler, http.MethodDelete, "/v1/rules/1", testToken, "")
+	if code != http.StatusConflict {
+		t.Fatalf("deleting the SSH rule: %d %s, want 409", code, body)
+	}
+	if !strings.Contains(body, "block SSH on port 22 over IPv4") {
+		t.Errorf("error %s doesn't name the blocked path", body)
+	}
+
+	code, body = request(t, handler, http.MethodPut, "/v1/defaults", testToken, `{"incoming": "allow"}`)
+	if code != http.StatusOK {
+		t.Fatalf("allowing incoming: %d %s", code, body)
+	}
+	// with incoming traffic allowed the SSH rule isn't needed anymore
+	if code, body := request(t, handler, http.MethodDelete, "/v1/rules/1?twins=true", testToken, ""); code != http.StatusOK {
+		t.Errorf("deleting the SSH rule with incoming allowed: %d %s, want 200", code, body)
+	}
+}
+
+func TestAddAndDeleteRoundTrip(t *testing.T) {
+	fake := NewFake()
+	handler := New(fake, testToken).Handler()
+	before := listRules(t, handler)
+
+	code, body := request(t, handler, http.MethodPost, "/v1/rules", testToken, `{"port": "443", "proto": "tcp", "comment": "web"}`)
+	if code != http.StatusOK {
+		t.Fatalf("adding a rule: %d %s", code, body)
+	}
+	var change changeJSON
+	if err := json.Unmarshal([]byte(body), &change); err != nil {
+		t.Fatal(err)
+	}
+	if change.Backup != "memory:1" || len(change.Commands) != 1 {
+		t.Errorf("change = %+v, want one command after the first backup", change)
+	}
+
+	rules := listRules(t, handler)
+	if len(rules) != len(before)+2 {
+		t.Fatalf("%d rules after adding, want %d", len(rules), len(before)+2)
+	}
+	added := rules[1]
+	if added.Port != "443" || added.Protocol != "tcp" || added.Comment != "web" || added.V6 {
+		t.Fatalf("rule 2 = %+v, want the IPv4 rule for 443/tcp", added)
+	}
+
+	code, body = request(t, handler, http.MethodDelete, "/v1/rules/2?twins=true", testToken, "")
+	if code != http.StatusOK {
+		t.Fatalf("deleting the rule: %d %s", code, body)
+	}
+	after := listRules(t, handler)
+	if len(after) != len(before) {
+		t.Fatalf("%d rules after deleting, want %d", len(after), len(before))
+	}
+	for i := range after {
+		if after[i].Line != before[i].Line {
+			t.Errorf("rule %d = %s, want %s", i+1, after[i].Line, before[i].Line)
+		}
+	}
+	if len(fake.Backups) != 2 {
+		t.Errorf("%d backups, want one per change", len(fake.Backups))
+	}
+
+	if code, _ := request(t, handler, http.MethodDelete, "/v1/rules/9", testToken, ""); code != http.StatusNotFound {
+		t.Errorf("deleting a missing rule: %d, want 404", code)
+	}
+}